@@ -2,7 +2,11 @@ package cose
 
 import (
 	"crypto"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"sync"
 )
 
 // Algorithm name
@@ -23,9 +27,44 @@ const (
 	AlgorithmES256 Algorithm = "ES256"
 	// AlgorithmEdDSA for signing with EdDSA/Ed25519
 	AlgorithmEdDSA Algorithm = "EdDSA"
+	// AlgorithmEd25519ph for signing with pre-hashed Ed25519ph w/ SHA-512, the algorithm has no
+	// IANA assignment and uses a private use value configurable with SetEd25519phValue
+	AlgorithmEd25519ph Algorithm = "Ed25519ph"
+	// AlgorithmSHA256 for hashing with SHA-256
+	AlgorithmSHA256 Algorithm = "SHA-256"
+	// AlgorithmSHA384 for hashing with SHA-384
+	AlgorithmSHA384 Algorithm = "SHA-384"
+	// AlgorithmSHA512 for hashing with SHA-512
+	AlgorithmSHA512 Algorithm = "SHA-512"
+	// AlgorithmA128GCM for encryption with AES-GCM w/ 128-bit key
+	AlgorithmA128GCM Algorithm = "A128GCM"
+	// AlgorithmA192GCM for encryption with AES-GCM w/ 192-bit key
+	AlgorithmA192GCM Algorithm = "A192GCM"
+	// AlgorithmA256GCM for encryption with AES-GCM w/ 256-bit key
+	AlgorithmA256GCM Algorithm = "A256GCM"
+	// AlgorithmDirect for direct use of the shared key as the content encryption key
+	AlgorithmDirect Algorithm = "direct"
+	// AlgorithmA128KW for key wrapping with AES Key Wrap w/ 128-bit key
+	AlgorithmA128KW Algorithm = "A128KW"
+	// AlgorithmA192KW for key wrapping with AES Key Wrap w/ 192-bit key
+	AlgorithmA192KW Algorithm = "A192KW"
+	// AlgorithmA256KW for key wrapping with AES Key Wrap w/ 256-bit key
+	AlgorithmA256KW Algorithm = "A256KW"
+	// AlgorithmECDHESHKDF256 for key agreement with ECDH ES w/ HKDF-SHA256
+	AlgorithmECDHESHKDF256 Algorithm = "ECDH-ES + HKDF-256"
+	// AlgorithmHMAC256_64 for MAC with HMAC w/ SHA-256 truncated to 64 bits
+	AlgorithmHMAC256_64 Algorithm = "HMAC 256/64"
+	// AlgorithmHMAC256 for MAC with HMAC w/ SHA-256
+	AlgorithmHMAC256 Algorithm = "HMAC 256/256"
+	// AlgorithmHMAC384 for MAC with HMAC w/ SHA-384
+	AlgorithmHMAC384 Algorithm = "HMAC 384/384"
+	// AlgorithmHMAC512 for MAC with HMAC w/ SHA-512
+	AlgorithmHMAC512 Algorithm = "HMAC 512/512"
 )
 
 func getAlg(name string) *algorithm {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
 	for _, a := range algorithms {
 		if a.Name == name {
 			return a
@@ -35,6 +74,8 @@ func getAlg(name string) *algorithm {
 }
 
 func getAlgByValue(value int64) *algorithm {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
 	for _, a := range algorithms {
 		if a.Value == value {
 			return a
@@ -50,55 +91,210 @@ const (
 	algorithmTypeKeyRSA
 	algorithmTypeKeyECDSA
 	algorithmTypeKeyED25519
+	algorithmTypeKeyWrap
+	algorithmTypeContentEncryption
+	algorithmTypeECDHES
+	algorithmTypeDirect
+	algorithmTypeMAC
 )
 
 type algorithm struct {
 	Name  string
 	Value int64
 
-	Hash crypto.Hash   // hash function
-	Type algorithmType // required key type
+	Hash    crypto.Hash   // hash function
+	Type    algorithmType // required key type
+	Signing bool          // algorithm is a signature algorithm
 
 	MinKeySize       int            // minimimum key size
 	KeyEllipticCurve elliptic.Curve // key elliptic curve type
+	KeySize          int            // symmetric key size in bits
+	TagSize          int            // MAC tag size in bytes
+
+	Implemented bool // algorithm is implemented by the library
+}
+
+// IsSigningAlgorithm returns true if the algorithm is used for signatures.
+func (a *algorithm) IsSigningAlgorithm() bool {
+	return a.Signing
+}
+
+// SignatureSize returns the signature size in bytes for a key of the given size in bits,
+// zero is returned if the size is not known, such as for RSA with zero key size.
+func (a *algorithm) SignatureSize(keyBits int) int {
+	switch a.Type {
+	case algorithmTypeKeyRSA:
+		return (keyBits + 7) / 8
+	case algorithmTypeKeyECDSA:
+		return 2 * curveByteSize(a.KeyEllipticCurve)
+	case algorithmTypeKeyED25519:
+		return ed25519.SignatureSize
+	default:
+		return 0
+	}
+}
+
+// AlgorithmInfo describes an algorithm and its capabilities.
+type AlgorithmInfo struct {
+	Name  string
+	Value int64
+
+	CanSign    bool // algorithm can be used for signing
+	CanVerify  bool // algorithm can be used for signature verification
+	CanEncrypt bool // algorithm can be used for encryption or key protection
+	CanMAC     bool // algorithm can be used for message authentication codes
+
+	KeyType    string // COSE key type, if known
+	Hash       string // hash function, if used
+	MinKeySize int    // minimum key size in bits
+	Curve      string // elliptic curve, if used
+}
+
+func (a *algorithm) info() AlgorithmInfo {
+	info := AlgorithmInfo{
+		Name:       a.Name,
+		Value:      a.Value,
+		CanSign:    a.Implemented && a.IsSigningAlgorithm(),
+		CanVerify:  a.Implemented && a.IsSigningAlgorithm(),
+		CanEncrypt: a.Implemented && !a.IsSigningAlgorithm() && a.Type != algorithmTypeMAC,
+		CanMAC:     a.Implemented && a.Type == algorithmTypeMAC,
+		MinKeySize: a.MinKeySize,
+	}
+	switch a.Type {
+	case algorithmTypeKeyRSA:
+		info.KeyType = "RSA"
+	case algorithmTypeKeyECDSA, algorithmTypeECDHES:
+		info.KeyType = "EC2"
+	case algorithmTypeKeyED25519:
+		info.KeyType = "OKP"
+		info.Curve = "Ed25519"
+	case algorithmTypeKeyWrap, algorithmTypeContentEncryption, algorithmTypeMAC:
+		info.KeyType = "Symmetric"
+		info.MinKeySize = a.KeySize
+	case algorithmTypeDirect:
+		info.KeyType = "Symmetric"
+	}
+	if a.Hash > 0 {
+		info.Hash = a.Hash.String()
+	}
+	if a.KeyEllipticCurve != nil {
+		info.Curve = a.KeyEllipticCurve.Params().Name
+	}
+	return info
+}
+
+// SupportedAlgorithms returns the algorithms implemented by the library.
+func SupportedAlgorithms() []AlgorithmInfo {
+	infos := make([]AlgorithmInfo, 0)
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	for _, a := range algorithms {
+		if a.Implemented {
+			infos = append(infos, a.info())
+		}
+	}
+	return infos
+}
+
+// AlgorithmInfoFor returns the information about the algorithm
+// and whether the algorithm is known.
+func AlgorithmInfoFor(alg Algorithm) (AlgorithmInfo, bool) {
+	a := getAlg(string(alg))
+	if a == nil {
+		return AlgorithmInfo{}, false
+	}
+	return a.info(), true
+}
+
+// Hash returns the hash function of the algorithm, false is returned
+// for unknown algorithms and algorithms without a hash function such as EdDSA.
+func (alg Algorithm) Hash() (crypto.Hash, bool) {
+	a := getAlg(string(alg))
+	if a == nil || a.Hash == 0 {
+		return 0, false
+	}
+	return a.Hash, true
 }
 
+// SetEd25519phValue sets the private use algorithm value of AlgorithmEd25519ph,
+// it should be called before encoding or decoding any messages. Signers and verifiers
+// created before keep using the previous value.
+func SetEd25519phValue(value int64) error {
+	if value >= -65536 {
+		return errors.New("algorithm value must be in the private use range below -65536")
+	}
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+	index := -1
+	for i, a := range algorithms {
+		if a.Name == string(AlgorithmEd25519ph) {
+			index = i
+		} else if a.Value == value {
+			return fmt.Errorf("algorithm value %d is used by %s", value, a.Name)
+		}
+	}
+	// The entry is replaced as algorithms returned by getAlg are read without the lock
+	a := *algorithms[index]
+	a.Value = value
+	algorithms[index] = &a
+	return nil
+}
+
+// algorithmsMu guards the entries of algorithms replaced by SetEd25519phValue
+var algorithmsMu sync.RWMutex
+
 // COSE algorithms from
 var algorithms = []*algorithm{
+	// Ed25519ph w/ SHA-512 in private use
+	{
+		Name:        string(AlgorithmEd25519ph),
+		Value:       -65537,
+		Signing:     true,
+		Type:        algorithmTypeKeyED25519,
+		Implemented: ed25519phSupported,
+		Hash:        crypto.SHA512,
+	},
 	// RSASSA-PKCS1-v1_5 using SHA-1
 	{
-		Name:  "RS1",
-		Value: -65535,
+		Name:    "RS1",
+		Value:   -65535,
+		Signing: true,
 	},
 	// WalnutDSA signature
 	{
-		Name:  "WalnutDSA",
-		Value: -260,
+		Name:    "WalnutDSA",
+		Value:   -260,
+		Signing: true,
 	},
 	// RSASSA-PKCS1-v1_5 using SHA-512
 	{
-		Name:  "RS512",
-		Value: -259,
+		Name:    "RS512",
+		Value:   -259,
+		Signing: true,
 	},
 	// RSASSA-PKCS1-v1_5 using SHA-384
 	{
-		Name:  "RS384",
-		Value: -258,
+		Name:    "RS384",
+		Value:   -258,
+		Signing: true,
 	},
 	// RSASSA-PKCS1-v1_5 using SHA-256
 	{
-		Name:  "RS256",
-		Value: -257,
+		Name:    "RS256",
+		Value:   -257,
+		Signing: true,
 	},
 	// ECDSA using secp256k1 curve and SHA-256
 	{
-		Name:  "ES256K",
-		Value: -47,
+		Name:    "ES256K",
+		Value:   -47,
+		Signing: true,
 	},
 	// HSS/LMS hash-based digital signature
 	{
-		Name:  "HSS-LMS",
-		Value: -46,
+		Name:    "HSS-LMS",
+		Value:   -46,
+		Signing: true,
 	},
 	// SHAKE-256 512-bit Hash Value
 	{
@@ -107,13 +303,15 @@ var algorithms = []*algorithm{
 	},
 	// SHA-2 512-bit Hash
 	{
-		Name:  "SHA-512",
+		Name:  string(AlgorithmSHA512),
 		Value: -44,
+		Hash:  crypto.SHA512,
 	},
 	// SHA-2 384-bit Hash
 	{
-		Name:  "SHA-384",
+		Name:  string(AlgorithmSHA384),
 		Value: -43,
+		Hash:  crypto.SHA384,
 	},
 	// RSAES-OAEP w/ SHA-512
 	{
@@ -132,33 +330,41 @@ var algorithms = []*algorithm{
 	},
 	// RSASSA-PSS w/ SHA-512
 	{
-		Name:       string(AlgorithmPS512),
-		Value:      -39,
-		Type:       algorithmTypeKeyRSA,
-		Hash:       crypto.SHA512,
-		MinKeySize: 2048,
+		Name:        string(AlgorithmPS512),
+		Value:       -39,
+		Signing:     true,
+		Type:        algorithmTypeKeyRSA,
+		Implemented: rsaSupported,
+		Hash:        crypto.SHA512,
+		MinKeySize:  2048,
 	},
 	// RSASSA-PSS w/ SHA-384
 	{
-		Name:       string(AlgorithmPS384),
-		Value:      -38,
-		Type:       algorithmTypeKeyRSA,
-		Hash:       crypto.SHA384,
-		MinKeySize: 2048,
+		Name:        string(AlgorithmPS384),
+		Value:       -38,
+		Signing:     true,
+		Type:        algorithmTypeKeyRSA,
+		Implemented: rsaSupported,
+		Hash:        crypto.SHA384,
+		MinKeySize:  2048,
 	},
 	// RSASSA-PSS w/ SHA-256
 	{
-		Name:       string(AlgorithmPS256),
-		Value:      -37,
-		Type:       algorithmTypeKeyRSA,
-		Hash:       crypto.SHA256,
-		MinKeySize: 2048,
+		Name:        string(AlgorithmPS256),
+		Value:       -37,
+		Signing:     true,
+		Type:        algorithmTypeKeyRSA,
+		Implemented: rsaSupported,
+		Hash:        crypto.SHA256,
+		MinKeySize:  2048,
 	},
 	// ECDSA w/ SHA-512
 	{
 		Name:             string(AlgorithmES512),
 		Value:            -36,
+		Signing:          true,
 		Type:             algorithmTypeKeyECDSA,
+		Implemented:      true,
 		Hash:             crypto.SHA512,
 		KeyEllipticCurve: elliptic.P521(),
 	},
@@ -166,7 +372,9 @@ var algorithms = []*algorithm{
 	{
 		Name:             string(AlgorithmES384),
 		Value:            -35,
+		Signing:          true,
 		Type:             algorithmTypeKeyECDSA,
+		Implemented:      true,
 		Hash:             crypto.SHA384,
 		KeyEllipticCurve: elliptic.P384(),
 	},
@@ -217,8 +425,11 @@ var algorithms = []*algorithm{
 	},
 	// ECDH ES w/ HKDF - generate key directly
 	{
-		Name:  "ECDH-ES + HKDF-256",
-		Value: -25,
+		Name:        string(AlgorithmECDHESHKDF256),
+		Value:       -25,
+		Type:        algorithmTypeECDHES,
+		Implemented: true,
+		Hash:        crypto.SHA256,
 	},
 	// SHAKE-128 256-bit Hash Value
 	{
@@ -232,8 +443,9 @@ var algorithms = []*algorithm{
 	},
 	// SHA-2 256-bit Hash
 	{
-		Name:  "SHA-256",
+		Name:  string(AlgorithmSHA256),
 		Value: -16,
+		Hash:  crypto.SHA256,
 	},
 	// SHA-2 256-bit Hash truncated to 64-bits
 	{
@@ -267,72 +479,116 @@ var algorithms = []*algorithm{
 	},
 	// EdDSA
 	{
-		Name:  string(AlgorithmEdDSA),
-		Value: -8,
-		Type:  algorithmTypeKeyED25519,
+		Name:        string(AlgorithmEdDSA),
+		Value:       -8,
+		Signing:     true,
+		Type:        algorithmTypeKeyED25519,
+		Implemented: true,
 	},
 	// ECDSA w/ SHA-256
 	{
 		Name:             string(AlgorithmES256),
 		Value:            -7,
+		Signing:          true,
 		Type:             algorithmTypeKeyECDSA,
+		Implemented:      true,
 		Hash:             crypto.SHA256,
 		KeyEllipticCurve: elliptic.P256(),
 	},
 	// Direct use of CEK
 	{
-		Name:  "direct",
-		Value: -6,
+		Name:        string(AlgorithmDirect),
+		Value:       -6,
+		Type:        algorithmTypeDirect,
+		Implemented: true,
 	},
 	// AES Key Wrap w/ 256-bit key
 	{
-		Name:  "A256KW",
-		Value: -5,
+		Name:        string(AlgorithmA256KW),
+		Value:       -5,
+		Type:        algorithmTypeKeyWrap,
+		Implemented: true,
+		KeySize:     256,
 	},
 	// AES Key Wrap w/ 192-bit key
 	{
-		Name:  "A192KW",
-		Value: -4,
+		Name:        string(AlgorithmA192KW),
+		Value:       -4,
+		Type:        algorithmTypeKeyWrap,
+		Implemented: true,
+		KeySize:     192,
 	},
 	// AES Key Wrap w/ 128-bit key
 	{
-		Name:  "A128KW",
-		Value: -3,
+		Name:        string(AlgorithmA128KW),
+		Value:       -3,
+		Type:        algorithmTypeKeyWrap,
+		Implemented: true,
+		KeySize:     128,
 	},
 	// AES-GCM mode w/ 128-bit key, 128-bit tag
 	{
-		Name:  "A128GCM",
-		Value: 1,
+		Name:        string(AlgorithmA128GCM),
+		Value:       1,
+		Type:        algorithmTypeContentEncryption,
+		Implemented: true,
+		KeySize:     128,
 	},
 	// AES-GCM mode w/ 192-bit key, 128-bit tag
 	{
-		Name:  "A192GCM",
-		Value: 2,
+		Name:        string(AlgorithmA192GCM),
+		Value:       2,
+		Type:        algorithmTypeContentEncryption,
+		Implemented: true,
+		KeySize:     192,
 	},
 	// AES-GCM mode w/ 256-bit key, 128-bit tag
 	{
-		Name:  "A256GCM",
-		Value: 3,
+		Name:        string(AlgorithmA256GCM),
+		Value:       3,
+		Type:        algorithmTypeContentEncryption,
+		Implemented: true,
+		KeySize:     256,
 	},
 	// HMAC w/ SHA-256 truncated to 64 bits
 	{
-		Name:  "HMAC 256/64",
-		Value: 4,
+		Name:        string(AlgorithmHMAC256_64),
+		Value:       4,
+		Type:        algorithmTypeMAC,
+		Implemented: true,
+		Hash:        crypto.SHA256,
+		KeySize:     256,
+		TagSize:     8,
 	},
 	// HMAC w/ SHA-256
 	{
-		Name:  "HMAC 256/256",
-		Value: 5,
+		Name:        string(AlgorithmHMAC256),
+		Value:       5,
+		Type:        algorithmTypeMAC,
+		Implemented: true,
+		Hash:        crypto.SHA256,
+		KeySize:     256,
+		TagSize:     32,
 	},
 	// HMAC w/ SHA-384
 	{
-		Name:  "HMAC 384/384",
-		Value: 6,
+		Name:        string(AlgorithmHMAC384),
+		Value:       6,
+		Type:        algorithmTypeMAC,
+		Implemented: true,
+		Hash:        crypto.SHA384,
+		KeySize:     384,
+		TagSize:     48,
 	},
 	// HMAC w/ SHA-512
 	{
-		Name:  "HMAC 512/512",
-		Value: 7,
+		Name:        string(AlgorithmHMAC512),
+		Value:       7,
+		Type:        algorithmTypeMAC,
+		Implemented: true,
+		Hash:        crypto.SHA512,
+		KeySize:     512,
+		TagSize:     64,
 	},
 	// AES-CCM mode 128-bit key, 64-bit tag, 13-byte nonce
 	{
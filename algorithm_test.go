@@ -0,0 +1,125 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlgorithm_SupportedAlgorithms(t *testing.T) {
+	supported := make(map[string]AlgorithmInfo)
+	for _, info := range SupportedAlgorithms() {
+		supported[info.Name] = info
+	}
+
+	for _, alg := range []Algorithm{
+		AlgorithmES256, AlgorithmES384, AlgorithmES512,
+		AlgorithmPS256, AlgorithmPS384, AlgorithmPS512,
+		AlgorithmEdDSA,
+	} {
+		t.Run(string(alg), func(t *testing.T) {
+			if strings.HasPrefix(string(alg), "PS") {
+				requireRSA(t)
+			}
+			info, ok := supported[string(alg)]
+			require.True(t, ok)
+			assert.True(t, info.CanSign)
+			assert.True(t, info.CanVerify)
+			assert.False(t, info.CanEncrypt)
+		})
+	}
+
+	assert.NotContains(t, supported, "RS1")
+	assert.Contains(t, supported, string(AlgorithmA256GCM))
+	assert.Contains(t, supported, string(AlgorithmA128KW))
+}
+
+func TestAlgorithm_AlgorithmInfoFor(t *testing.T) {
+	info, ok := AlgorithmInfoFor(AlgorithmES384)
+	require.True(t, ok)
+	assert.Equal(t, AlgorithmInfo{
+		Name:      "ES384",
+		Value:     -35,
+		CanSign:   true,
+		CanVerify: true,
+		KeyType:   "EC2",
+		Hash:      "SHA-384",
+		Curve:     "P-384",
+	}, info)
+
+	info, ok = AlgorithmInfoFor(AlgorithmPS256)
+	require.True(t, ok)
+	assert.Equal(t, "RSA", info.KeyType)
+	assert.Equal(t, 2048, info.MinKeySize)
+
+	info, ok = AlgorithmInfoFor(AlgorithmEdDSA)
+	require.True(t, ok)
+	assert.Equal(t, "OKP", info.KeyType)
+	assert.Equal(t, "Ed25519", info.Curve)
+	assert.Empty(t, info.Hash)
+
+	info, ok = AlgorithmInfoFor(AlgorithmHMAC256_64)
+	require.True(t, ok)
+	assert.True(t, info.CanMAC)
+	assert.False(t, info.CanEncrypt)
+	assert.Equal(t, "Symmetric", info.KeyType)
+	assert.Equal(t, 256, info.MinKeySize)
+
+	info, ok = AlgorithmInfoFor(Algorithm("RS1"))
+	require.True(t, ok)
+	assert.False(t, info.CanSign)
+	assert.False(t, info.CanVerify)
+
+	_, ok = AlgorithmInfoFor(Algorithm("unknown"))
+	assert.False(t, ok)
+}
+
+func TestAlgorithm_Hash(t *testing.T) {
+	tests := []struct {
+		alg  Algorithm
+		hash crypto.Hash
+		ok   bool
+	}{
+		{AlgorithmPS256, crypto.SHA256, true},
+		{AlgorithmES384, crypto.SHA384, true},
+		{AlgorithmPS512, crypto.SHA512, true},
+		{AlgorithmSHA256, crypto.SHA256, true},
+		{AlgorithmEdDSA, 0, false},
+		{AlgorithmA128GCM, 0, false},
+		{Algorithm("unknown"), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			hash, ok := tt.alg.Hash()
+			assert.Equal(t, tt.hash, hash)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestAlgorithm_SignatureSize(t *testing.T) {
+	tests := []struct {
+		alg     Algorithm
+		keyBits int
+		size    int
+	}{
+		{AlgorithmES256, 0, 64},
+		{AlgorithmES384, 0, 96},
+		{AlgorithmES512, 0, 132},
+		{AlgorithmEdDSA, 0, 64},
+		{AlgorithmPS256, 2048, 256},
+		{AlgorithmPS512, 4095, 512},
+		{AlgorithmPS256, 0, 0},
+		{AlgorithmA256GCM, 0, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.size, getAlg(string(tt.alg)).SignatureSize(tt.keyBits), "%s %d", tt.alg, tt.keyBits)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/rand"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// EncodeBatch encodes the messages on at most parallelism goroutines and returns the encoded
+// messages in the order of msgs, zero or negative parallelism uses GOMAXPROCS goroutines.
+//
+// Signers and headers can be shared by the messages, the protected headers of messages
+// sharing a signer and a Headers instance are encoded once. Messages must be distinct and
+// must not be modified until EncodeBatch returns. A random source set with WithRandomSource
+// is not safe for concurrent use, such encodings encode the messages sequentially.
+//
+// The error of the first failed message in the batch is returned as ErrBatchEncode.
+func (e *Encoding) EncodeBatch(msgs []Message, parallelism int) ([][]byte, error) {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > len(msgs) {
+		parallelism = len(msgs)
+	}
+	if e.rand != rand.Reader {
+		parallelism = 1
+	}
+
+	out := make([][]byte, len(msgs))
+	if parallelism <= 1 {
+		for i, msg := range msgs {
+			b, err := e.encodeBatchItem(msg)
+			if err != nil {
+				return nil, ErrBatchEncode{Index: i, Err: err}
+			}
+			out[i] = b
+		}
+		return out, nil
+	}
+
+	errs := make([]error, len(msgs))
+	next := int64(-1)
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(msgs) {
+					return
+				}
+				out[i], errs[i] = e.encodeBatchItem(msgs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, ErrBatchEncode{Index: i, Err: err}
+		}
+	}
+	return out, nil
+}
+
+func (e *Encoding) encodeBatchItem(msg Message) ([]byte, error) {
+	if msg == nil {
+		return nil, errors.New("message can not be nil")
+	}
+	return e.Encode(msg)
+}
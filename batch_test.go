@@ -0,0 +1,104 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding_EncodeBatch(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	headers := NewHeaders()
+	require.NoError(t, headers.SetProtected(HeaderContentType, "text/plain"))
+
+	msgs := make([]Message, 50)
+	for i := range msgs {
+		msg := NewSign1Message().WithContent([]byte(fmt.Sprint(i))).WithSigner(signer)
+		// Half of the messages share the headers
+		if i%2 == 0 {
+			msg.Headers = headers
+		}
+		msgs[i] = msg
+	}
+
+	for _, parallelism := range []int{0, 1, 4, 100} {
+		out, err := StdEncoding.EncodeBatch(msgs, parallelism)
+		require.NoError(t, err)
+		require.Len(t, out, len(msgs))
+		for i, data := range out {
+			msg, err := StdEncoding.DecodeSign1(data, config)
+			require.NoError(t, err)
+			assert.Equal(t, []byte(fmt.Sprint(i)), msg.GetContent())
+		}
+	}
+
+	out, err := StdEncoding.EncodeBatch(nil, 4)
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestEncoding_EncodeBatchError(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msgs := []Message{
+		NewSign1Message().WithSigner(signer),
+		NewSign1Message(),
+		NewSign1Message().WithSigner(signer),
+		nil,
+	}
+
+	_, err = StdEncoding.EncodeBatch(msgs, 4)
+	assert.ErrorIs(t, err, ErrNoSigner)
+	var batchErr ErrBatchEncode
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+
+	_, err = StdEncoding.EncodeBatch(msgs[2:], 4)
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+}
+
+func TestEncoding_EncodeBatchRandomSource(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msgs := make([]Message, 10)
+	for i := range msgs {
+		msgs[i] = NewSign1Message().WithContent([]byte(fmt.Sprint(i))).WithSigner(signer)
+	}
+
+	// A custom random source is not read concurrently
+	source := &exclusiveReader{t: t}
+	enc, err := NewEncoding(WithRandomSource(source))
+	require.NoError(t, err)
+	_, err = enc.EncodeBatch(msgs, 4)
+	assert.NoError(t, err)
+	assert.NotZero(t, atomic.LoadInt64(&source.reads))
+}
+
+// exclusiveReader is a random source failing the test when read concurrently.
+type exclusiveReader struct {
+	t       *testing.T
+	reading int32
+	reads   int64
+}
+
+func (r *exclusiveReader) Read(p []byte) (int, error) {
+	if !atomic.CompareAndSwapInt32(&r.reading, 0, 1) {
+		r.t.Error("random source read concurrently")
+	}
+	defer atomic.StoreInt32(&r.reading, 0)
+	atomic.AddInt64(&r.reads, 1)
+	time.Sleep(time.Millisecond)
+	return rand.Read(p)
+}
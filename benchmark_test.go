@@ -0,0 +1,267 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Baseline results are kept in BENCHMARKS.md, update them when changing signing or encoding.
+
+var benchmarkContent = []byte("This is the content.")
+
+func BenchmarkSign1Encode(b *testing.B) {
+	for _, tt := range vectorAlgorithms {
+		b.Run(tt.name, func(b *testing.B) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(b, tt.key))
+			require.NoError(b, err)
+			msg := NewSign1Message()
+			msg.SetContent(benchmarkContent)
+			require.NoError(b, msg.SetSigner(signer))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := StdEncoding.Encode(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSign1Decode(b *testing.B) {
+	for _, tt := range vectorAlgorithms {
+		b.Run(tt.name, func(b *testing.B) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(b, tt.key))
+			require.NoError(b, err)
+			msg := NewSign1Message()
+			msg.SetContent(benchmarkContent)
+			require.NoError(b, msg.SetSigner(signer))
+			data, err := StdEncoding.Encode(msg)
+			require.NoError(b, err)
+			config := &Config{GetVerifiers: staticVerifier(b, signer)}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			// Decoding in parallel with a shared config catches data races with -race
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := StdEncoding.Decode(data, config); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+func benchmarkSignMessageEncode(b *testing.B, n int) {
+	msg := NewSignMessage()
+	msg.SetContent(benchmarkContent)
+	for i := 0; i < n; i++ {
+		signer, err := NewSigner(AlgorithmES256, getPrivateKey(b, "ecdsa256"))
+		require.NoError(b, err)
+		require.NoError(b, signer.Headers.Set(HeaderKeyID, []byte(fmt.Sprint(i))))
+		msg.AddSigner(signer)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := StdEncoding.Encode(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSignMessageEncode_1(b *testing.B) {
+	benchmarkSignMessageEncode(b, 1)
+}
+
+func BenchmarkSignMessageEncode_5(b *testing.B) {
+	benchmarkSignMessageEncode(b, 5)
+}
+
+func BenchmarkSignMessageEncode_10(b *testing.B) {
+	benchmarkSignMessageEncode(b, 10)
+}
+
+// BenchmarkVerifierPool compares resolving a new verifier for each message with a verifier pool,
+// 100 goroutines per CPU verify the messages concurrently.
+func BenchmarkVerifierPool(b *testing.B) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(b, "ecdsa256"))
+	require.NoError(b, err)
+	msg := NewSign1Message()
+	msg.SetContent(benchmarkContent)
+	require.NoError(b, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(b, err)
+	key := getPublicKey(b, "ecdsa256")
+
+	pool, err := NewVerifierPool(AlgorithmES256, key)
+	require.NoError(b, err)
+	resolvers := []struct {
+		name         string
+		getVerifiers func(*Headers) ([]*Verifier, error)
+	}{
+		{"NewVerifier", func(*Headers) ([]*Verifier, error) {
+			v, err := NewVerifier(AlgorithmES256, key)
+			if err != nil {
+				return nil, err
+			}
+			return []*Verifier{v}, nil
+		}},
+		{"Pool", pool.GetVerifiers},
+	}
+	for _, r := range resolvers {
+		b.Run(r.name, func(b *testing.B) {
+			config := &Config{GetVerifiers: r.getVerifiers}
+			b.ReportAllocs()
+			b.SetParallelism(100)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := StdEncoding.Decode(data, config); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkVerifierVerifyPS256 compares verifying a valid signature with rejecting an oversized one.
+func BenchmarkVerifierVerifyPS256(b *testing.B) {
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(b, "rsa2048"))
+	require.NoError(b, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(b, err)
+	signature, err := signer.Sign(rand.Reader, benchmarkContent)
+	require.NoError(b, err)
+
+	for _, bb := range []struct {
+		name      string
+		signature []byte
+	}{
+		{"Valid", signature},
+		{"Oversized", make([]byte, 4096)},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = verifier.Verify(benchmarkContent, bb.signature)
+			}
+		})
+	}
+}
+
+func BenchmarkSign1DecodeX5Chain(b *testing.B) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(b, "ed25519"))
+	require.NoError(b, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(b, err)
+	msg := NewSign1Message()
+	msg.SetContent(benchmarkContent)
+	require.NoError(b, msg.Headers.Set(int64(33), x5chainHeader(4096)))
+	require.NoError(b, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(b, err)
+	config := &Config{GetVerifiers: func(*Headers) ([]*Verifier, error) {
+		return []*Verifier{verifier}, nil
+	}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := StdEncoding.Decode(data, config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var batchAlgorithms = []struct {
+	name string
+	alg  Algorithm
+	key  string
+}{
+	{name: "PS256", alg: AlgorithmPS256, key: "rsa2048"},
+	{name: "ES256", alg: AlgorithmES256, key: "ecdsa256"},
+}
+
+// batchMessages returns n messages signed by the signer, the messages share the protected
+// headers if shared is set and have equal but distinct headers otherwise.
+func batchMessages(b *testing.B, signer *Signer, n int, shared bool) []Message {
+	headers := NewHeaders()
+	require.NoError(b, headers.SetProtected(HeaderContentType, "application/cwt"))
+	require.NoError(b, headers.SetProtected(HeaderKeyID, []byte("batch-key")))
+	require.NoError(b, headers.SetProtected("issuer", "https://issuer.example"))
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msg := NewSign1Message().WithContent(benchmarkContent).WithSigner(signer)
+		msg.Headers = headers
+		if !shared {
+			msg.Headers = headers.Copy()
+		}
+		msgs[i] = msg
+	}
+	return msgs
+}
+
+// BenchmarkEncodeBatch encodes batches of 1000 messages with 1, 2 and 4 goroutines and GOMAXPROCS,
+// the speedup is bounded by the number of cores.
+func BenchmarkEncodeBatch(b *testing.B) {
+	for _, tt := range batchAlgorithms {
+		signer, err := NewSigner(tt.alg, getPrivateKey(b, tt.key))
+		require.NoError(b, err)
+		msgs := batchMessages(b, signer, 1000, true)
+		for _, parallelism := range []int{1, 2, 4, 0} {
+			name := fmt.Sprintf("%s/%d", tt.name, parallelism)
+			if parallelism == 0 {
+				name = tt.name + "/GOMAXPROCS"
+			}
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := StdEncoding.EncodeBatch(msgs, parallelism); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkSign1EncodeSequential compares encoding 1000 messages sharing the protected headers,
+// which are encoded once, with messages having distinct copies of the headers.
+func BenchmarkSign1EncodeSequential(b *testing.B) {
+	for _, tt := range batchAlgorithms {
+		signer, err := NewSigner(tt.alg, getPrivateKey(b, tt.key))
+		require.NoError(b, err)
+		for _, shared := range []bool{true, false} {
+			msgs := batchMessages(b, signer, 1000, shared)
+			name := tt.name + "/Shared"
+			if !shared {
+				name = tt.name + "/Distinct"
+			}
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for _, msg := range msgs {
+						if _, err := StdEncoding.Encode(msg); err != nil {
+							b.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
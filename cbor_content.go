@@ -0,0 +1,84 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// tagEncodedCBOR is the CBOR tag of an embedded CBOR data item (RFC 8949 section 3.4.5.1)
+const tagEncodedCBOR = 24
+
+// tagSelfDescribedCBOR is the self-described CBOR tag without semantics (RFC 8949 section 3.4.6)
+const tagSelfDescribedCBOR = 55799
+
+// SetCBORContent sets the message content to the CBOR encoding of v,
+// the content is wrapped in tag 24 (encoded CBOR data item) if wrapTag24 is true.
+func (m *Sign1Message) SetCBORContent(v interface{}, wrapTag24 bool) error {
+	e := m.contentEncoding()
+	b, err := e.marshal(v)
+	if err != nil {
+		return err
+	}
+	if wrapTag24 {
+		if b, err = e.marshal(cbor.Tag{Number: tagEncodedCBOR, Content: b}); err != nil {
+			return err
+		}
+	}
+	m.content = b
+	return nil
+}
+
+// GetCBORContent unmarshals the CBOR message content into v,
+// content wrapped in tag 24 (encoded CBOR data item) is unwrapped.
+func (m *Sign1Message) GetCBORContent(v interface{}) error {
+	e := m.contentEncoding()
+	content := m.content
+	if len(content) == 0 {
+		return errors.New("message content is empty, expected CBOR data")
+	}
+	if isEncodedCBOR(content) {
+		wrapped, err := unwrapEncodedCBOR(e, content)
+		if err != nil {
+			return err
+		}
+		content = wrapped
+	}
+	if err := e.unmarshal(stageContent, content, v); err != nil {
+		return fmt.Errorf("message content is not CBOR data (leading byte 0x%02x): %w", content[0], err)
+	}
+	return nil
+}
+
+// isEncodedCBOR reports whether the content starts with tag 24.
+func isEncodedCBOR(content []byte) bool {
+	return len(content) > 1 && content[0] == 0xd8 && content[1] == tagEncodedCBOR
+}
+
+// unwrapEncodedCBOR returns the data item wrapped in tag 24.
+func unwrapEncodedCBOR(e *Encoding, content []byte) ([]byte, error) {
+	var tag cbor.RawTag
+	if err := e.unmarshal(stageContent, content, &tag); err != nil {
+		return nil, fmt.Errorf("message content is not a valid tag 24 data item: %w", err)
+	}
+	var wrapped []byte
+	if err := e.unmarshal(stageContent, tag.Content, &wrapped); err != nil {
+		return nil, fmt.Errorf("message content tag 24 does not contain a byte string: %w", err)
+	}
+	if len(wrapped) == 0 {
+		return nil, errors.New("message content tag 24 contains empty data, expected CBOR data")
+	}
+	return wrapped, nil
+}
+
+func (m *Sign1Message) contentEncoding() *Encoding {
+	if m.encoding != nil {
+		return m.encoding
+	}
+	return StdEncoding
+}
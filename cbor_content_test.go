@@ -0,0 +1,70 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign1Message_CBORContent(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	type nested struct {
+		List []string          `cbor:"list"`
+		Map  map[string][]byte `cbor:"map"`
+	}
+	payload := map[string]nested{
+		"first":  {List: []string{"one", "two"}, Map: map[string][]byte{"key": {1, 2}}},
+		"second": {Map: map[string][]byte{}},
+	}
+
+	for _, wrap := range []bool{false, true} {
+		t.Run(map[bool]string{false: "raw", true: "tag 24"}[wrap], func(t *testing.T) {
+			msg := NewSign1Message()
+			require.NoError(t, msg.SetCBORContent(payload, wrap))
+			require.NoError(t, msg.SetSigner(signer))
+			if wrap {
+				assert.Equal(t, []byte{0xd8, 0x18}, msg.GetContent()[:2])
+			} else {
+				assert.Equal(t, byte(0xa2), msg.GetContent()[0])
+			}
+
+			b, err := StdEncoding.Encode(msg)
+			require.NoError(t, err)
+			dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+			require.NoError(t, err)
+
+			var decoded map[string]nested
+			require.NoError(t, dec.(*Sign1Message).GetCBORContent(&decoded))
+			assert.Equal(t, payload, decoded)
+		})
+	}
+}
+
+func TestSign1Message_GetCBORContentInvalid(t *testing.T) {
+	var v map[string]interface{}
+
+	msg := NewSign1Message()
+	assert.EqualError(t, msg.GetCBORContent(&v), "message content is empty, expected CBOR data")
+
+	msg.SetContent([]byte("plain text"))
+	err := msg.GetCBORContent(&v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "leading byte 0x70")
+
+	msg.SetContent([]byte{0xd8, 0x18, 0x01})
+	err = msg.GetCBORContent(&v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not contain a byte string")
+
+	msg.SetContent([]byte{0xd8, 0x18, 0x42, 0xff, 0x00})
+	err = msg.GetCBORContent(&v)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "leading byte 0xff")
+}
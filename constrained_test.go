@@ -0,0 +1,86 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// constrainedTags leave out the optional dependencies for WASM and TinyGo builds
+var constrainedTags = []string{"cose_norsa", "cose_nox509", "cose_noexpvar", "cose_nohttp"}
+
+// optionalImports lists the packages imported only by the file gated by the build tag
+var optionalImports = map[string]string{
+	"crypto/rsa":  "rsa.go",
+	"crypto/x509": "x509.go",
+	"expvar":      "metrics_expvar.go",
+	"net/http":    "http.go",
+}
+
+func TestConstrainedBuild_OptionalImports(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly)
+		require.NoError(t, err)
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			require.NoError(t, err)
+			if file, ok := optionalImports[path]; ok {
+				assert.Equal(t, file, name, "%s must only be imported by %s", path, file)
+			}
+		}
+	}
+}
+
+func TestConstrainedBuild_Imports(t *testing.T) {
+	ctx := build.Default
+	ctx.BuildTags = append(ctx.BuildTags, constrainedTags...)
+	pkg, err := ctx.ImportDir(".", 0)
+	require.NoError(t, err)
+
+	for path := range optionalImports {
+		assert.NotContains(t, pkg.Imports, path)
+	}
+	for _, name := range []string{"rsa.go", "x509.go", "metrics_expvar.go", "http.go"} {
+		assert.NotContains(t, pkg.GoFiles, name)
+	}
+}
+
+func TestConstrainedBuild_Dependencies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping dependency listing in short mode")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not available")
+	}
+
+	cmd := exec.Command(gocmd, "list", "-deps", "-tags", strings.Join(constrainedTags, " "), ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	deps := strings.Fields(string(out))
+	for _, path := range []string{"crypto/rsa", "crypto/x509", "expvar", "net/http"} {
+		assert.NotContains(t, deps, path)
+	}
+}
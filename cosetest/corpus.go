@@ -0,0 +1,154 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package cosetest provides utilities for testing COSE message decoding against test corpora.
+package cosetest
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zzdats/go-cose"
+)
+
+// TestCase is a corpus test case in the DGC test data format.
+type TestCase struct {
+	// Path is the test case file path relative to the corpus directory
+	Path string
+	// Message is the encoded COSE message
+	Message []byte
+	// Certificate is the signer certificate of the test context, nil if missing or invalid
+	Certificate *x509.Certificate
+	// ExpectedVerify is true if the message signature is expected to verify
+	ExpectedVerify bool
+}
+
+// ResolverFunc returns the verifiers for the message headers of the test case.
+type ResolverFunc func(tc *TestCase, headers *cose.Headers) ([]*cose.Verifier, error)
+
+// ErrorClass reports whether the decoding error is of the expected class.
+type ErrorClass func(err error) bool
+
+// Is returns the error class of errors matching the target with errors.Is.
+func Is(target error) ErrorClass {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// As returns the error class of errors matching the type of target with errors.As,
+// target must be a non-nil pointer.
+func As(target interface{}) ErrorClass {
+	t := reflect.TypeOf(target).Elem()
+	return func(err error) bool {
+		return errors.As(err, reflect.New(t).Interface())
+	}
+}
+
+var (
+	// InvalidStructure is the error class of messages that are not valid COSE structures.
+	InvalidStructure = As(&cose.ErrInvalidMessageStructure{})
+	// VerificationFailed is the error class of messages with an invalid signature.
+	VerificationFailed = Is(cose.ErrVerification)
+)
+
+type testFile struct {
+	COSE    string `json:"COSE"`
+	TestCtx struct {
+		Certificate string `json:"CERTIFICATE"`
+	} `json:"TESTCTX"`
+	ExpectedResults struct {
+		ExpectedVerify *bool `json:"EXPECTEDVERIFY"`
+	} `json:"EXPECTEDRESULTS"`
+}
+
+// RunCorpus decodes every JSON test case in the directory tree as a subtest.
+//
+// Test cases without a COSE message or an expected verification result are skipped.
+// The decoding error of test cases listed in expectations by their slash separated
+// relative path must be of the given class, otherwise verification must succeed or
+// fail with ErrVerification as expected by the test case.
+func RunCorpus(t *testing.T, dir string, resolve ResolverFunc, expectations map[string]ErrorClass) {
+	t.Helper()
+
+	seen := make(map[string]bool, len(expectations))
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		t.Run(rel, func(t *testing.T) {
+			runTestCase(t, path, rel, resolve, expectations[rel])
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path := range expectations {
+		if !seen[path] {
+			t.Errorf("expected test case %s not found in corpus", path)
+		}
+	}
+}
+
+func runTestCase(t *testing.T, path, rel string, resolve ResolverFunc, class ErrorClass) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f testFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.COSE) == 0 || f.ExpectedResults.ExpectedVerify == nil {
+		t.Skip("no COSE message verification expected")
+	}
+
+	tc := &TestCase{
+		Path:           rel,
+		ExpectedVerify: *f.ExpectedResults.ExpectedVerify,
+	}
+	if tc.Message, err = hex.DecodeString(f.COSE); err != nil {
+		t.Fatal(err)
+	}
+	if der, err := base64.StdEncoding.DecodeString(f.TestCtx.Certificate); err == nil {
+		tc.Certificate, _ = x509.ParseCertificate(der)
+	}
+
+	dec, err := cose.StdEncoding.Decode(tc.Message, &cose.Config{
+		GetVerifiers: func(headers *cose.Headers) ([]*cose.Verifier, error) {
+			return resolve(tc, headers)
+		},
+	})
+	switch {
+	case class != nil:
+		if !class(err) {
+			t.Fatalf("unexpected error class: %v", err)
+		}
+		return
+	case tc.ExpectedVerify && err != nil:
+		t.Fatalf("expected verification to succeed: %v", err)
+	case !tc.ExpectedVerify && !errors.Is(err, cose.ErrVerification):
+		t.Fatalf("expected verification error: %v", err)
+	}
+	if len(dec.GetContent()) == 0 {
+		t.Fatal("decoded message content is empty")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cosetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zzdats/go-cose"
+)
+
+func writeTestCase(t *testing.T, path string, msg []byte, cert []byte, expectedVerify interface{}) {
+	data := map[string]interface{}{
+		"COSE": hex.EncodeToString(msg),
+		"TESTCTX": map[string]interface{}{
+			"CERTIFICATE": base64.StdEncoding.EncodeToString(cert),
+		},
+		"EXPECTEDRESULTS": map[string]interface{}{},
+	}
+	if expectedVerify != nil {
+		data["EXPECTEDRESULTS"].(map[string]interface{})["EXPECTEDVERIFY"] = expectedVerify
+	}
+	b, err := json.Marshal(data)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+}
+
+func TestRunCorpus(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{SerialNumber: big.NewInt(1)},
+		&x509.Certificate{SerialNumber: big.NewInt(1)}, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	signer, err := cose.NewSigner(cose.AlgorithmES256, key)
+	require.NoError(t, err)
+	msg := cose.NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := cose.StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	tampered := append([]byte{}, b...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	dir := t.TempDir()
+	writeTestCase(t, filepath.Join(dir, "valid", "1.json"), b, cert, true)
+	writeTestCase(t, filepath.Join(dir, "valid", "2.json"), tampered, cert, false)
+	writeTestCase(t, filepath.Join(dir, "invalid", "1.json"), []byte{0xd2, 0x84, 0x40}, cert, false)
+	writeTestCase(t, filepath.Join(dir, "invalid", "2.json"), b, []byte{}, true)
+	writeTestCase(t, filepath.Join(dir, "skipped.json"), b, cert, nil)
+
+	errMissingCertificate := errors.New("missing certificate")
+	var resolved []string
+	RunCorpus(t, dir, func(tc *TestCase, headers *cose.Headers) ([]*cose.Verifier, error) {
+		resolved = append(resolved, tc.Path)
+		if tc.Certificate == nil {
+			return nil, errMissingCertificate
+		}
+		v, err := cose.NewVerifier(cose.AlgorithmES256, tc.Certificate.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return []*cose.Verifier{v}, nil
+	}, map[string]ErrorClass{
+		"invalid/1.json": InvalidStructure,
+		"invalid/2.json": Is(errMissingCertificate),
+	})
+	assert.ElementsMatch(t, []string{"invalid/2.json", "valid/1.json", "valid/2.json"}, resolved)
+}
+
+func TestErrorClass(t *testing.T) {
+	err := fmt.Errorf("decode: %w", cose.ErrInvalidMessageStructure{Err: io.ErrUnexpectedEOF})
+	assert.True(t, InvalidStructure(err))
+	assert.True(t, Is(io.ErrUnexpectedEOF)(err))
+	assert.False(t, VerificationFailed(err))
+	assert.False(t, InvalidStructure(cose.ErrVerification))
+	assert.True(t, VerificationFailed(cose.ErrVerification))
+}
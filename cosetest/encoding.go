@@ -0,0 +1,27 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cosetest
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/zzdats/go-cose"
+)
+
+// NewTestEncoding creates a COSE encoding producing the same output for the same seed,
+// messages and keys for byte-for-byte comparison in tests.
+//
+// IVs, content encryption keys and ephemeral keys are read from a math/rand source seeded
+// with the seed and signatures use deterministic signing with the seed. The encoding must
+// not be used for encoding production messages.
+func NewTestEncoding(seed int64, opts ...cose.EncodingOption) (*cose.Encoding, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(seed))
+	return cose.NewEncoding(append([]cose.EncodingOption{
+		cose.WithRandomSource(rand.New(rand.NewSource(seed))),
+		cose.WithDeterministicSigning(b),
+	}, opts...)...)
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cosetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zzdats/go-cose"
+)
+
+func TestNewTestEncoding(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := cose.NewSigner(cose.AlgorithmES256, key)
+	require.NoError(t, err)
+
+	encode := func(seed int64) ([]byte, []byte) {
+		enc, err := NewTestEncoding(seed)
+		require.NoError(t, err)
+
+		sign1 := cose.NewSign1Message()
+		sign1.SetContent([]byte("test"))
+		require.NoError(t, sign1.SetSigner(signer))
+		signed, err := enc.Encode(sign1)
+		require.NoError(t, err)
+
+		encrypt0 := cose.NewEncrypt0Message()
+		encrypt0.SetContent([]byte("test"))
+		require.NoError(t, encrypt0.SetAlgorithm(cose.AlgorithmA128GCM))
+		require.NoError(t, encrypt0.SetKey(make([]byte, 16)))
+		encrypted, err := enc.Encode(encrypt0)
+		require.NoError(t, err)
+		return signed, encrypted
+	}
+
+	signed, encrypted := encode(1)
+	signed2, encrypted2 := encode(1)
+	assert.Equal(t, signed, signed2)
+	assert.Equal(t, encrypted, encrypted2)
+
+	// ECDSA nonces depend only on the key and message, IVs depend on the seed
+	signed2, encrypted2 = encode(2)
+	assert.Equal(t, signed, signed2)
+	assert.NotEqual(t, encrypted, encrypted2)
+
+	_, err = cose.StdEncoding.Decode(signed, &cose.Config{GetVerifiers: func(*cose.Headers) ([]*cose.Verifier, error) {
+		v, err := signer.ToVerifier()
+		return []*cose.Verifier{v}, err
+	}})
+	assert.NoError(t, err)
+}
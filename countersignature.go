@@ -0,0 +1,74 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import "errors"
+
+// AddCounterSignature0 sets the signer of the abbreviated countersignature, the countersignature
+// is computed when encoding the message and only its signature is stored in the counter signature0
+// unprotected header.
+//
+// The countersignature is version 1 of RFC 8152 section 4.5 with the CounterSignature0 context,
+// it covers the protected headers and the payload but not the message signature. The version 2
+// countersignatures of RFC 9338 are not implemented.
+//
+// The countersignature algorithm is not encoded and must be known to the verifier.
+func (m *Sign1Message) AddCounterSignature0(signer *Signer) error {
+	if signer == nil {
+		return errors.New("signer can not be nil")
+	}
+	m.counterSigner0 = signer
+	return nil
+}
+
+// VerifyCounterSignature0 verifies the abbreviated countersignature of the decoded message
+// with the given verifier using the external data the message was decoded with.
+func (m *Sign1Message) VerifyCounterSignature0(verifier *Verifier) error {
+	if m.raw == nil {
+		return ErrMessageNotDecoded
+	}
+	if verifier == nil {
+		return errors.New("verifier can not be nil")
+	}
+	v, _, err := m.Headers.Lookup(HeaderCounterSignature0)
+	if err != nil {
+		return err
+	}
+	signature, ok := v.([]byte)
+	if !ok || len(signature) == 0 {
+		return ErrMissingCounterSignature
+	}
+	digest, err := m.raw.CounterSignature0Digest(m.encoding, m.rawExternal)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(digest, signature)
+}
+
+// CounterSignature0Digest returns the Countersign_structure of the abbreviated countersignature,
+// the sign_protected field is omitted as the countersignature has no protected headers.
+func (m *sign1Message) CounterSignature0Digest(e *Encoding, external []byte) ([]byte, error) {
+	s := SigStructure{
+		Context:       ContextCounterSignature0,
+		BodyProtected: m.Protected,
+		ExternalAAD:   external,
+		Payload:       m.Payload,
+	}
+	return s.Marshal(e)
+}
+
+// counterSign0 stores the abbreviated countersignature of the signed message in unprotected headers.
+func (m *sign1Message) counterSign0(e *Encoding, signer *Signer, external []byte) error {
+	digest, err := m.CounterSignature0Digest(e, external)
+	if err != nil {
+		return err
+	}
+	signature, err := e.signDigest(signer, digest)
+	if err != nil {
+		return err
+	}
+	m.Unprotected[getCommonHeader(HeaderCounterSignature0)] = signature
+	return nil
+}
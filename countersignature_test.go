@@ -0,0 +1,153 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign1Message_CounterSignature0Structure(t *testing.T) {
+	m := &sign1Message{
+		Protected: mustHex(t, "a10126"),
+		Payload:   []byte("This is the content."),
+	}
+	b, err := m.CounterSignature0Digest(StdEncoding, nil)
+	require.NoError(t, err)
+	// ["CounterSignature0", h'A10126', h'', 'This is the content.']
+	assert.Equal(t, "84"+"71436f756e7465725369676e617475726530"+"43a10126"+"40"+
+		"54546869732069732074686520636f6e74656e742e", hex.EncodeToString(b))
+}
+
+func TestSign1Message_CounterSignature0(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	counterSigner, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	counterVerifier, err := counterSigner.ToVerifier()
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("This is the content."))
+	require.NoError(t, msg.SetSigner(signer))
+	assert.Error(t, msg.AddCounterSignature0(nil))
+	require.NoError(t, msg.AddCounterSignature0(counterSigner))
+	// Full countersignature coexists with the abbreviated one
+	full := []interface{}{[]byte{}, map[interface{}]interface{}{int64(4): []byte("11")}, []byte{0x01}}
+	require.NoError(t, msg.Headers.Set(HeaderCounterSignature, full))
+
+	b, err := StdEncoding.EncodeWithExternal(msg, []byte("external"))
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.DecodeWithExternal(b, []byte("external"), &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	decoded := dec.(*Sign1Message)
+	require.NoError(t, decoded.VerifyCounterSignature0(counterVerifier))
+	cs, err := decoded.Headers.Get(HeaderCounterSignature)
+	require.NoError(t, err)
+	assert.Len(t, cs, 3)
+
+	signerVerifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	assert.Error(t, decoded.VerifyCounterSignature0(signerVerifier))
+
+	// Countersignature covers the external data
+	dec, err = StdEncoding.DecodeWithExternal(b, nil, nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, dec.(*Sign1Message).VerifyCounterSignature0(counterVerifier), ErrVerification)
+
+	assert.ErrorIs(t, msg.VerifyCounterSignature0(counterVerifier), ErrMessageNotDecoded)
+
+	plain := NewSign1Message()
+	plain.SetContent([]byte("test"))
+	require.NoError(t, plain.SetSigner(signer))
+	b, err = StdEncoding.Encode(plain)
+	require.NoError(t, err)
+	dec, err = StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	assert.ErrorIs(t, dec.(*Sign1Message).VerifyCounterSignature0(counterVerifier), ErrMissingCounterSignature)
+}
+
+// coseWGKey11 is the seed of the Ed25519 key "11" of the COSE WG examples, RFC 8032 section 7.1 TEST 1.
+const coseWGKey11 = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+
+func TestSign1Message_CounterSignature0Example(t *testing.T) {
+	// COSE_Sign1 message of the COSE WG examples countersign/signed1-01.json without the countersignature header
+	data, err := os.ReadFile(filepath.Join("testdata", "vectors", "cose-wg-signed1-01.hex"))
+	require.NoError(t, err)
+	published := mustHex(t, strings.TrimSpace(string(data)))
+
+	key := ed25519.NewKeyFromSeed(mustHex(t, coseWGKey11))
+	signer, err := NewSigner(AlgorithmEdDSA, key)
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("This is the content."))
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, 0))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("11")))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, published, b)
+
+	require.NoError(t, msg.AddCounterSignature0(signer))
+	b, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	decoded := dec.(*Sign1Message)
+	require.NoError(t, decoded.VerifyCounterSignature0(verifier))
+
+	// Version 1 countersignature of RFC 8152 section 4.5 does not cover the message signature:
+	// ["CounterSignature0", h'A201270300', h'', 'This is the content.']
+	toBeSigned := mustHex(t, "84"+"71436f756e7465725369676e617475726530"+"45a201270300"+"40"+
+		"54546869732069732074686520636f6e74656e742e")
+	signature, err := decoded.Headers.Get(HeaderCounterSignature0)
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), toBeSigned, signature.([]byte)))
+	assert.Equal(t, ed25519.Sign(key, toBeSigned), signature)
+}
+
+// TestSign1Message_CounterSignature0Vector detects changes of the encoding generated by this package.
+func TestSign1Message_CounterSignature0Vector(t *testing.T) {
+	enc, err := NewEncoding(WithDeterministicSigning([]byte("go-cose test vectors")))
+	require.NoError(t, err)
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte("ecdsa256")))
+	counterSigner, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("This is the content."))
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.AddCounterSignature0(counterSigner))
+	b, err := enc.Encode(msg)
+	require.NoError(t, err)
+
+	path := filepath.Join("testdata", "vectors", "sign1-countersignature0.hex")
+	if *updateVectors {
+		require.NoError(t, os.WriteFile(path, []byte(hex.EncodeToString(b)+"\n"), 0o600))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test with -update-vectors to generate test vectors")
+	assert.Equal(t, strings.TrimSpace(string(expected)), hex.EncodeToString(b))
+
+	counterVerifier, err := counterSigner.ToVerifier()
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	assert.NoError(t, dec.(*Sign1Message).VerifyCounterSignature0(counterVerifier))
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"math"
+	"time"
+)
+
+// CWT claim keys (RFC 8392)
+const (
+	ClaimIssuer     = 1
+	ClaimSubject    = 2
+	ClaimAudience   = 3
+	ClaimExpiration = 4
+	ClaimNotBefore  = 5
+	ClaimIssuedAt   = 6
+	ClaimCWTID      = 7
+)
+
+// Claims represents CWT claims by claim key, decoded integer claim keys are int64.
+type Claims map[interface{}]interface{}
+
+// isCWTContentType reports whether the content type header value is CWT.
+func isCWTContentType(ct interface{}) bool {
+	switch v := ct.(type) {
+	case int64:
+		return v == MessageTagCWT
+	case string:
+		return v == "application/cwt"
+	}
+	return false
+}
+
+// validateClaims validates the CWT claims times of the verified message payload.
+func (c *Config) validateClaims(e *Encoding, headers *Headers, payload []byte) error {
+	if err := c.validateExpiry(headers); err != nil {
+		return err
+	}
+	if c == nil || (c.ValidateSigningTime == nil && !c.RequireCWTClaims) {
+		return nil
+	}
+
+	var claims map[interface{}]interface{}
+	if c.CWTPayload || isCWTContentType(getHeaderValue(headers, HeaderContentType)) {
+		if err := e.unmarshal(stageClaims, payload, &claims); err != nil {
+			claims = nil
+		}
+	}
+	if claims == nil {
+		if c.RequireCWTClaims {
+			return ErrMissingCWTClaims
+		}
+		return nil
+	}
+
+	now := time.Now()
+	if c.CurrentTime != nil {
+		now = c.CurrentTime()
+	}
+	exp := cwtNumericDate(claims[int64(ClaimExpiration)])
+	nbf := cwtNumericDate(claims[int64(ClaimNotBefore)])
+	iat := cwtNumericDate(claims[int64(ClaimIssuedAt)])
+
+	if !exp.IsZero() && now.After(exp.Add(c.TimeLeeway)) {
+		return ErrTokenExpired
+	}
+	if !nbf.IsZero() && now.Add(c.TimeLeeway).Before(nbf) {
+		return ErrTokenNotYetValid
+	}
+	if !iat.IsZero() && now.Add(c.TimeLeeway).Before(iat) {
+		return ErrTokenNotYetValid
+	}
+
+	if c.ValidateSigningTime != nil {
+		return c.ValidateSigningTime(iat, exp, headers)
+	}
+	return nil
+}
+
+// cwtNumericDate converts the CWT NumericDate claim value to time, zero time is returned for invalid values.
+func cwtNumericDate(v interface{}) time.Time {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0)
+	case uint64:
+		if t <= math.MaxInt64 {
+			return time.Unix(int64(t), 0)
+		}
+	case float64:
+		if !math.IsNaN(t) && !math.IsInf(t, 0) {
+			sec, frac := math.Modf(t)
+			return time.Unix(int64(sec), int64(frac*1e9))
+		}
+	}
+	return time.Time{}
+}
+
+func getHeaderValue(headers *Headers, key interface{}) interface{} {
+	if headers == nil {
+		return nil
+	}
+	v, _, _ := headers.Lookup(key)
+	return v
+}
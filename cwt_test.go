@@ -0,0 +1,100 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ValidateSigningTime(t *testing.T) {
+	b, err := hex.DecodeString(dgcTestMessage)
+	require.NoError(t, err)
+
+	data, err := base64.StdEncoding.DecodeString(dgcTestCertificate)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(data)
+	require.NoError(t, err)
+
+	errCertificateValidity := errors.New("signed outside certificate validity")
+	var iat, exp time.Time
+	config := dgcTestConfig(t)
+	config.CWTPayload = true
+	config.ValidateSigningTime = func(i, e time.Time, headers *Headers) error {
+		iat, exp = i, e
+		if i.Before(cert.NotBefore) || i.After(cert.NotAfter) {
+			return errCertificateValidity
+		}
+		return nil
+	}
+
+	now := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	config.CurrentTime = func() time.Time { return now }
+	_, err = StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1623744539, 0), iat)
+	assert.Equal(t, time.Unix(1655280539, 0), exp)
+
+	now = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	now = time.Unix(1655280539, 0).Add(time.Minute)
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	config.TimeLeeway = 5 * time.Minute
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+	config.TimeLeeway = 0
+
+	now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrTokenNotYetValid)
+
+	now = time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	cert.NotBefore = time.Date(2021, 6, 20, 0, 0, 0, 0, time.UTC)
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, errCertificateValidity)
+}
+
+func TestConfig_RequireCWTClaims(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("not claims"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	config := &Config{
+		GetVerifiers:        staticVerifier(t, signer),
+		ValidateSigningTime: func(iat, exp time.Time, headers *Headers) error { return nil },
+		CWTPayload:          true,
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+
+	config.RequireCWTClaims = true
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrMissingCWTClaims)
+
+	// {4: 1000} with CWT content type
+	msg.SetContent(mustHex(t, "a1041903e8"))
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, int64(MessageTagCWT)))
+	b, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	config.CWTPayload = false
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"io"
+	"math"
+)
+
+// minDecoderRead is the minimum free buffer space for reading messages
+const minDecoderRead = 4096
+
+// maxItemLength is the maximum length of byte and text strings, arrays and maps scanned by MessageDecoder
+const maxItemLength = math.MaxInt32
+
+// defaultMaxDecoderMessageSize is the default size limit of messages read by MessageDecoder
+const defaultMaxDecoderMessageSize = 16 << 20
+
+// MessageDecoder decodes consecutive COSE messages read from a reader.
+type MessageDecoder struct {
+	e       *Encoding
+	config  *Config
+	r       io.Reader
+	buf     []byte
+	readErr error
+	maxSize int
+	scanner itemScanner
+}
+
+// DecodeFrom returns a decoder of the consecutive COSE messages read from r,
+// each message is decoded with DecodeFirst. Messages are limited to 16 MiB by default.
+func (e *Encoding) DecodeFrom(r io.Reader, config *Config) *MessageDecoder {
+	return &MessageDecoder{e: e, config: config, r: r, maxSize: defaultMaxDecoderMessageSize}
+}
+
+// SetMaxMessageSize limits the size of the decoded messages, Decode fails with ErrMessageTooLarge
+// as soon as the message is known to be larger. Size 0 or less removes the limit.
+func (d *MessageDecoder) SetMaxMessageSize(size int) {
+	d.maxSize = size
+}
+
+// Decode decodes the next message, io.EOF is returned when all messages are decoded.
+//
+// As with Decode the message is returned with verification errors, the next call
+// decodes the following message. Malformed messages can not be skipped.
+func (d *MessageDecoder) Decode() (Message, error) {
+	for {
+		if len(d.buf) == 0 && d.readErr != nil {
+			return nil, d.readErr
+		}
+		if len(d.buf) > 0 {
+			// The message is decoded only after the data it needs has been read
+			need, done := d.scanner.scan(d.buf)
+			if d.maxSize > 0 && need > d.maxSize {
+				return nil, ErrMessageTooLarge{Size: d.maxSize}
+			}
+			if done || d.readErr == io.EOF {
+				msg, rest, err := d.e.DecodeFirst(d.buf, d.config)
+				if rest != nil {
+					d.buf = rest
+					d.scanner = itemScanner{}
+					return msg, err
+				}
+				return nil, err
+			}
+			if d.readErr != nil {
+				return nil, d.readErr
+			}
+		}
+		d.read()
+	}
+}
+
+// read appends data from the reader to the buffer. Decoded messages refer to the buffer
+// so the data before the buffer start is never overwritten.
+func (d *MessageDecoder) read() {
+	if cap(d.buf)-len(d.buf) < minDecoderRead {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+minDecoderRead)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	d.readErr = err
+}
+
+// itemScanner finds the end of the first CBOR data item in data read so far,
+// it keeps its position between calls so the data is scanned only once.
+type itemScanner struct {
+	off int
+	// pending holds the number of remaining items of the open arrays and maps, -1 if indefinite length
+	pending []int
+}
+
+// scan returns the length of the data needed for the first data item or its next head to be complete,
+// done is true if the data item is complete or malformed and should be given to the decoder.
+func (s *itemScanner) scan(data []byte) (need int, done bool) {
+	for {
+		if s.off >= len(data) {
+			return s.off + 1, false
+		}
+		head := data[s.off]
+		major, ai := head>>5, head&0x1f
+		switch {
+		case head == 0xff:
+			if len(s.pending) == 0 || s.pending[len(s.pending)-1] != -1 {
+				return 0, true
+			}
+			s.pending = s.pending[:len(s.pending)-1]
+			s.off++
+		case ai == 31:
+			if major < 2 || major == 6 {
+				return 0, true
+			}
+			s.pending = append(s.pending, -1)
+			s.off++
+			continue
+		default:
+			if ai >= 24 && ai <= 27 && len(data)-s.off < 1+1<<(ai-24) {
+				return s.off + 1 + 1<<(ai-24), false
+			}
+			arg, n, err := parseHeadArgument(data[s.off:])
+			if err != nil || major >= 2 && major <= 5 && arg > maxItemLength {
+				return 0, true
+			}
+			switch major {
+			case 2, 3:
+				end := s.off + n + int(arg)
+				if end > len(data) {
+					return end, false
+				}
+				s.off = end
+			case 4, 5:
+				s.off += n
+				if arg > 0 {
+					s.pending = append(s.pending, int(arg)*int(major-3))
+					continue
+				}
+			case 6:
+				s.off += n
+				continue
+			default:
+				s.off += n
+			}
+		}
+		// The data item is complete, it completes the enclosing definite length arrays and maps
+		for len(s.pending) > 0 && s.pending[len(s.pending)-1] > 0 {
+			s.pending[len(s.pending)-1]--
+			if s.pending[len(s.pending)-1] > 0 {
+				break
+			}
+			s.pending = s.pending[:len(s.pending)-1]
+		}
+		if len(s.pending) == 0 {
+			return s.off, true
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageDecoder_Decode(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	var stream []byte
+	for _, content := range []string{"first", "second", string(bytes.Repeat([]byte("x"), 3*minDecoderRead))} {
+		b, err := StdEncoding.Encode(NewSign1Message().WithContent([]byte(content)).WithSigner(signer))
+		require.NoError(t, err)
+		stream = append(stream, b...)
+	}
+
+	for _, r := range []io.Reader{bytes.NewReader(stream), iotest.OneByteReader(bytes.NewReader(stream))} {
+		d := StdEncoding.DecodeFrom(r, &Config{GetVerifiers: staticVerifier(t, signer)})
+		var contents []string
+		for {
+			msg, err := d.Decode()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			contents = append(contents, string(msg.GetContent()))
+		}
+		require.Len(t, contents, 3)
+		// Decoded messages are not overwritten by reading the following messages
+		assert.Equal(t, []string{"first", "second"}, contents[:2])
+		assert.Len(t, contents[2], 3*minDecoderRead)
+	}
+}
+
+func TestMessageDecoder_Errors(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	b, err := StdEncoding.Encode(NewSign1Message().WithContent([]byte("test")).WithSigner(signer))
+	require.NoError(t, err)
+
+	// Verification errors do not stop decoding
+	other := MustNewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	d := StdEncoding.DecodeFrom(bytes.NewReader(append(append([]byte{}, b...), b...)), &Config{GetVerifiers: staticVerifier(t, other)})
+	for i := 0; i < 2; i++ {
+		msg, err := d.Decode()
+		assert.ErrorIs(t, err, ErrVerification)
+		assert.Equal(t, []byte("test"), msg.GetContent())
+	}
+	_, err = d.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	// Truncated message at the end of the input
+	d = StdEncoding.DecodeFrom(bytes.NewReader(append(append([]byte{}, b...), b[:10]...)), &Config{GetVerifiers: staticVerifier(t, signer)})
+	_, err = d.Decode()
+	require.NoError(t, err)
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorAs(t, err, &ErrInvalidMessageStructure{})
+
+	// Read errors are returned
+	d = StdEncoding.DecodeFrom(iotest.TimeoutReader(bytes.NewReader(b[:10])), nil)
+	_, err = d.Decode()
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+}
+
+func TestMessageDecoder_ScanItem(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	message, err := StdEncoding.Encode(NewSign1Message().WithContent(bytes.Repeat([]byte("x"), 1000)).WithSigner(signer))
+	require.NoError(t, err)
+	items := [][]byte{
+		message,
+		mustHex(t, "00"),
+		mustHex(t, "1bffffffffffffffff"),
+		mustHex(t, "80"),
+		mustHex(t, "a0"),
+		mustHex(t, "c11a5f5e1000"),
+		mustHex(t, "83018202039f0405ff"),
+		mustHex(t, "bf6161015f42010243030405ffff"),
+		mustHex(t, "a201a1024103029f9fffff"),
+		mustHex(t, "7f6161ff"),
+		mustHex(t, "f93c00"),
+	}
+	for _, item := range items {
+		data := append(append([]byte{}, item...), 0x00)
+		var s itemScanner
+		for i := 1; i < len(item); i++ {
+			need, done := s.scan(data[:i])
+			require.False(t, done, "%x %d", item, i)
+			assert.Greater(t, need, i, "%x %d", item, i)
+			assert.LessOrEqual(t, need, len(item), "%x %d", item, i)
+		}
+		need, done := s.scan(data)
+		assert.True(t, done, "%x", item)
+		assert.Equal(t, len(item), need, "%x", item)
+	}
+
+	// Byte string heads give the length of the whole string
+	var s itemScanner
+	need, done := s.scan(message[:10])
+	assert.False(t, done)
+	assert.Greater(t, need, 1000)
+
+	// Malformed items are given to the decoder
+	for _, malformed := range []string{"ff", "1c", "1f", "9f01fe", "81ff"} {
+		var s itemScanner
+		_, done := s.scan(mustHex(t, malformed))
+		assert.True(t, done, malformed)
+	}
+}
+
+func TestMessageDecoder_MaxMessageSize(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	b, err := StdEncoding.Encode(NewSign1Message().WithContent(bytes.Repeat([]byte("x"), 2000)).WithSigner(signer))
+	require.NoError(t, err)
+
+	d := StdEncoding.DecodeFrom(bytes.NewReader(b), &Config{GetVerifiers: staticVerifier(t, signer)})
+	d.SetMaxMessageSize(len(b))
+	_, err = d.Decode()
+	assert.NoError(t, err)
+
+	r := &countingReader{r: iotest.OneByteReader(bytes.NewReader(b))}
+	d = StdEncoding.DecodeFrom(r, nil)
+	d.SetMaxMessageSize(1024)
+	_, err = d.Decode()
+	assert.Equal(t, ErrMessageTooLarge{Size: 1024}, err)
+	// The size is known from the payload head
+	assert.Less(t, r.n, 100)
+
+	// Items of an endless indefinite length array
+	endless := io.MultiReader(bytes.NewReader([]byte{0xd2, 0x9f}), &repeatReader{b: 0x00})
+	d = StdEncoding.DecodeFrom(endless, nil)
+	d.SetMaxMessageSize(1 << 16)
+	_, err = d.Decode()
+	assert.Equal(t, ErrMessageTooLarge{Size: 1 << 16}, err)
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// repeatReader reads the byte endlessly.
+type repeatReader struct {
+	b byte
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.b
+	}
+	return len(p), nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// maxDeterministicNesting is the maximum nesting level of arrays, maps and tags checked
+const maxDeterministicNesting = 32
+
+// CheckDeterministicEncoding checks that the data is a single CBOR data item using
+// Core Deterministic Encoding (RFC 8949 section 4.2.1) and returns ErrNonDeterministicEncoding
+// with the byte offset of the first violation.
+func CheckDeterministicEncoding(data []byte) error {
+	c := deterministicChecker{data: data}
+	n, err := c.item(0, 0)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return errors.New("cbor: extraneous data after the data item")
+	}
+	return nil
+}
+
+type deterministicChecker struct {
+	data []byte
+}
+
+func (c *deterministicChecker) violation(off int, reason string) error {
+	return ErrNonDeterministicEncoding{Offset: off, Reason: reason}
+}
+
+// item checks the data item at the offset and returns the offset of the next data item.
+func (c *deterministicChecker) item(off, depth int) (int, error) {
+	if off >= len(c.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if depth > maxDeterministicNesting {
+		return 0, errors.New("cbor: exceeded maximum nesting level")
+	}
+	major := c.data[off] >> 5
+	ai := c.data[off] & 0x1f
+	if ai == 31 {
+		if major >= 2 && major <= 5 {
+			return 0, c.violation(off, "indefinite length")
+		}
+		return 0, errors.New("cbor: invalid indefinite length item")
+	}
+	arg, n, err := parseHeadArgument(c.data[off:])
+	if err != nil {
+		return 0, err
+	}
+	next := off + n
+
+	if major == 7 {
+		return next, c.checkSimple(off, ai, arg)
+	}
+	if !minimalArgument(ai, arg) {
+		return 0, c.violation(off, "non-minimal argument encoding")
+	}
+
+	switch major {
+	case 2, 3:
+		if arg > uint64(len(c.data)-next) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return next + int(arg), nil
+	case 4:
+		for i := uint64(0); i < arg; i++ {
+			if next, err = c.item(next, depth+1); err != nil {
+				return 0, err
+			}
+		}
+		return next, nil
+	case 5:
+		var prev []byte
+		for i := uint64(0); i < arg; i++ {
+			start := next
+			if next, err = c.item(next, depth+1); err != nil {
+				return 0, err
+			}
+			key := c.data[start:next]
+			if prev != nil && bytes.Compare(prev, key) >= 0 {
+				return 0, c.violation(start, "map keys not in bytewise lexicographic order")
+			}
+			prev = key
+			if next, err = c.item(next, depth+1); err != nil {
+				return 0, err
+			}
+		}
+		return next, nil
+	case 6:
+		return c.item(next, depth+1)
+	default:
+		return next, nil
+	}
+}
+
+// checkSimple checks that the simple value or float uses the shortest encoding.
+func (c *deterministicChecker) checkSimple(off int, ai byte, arg uint64) error {
+	switch ai {
+	case 24:
+		if arg < 32 {
+			return errors.New("cbor: invalid simple value encoding")
+		}
+	case 26:
+		f := math.Float32frombits(uint32(arg))
+		if f != f || fitsFloat16(uint32(arg)) {
+			return c.violation(off, "float not in shortest form")
+		}
+	case 27:
+		f := math.Float64frombits(arg)
+		if f != f || float64(float32(f)) == f {
+			return c.violation(off, "float not in shortest form")
+		}
+	}
+	return nil
+}
+
+// minimalArgument reports whether the argument is encoded in the fewest bytes.
+func minimalArgument(ai byte, arg uint64) bool {
+	switch ai {
+	case 24:
+		return arg >= 24
+	case 25:
+		return arg > math.MaxUint8
+	case 26:
+		return arg > math.MaxUint16
+	case 27:
+		return arg > math.MaxUint32
+	default:
+		return true
+	}
+}
+
+// fitsFloat16 reports whether the float32 value is exactly representable as float16.
+func fitsFloat16(bits uint32) bool {
+	exp := int(bits>>23&0xff) - 127
+	mant := bits & 0x7fffff
+	switch {
+	case bits&0x7fffffff == 0:
+		return true
+	case exp == 128:
+		// Infinity, NaN is never in shortest form as float32
+		return mant == 0
+	case exp >= -14 && exp <= 15:
+		return mant&0x1fff == 0
+	case exp >= -24 && exp < -14:
+		// Subnormal float16 values are multiples of 2^-24
+		sig := uint32(1)<<23 | mant
+		return sig&(uint32(1)<<uint(-(exp+1))-1) == 0
+	default:
+		return false
+	}
+}
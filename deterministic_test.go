@@ -0,0 +1,71 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDeterministicEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		offset int
+	}{
+		{name: "protected headers", data: "a10126", offset: -1},
+		{name: "nested", data: "d28443a10126a2041831182082f93c00f97e00447465737440", offset: -1},
+		{name: "float32", data: "fa47c35000", offset: -1},
+		{name: "float64", data: "fb3ff199999999999a", offset: -1},
+		{name: "two byte length", data: "590003616263", offset: 0},
+		{name: "one byte length", data: "5803616263", offset: 0},
+		{name: "nested non-minimal integer", data: "82011817", offset: 2},
+		{name: "non-minimal tag", data: "d81201", offset: 0},
+		{name: "reversed map keys", data: "a20441310126", offset: 4},
+		{name: "length-first map keys", data: "a22000181800", offset: 3},
+		{name: "duplicate map keys", data: "a201010102", offset: 3},
+		{name: "indefinite array", data: "8201" + "9f01ff", offset: 2},
+		{name: "indefinite bstr", data: "5f4101ff", offset: 0},
+		{name: "float32 fits float16", data: "fa3f800000", offset: 0},
+		{name: "float64 fits float32", data: "fb3ff0000000000000", offset: 0},
+		{name: "float NaN", data: "fb7ff8000000000000", offset: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDeterministicEncoding(mustHex(t, tt.data))
+			if tt.offset < 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var derr ErrNonDeterministicEncoding
+			require.ErrorAs(t, err, &derr)
+			assert.Equal(t, tt.offset, derr.Offset)
+		})
+	}
+}
+
+func TestCheckDeterministicEncoding_Malformed(t *testing.T) {
+	for _, data := range []string{"", "5900", "a201", "0101", "ff", "1c", "f818"} {
+		err := CheckDeterministicEncoding(mustHex(t, data))
+		require.Error(t, err, data)
+		_, ok := err.(ErrNonDeterministicEncoding)
+		assert.False(t, ok, data)
+	}
+}
+
+func TestFitsFloat16(t *testing.T) {
+	assert.True(t, fitsFloat16(0x00000000))
+	assert.True(t, fitsFloat16(0x80000000))
+	assert.True(t, fitsFloat16(0x3f800000))
+	assert.True(t, fitsFloat16(0x477fe000))
+	assert.False(t, fitsFloat16(0x477ff000))
+	assert.True(t, fitsFloat16(0x33800000))
+	assert.False(t, fitsFloat16(0x33000000))
+	assert.True(t, fitsFloat16(0x38800000))
+	assert.False(t, fitsFloat16(0x3f800001))
+	assert.True(t, fitsFloat16(0x7f800000))
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/zzdats/go-cose"
+	"github.com/zzdats/go-cose/cosetest"
+)
+
+// dgcExpectedErrors lists the DGC test cases failing for other reasons than signature verification.
+var dgcExpectedErrors = map[string]cosetest.ErrorClass{
+	"ES/2DCode/raw/1501.json":     cosetest.InvalidStructure,
+	"ES/2DCode/raw/1502.json":     cosetest.InvalidStructure,
+	"ES/2DCode/raw/1503.json":     cosetest.InvalidStructure,
+	"ES/2DCode/raw/401.json":      cosetest.Is(cose.ErrInvalidEllipticCurve),
+	"ES/2DCode/raw/402.json":      cosetest.Is(cose.ErrInvalidEllipticCurve),
+	"ES/2DCode/raw/403.json":      cosetest.Is(cose.ErrInvalidEllipticCurve),
+	"common/2DCode/raw/CBO2.json": cosetest.InvalidStructure,
+}
+
+func TestDgc(t *testing.T) {
+	if os.Getenv("TEST_DGC") != "true" {
+		t.Skip("Skipping DGC test suite")
+	}
+	cosetest.RunCorpus(t, "test-data/dgc", resolveDgcVerifiers, dgcExpectedErrors)
+}
+
+// resolveDgcVerifiers returns the verifier of the test certificate if the message kid matches
+// the certificate kid, the protected kid takes precedence over the unprotected one.
+func resolveDgcVerifiers(tc *cosetest.TestCase, headers *cose.Headers) ([]*cose.Verifier, error) {
+	kid, ok, err := headers.ProtectedOnly().Lookup(cose.HeaderKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if kid, _, err = headers.Unprotected().Lookup(cose.HeaderKeyID); err != nil {
+			return nil, err
+		}
+	}
+	k, ok := kid.([]byte)
+	if !ok || len(k) == 0 {
+		return nil, errors.New("kid missing")
+	}
+	if tc.Certificate == nil {
+		return nil, errors.New("certificate missing")
+	}
+	// DGC kid is the first 8 bytes of the certificate SHA-256 fingerprint
+	fingerprint := sha256.Sum256(tc.Certificate.Raw)
+	if !bytes.Equal(k, fingerprint[:8]) {
+		return nil, nil
+	}
+
+	algRaw, err := headers.GetProtected(cose.HeaderAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	alg, ok := algRaw.(string)
+	if !ok {
+		return nil, errors.New("alg not string")
+	}
+	verifier, err := cose.NewVerifier(cose.Algorithm(alg), tc.Certificate.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*cose.Verifier{verifier}, nil
+}
@@ -5,54 +5,12 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
-	"errors"
-	"os"
-	"path/filepath"
-	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-var dgcKnownIssues = []string{
-	"ES/2DCode/raw/1501.json",     // invalid CBOR structure
-	"ES/2DCode/raw/1502.json",     // invalid CBOR structure
-	"ES/2DCode/raw/1503.json",     // invalid CBOR structure
-	"ES/2DCode/raw/401.json",      // invalid elliptic curve
-	"ES/2DCode/raw/402.json",      // invalid elliptic curve
-	"ES/2DCode/raw/403.json",      // invalid elliptic curve
-	"common/2DCode/raw/CBO2.json", // invalid CBOR structure
-	"common/2DCode/raw/CO28.json", // invalid CBOR tag 61
-	"common/2DCode/raw/CO22.json", // INVALID: KID in protected header not correct, KID in unprotected header correct
-	"common/2DCode/raw/CO23.json", // INVALID: KID in protected header not present, KID in unprotected header not correct
-}
-
-func TestDgc(t *testing.T) {
-	if os.Getenv("TEST_DGC") != "true" {
-		t.Skip("Skipping DGC test suite")
-	}
-	err := filepath.Walk("test-data/dgc",
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if filepath.Ext(path) != ".json" {
-				return nil
-			}
-			t.Run(path, func(t *testing.T) {
-				for _, k := range dgcKnownIssues {
-					if strings.HasSuffix(path, k) {
-						t.Skip()
-					}
-				}
-				testDgcTestCase(t, path)
-			})
-			return nil
-		})
-	require.NoError(t, err)
-}
-
 func parseKey(certData string) (crypto.PublicKey, error) {
 	data, err := base64.StdEncoding.DecodeString(certData)
 	if err != nil {
@@ -66,51 +24,44 @@ func parseKey(certData string) (crypto.PublicKey, error) {
 	return cert.PublicKey, nil
 }
 
-func testDgcTestCase(t *testing.T, path string) {
-	data, err := os.ReadFile(path)
+// DGC test certificate and COSE_Sign1 message issued by Latvia
+const (
+	dgcTestCertificate = `MIICEjCCAbmgAwIBAgIUTExVw4anJr4PZhNn3w8UgGwoQGUwCgYIKoZIzj0EAwIwZjELMAkGA1UEBhMCTFYxLTArBgNVBAoMJE5hY2lvbsOEwoFsYWlzIFZlc2Vsw4TCq2JhcyBkaWVuZXN0czENMAsGA1UECwwEQ1NDQTEZMBcGA1UEAwwQQ1NDQSBER0MgTFYgVGVzdDAeFw0yMTA1MTMwNzM2MTZaFw0yNTA1MTIwNzM2MTZaMGYxCzAJBgNVBAYTAkxWMS0wKwYDVQQKDCROYWNpb27DhMKBbGFpcyBWZXNlbMOEwqtiYXMgZGllbmVzdHMxDTALBgNVBAsMBENTQ0ExGTAXBgNVBAMMEENTQ0EgREdDIExWIFRlc3QwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAREAeqbcI/ljWtS/UAvYhF4ubd1RQpOd/NrgLunZb3HAbBW/8h1dxPr1DSWQmxxXlGR/TitYtL1ZuxeRWfl8bGDo0UwQzASBgNVHRMBAf8ECDAGAQH/AgEAMA4GA1UdDwEB/wQEAwIBBjAdBgNVHQ4EFgQUTP6CwP1AoJEnvrISXSiv4q+Q0U0wCgYIKoZIzj0EAwIDRwAwRAIgU3W1knii0mIcfFBTzE3c0GjL8zTg8oSaUJwrSKq0eVwCIFfT95WJ2qIQA9a7abobrHLmnYCP+K/lbtwQ2tNErpc3`
+	dgcTestMessage     = `d28443a10126a104484dfc0b3070d7230b59015ca401624c56041a62a9939b061a60c8601b390103a101a46376657265312e302e30636e616da462666e67c4b6656c70697363666e74664b454c50495362676e6a4dc481727469c586c5a163676e74674d415254494e5363646f626a313939332d30392d3133617481aa62746769383430353339303036627474684c50363436342d34626e6d7832412a5354415220466f72746974756465204b697420322e30202853696e6761706f72652048534129203f20504352206b697462736374323032312d30362d31325430393a30303a30305a62647274323032312d30362d31325430393a30303a30305a62747269323630343135303030627463634e564462636f624c5662697378204e6163696f6ec4816c61697320766573656cc4ab626173206469656e65737473626369782f75726e3a757663693a30313a6c763a3363653362623365383033346364376561653236646639656435636130383962584049232f3562692ca90585994d02e0131058e9800797449e5fbc4ba323a339adc4895872959e813ae34e4dcb9e0157113f97c6307db2bbe54b66767482fe571363`
+)
+
+func dgcTestConfig(t *testing.T) *Config {
+	cert, err := parseKey(dgcTestCertificate)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(AlgorithmES256, cert)
 	require.NoError(t, err)
-	var j map[string]interface{}
-	require.NoError(t, json.Unmarshal(data, &j))
 
-	if j["COSE"] == nil || len(j["COSE"].(string)) == 0 || j["EXPECTEDRESULTS"].(map[string]interface{})["EXPECTEDVERIFY"] == nil {
-		t.Skip()
+	return &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return []*Verifier{verifier}, nil
+		},
 	}
+}
 
-	b, err := hex.DecodeString(j["COSE"].(string))
-	require.NoError(t, err)
+// TestDgc_CWTTag checks that CWT tagged certificates such as the DGC common/2DCode/raw/CO28 test case
+// are decoded as COSE_Sign1 messages, the CWT tag is unwrapped unless Config.UnwrapCWTTag is false.
+func TestDgc_CWTTag(t *testing.T) {
+	for _, prefix := range []string{"d83d", "d9d9f7d83d"} {
+		b, err := hex.DecodeString(prefix + dgcTestMessage)
+		require.NoError(t, err)
 
-	dec, err := StdEncoding.Decode(b, &Config{
-		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
-			kid, err := headers.Get(HeaderKeyID)
-			if err != nil {
-				return nil, err
-			}
-			if kid == nil || len(kid.([]byte)) == 0 {
-				return nil, errors.New("kid missing")
-			}
-			algRaw, err := headers.GetProtected(HeaderAlgorithm)
-			if err != nil {
-				return nil, err
-			}
-			cert, err := parseKey(j["TESTCTX"].(map[string]interface{})["CERTIFICATE"].(string))
-			if err != nil {
-				return nil, err
-			}
-			if alg, ok := algRaw.(string); ok {
-				verifier, err := NewVerifier(Algorithm(alg), cert)
-				if err != nil {
-					return nil, err
-				}
-				return []*Verifier{verifier}, nil
-			}
-			return nil, errors.New("alg not string")
-		},
-	})
-	if !j["EXPECTEDRESULTS"].(map[string]interface{})["EXPECTEDVERIFY"].(bool) {
-		require.ErrorIs(t, err, ErrVerification)
-	} else {
+		dec, err := StdEncoding.Decode(b, dgcTestConfig(t))
 		require.NoError(t, err)
-	}
+		require.NotEmpty(t, dec.GetContent())
+		assert.Equal(t, uint64(MessageTagSign1), dec.GetMessageTag())
+
+		dec, err = StdEncoding.DecodeWithExternal(b, nil, dgcTestConfig(t))
+		require.NoError(t, err)
+		assert.IsType(t, &Sign1Message{}, dec)
 
-	require.NotEmpty(t, dec.GetContent())
+		config := dgcTestConfig(t)
+		config.UnwrapCWTTag = Bool(false)
+		_, err = StdEncoding.Decode(b, config)
+		assert.ErrorIs(t, err, ErrUnsupportedMessageTag{MessageTagCWT})
+	}
 }
@@ -0,0 +1,188 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+// COSE_Key labels and values for EC2 keys
+const (
+	keyLabelKeyType = 1
+	keyLabelCurve   = -1
+	keyLabelX       = -2
+	keyLabelY       = -3
+
+	keyTypeEC2 = 2
+)
+
+// HeaderEphemeralKey is the label of the ephemeral public key header
+const HeaderEphemeralKey = int64(-1)
+
+// ellipticCurveID returns the COSE elliptic curve identifier.
+func ellipticCurveID(curve elliptic.Curve) int64 {
+	switch curve {
+	case elliptic.P256():
+		return 1
+	case elliptic.P384():
+		return 2
+	case elliptic.P521():
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ellipticCurveByID returns the elliptic curve for the COSE elliptic curve identifier.
+func ellipticCurveByID(id int64) elliptic.Curve {
+	switch id {
+	case 1:
+		return elliptic.P256()
+	case 2:
+		return elliptic.P384()
+	case 3:
+		return elliptic.P521()
+	default:
+		return nil
+	}
+}
+
+// ECDHESRecipient represents a COSE_recipient of an encrypted message
+// using ephemeral-static ECDH with HKDF-SHA256 direct key derivation.
+type ECDHESRecipient struct {
+	Headers   *Headers
+	publicKey *ecdsa.PublicKey
+	alg       *algorithm
+}
+
+// NewECDHESRecipient creates a new ECDH-ES + HKDF-256 recipient for the static public key.
+func NewECDHESRecipient(key *ecdsa.PublicKey) (*ECDHESRecipient, error) {
+	if key == nil {
+		return nil, errors.New("key can not be nil")
+	}
+	if ellipticCurveID(key.Curve) == 0 {
+		return nil, ErrInvalidEllipticCurve
+	}
+
+	return &ECDHESRecipient{
+		Headers:   NewHeaders(),
+		publicKey: key,
+		alg:       getAlg(string(AlgorithmECDHESHKDF256)),
+	}, nil
+}
+
+func (r *ECDHESRecipient) directKey() bool {
+	return true
+}
+
+func (r *ECDHESRecipient) encrypt(e *Encoding, contentAlg *algorithm, _ []byte) (*recipientMessage, []byte, error) {
+	ephemeral, err := ecdsa.GenerateKey(r.publicKey.Curve, e.rand)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := MergeHeaders(r.Headers, nil)
+	if err = h.SetProtected(HeaderAlgorithm, r.alg.Value); err != nil {
+		return nil, nil, err
+	}
+	if err = h.Set(HeaderEphemeralKey, encodeEC2Key(&ephemeral.PublicKey)); err != nil {
+		return nil, nil, err
+	}
+	ph, err := e.marshalProtected(h.protected)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cek, err := ecdhesKey(e, r.alg, contentAlg, ephemeral, r.publicKey, ph)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &recipientMessage{
+		Protected:   ph,
+		Unprotected: h.unprotected,
+		Ciphertext:  []byte{},
+	}, cek, nil
+}
+
+// deriveECDHESKey derives the content encryption key from the recipient private key
+// and the ephemeral public key in the recipient headers.
+func deriveECDHESKey(e *Encoding, alg, contentAlg *algorithm, key *ecdsa.PrivateKey, headers *Headers, protected []byte) ([]byte, error) {
+	epk, err := headers.Get(HeaderEphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := decodeEC2Key(epk)
+	if err != nil {
+		return nil, err
+	}
+	return ecdhesKey(e, alg, contentAlg, key, pub, protected)
+}
+
+// ecdhesKey computes the ECDH shared secret and derives the key using the COSE_KDF_Context.
+func ecdhesKey(e *Encoding, alg, contentAlg *algorithm, key *ecdsa.PrivateKey, pub *ecdsa.PublicKey, protected []byte) ([]byte, error) {
+	if key.Curve != pub.Curve || !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrInvalidEllipticCurve
+	}
+	x, _ := key.Curve.ScalarMult(pub.X, pub.Y, key.D.Bytes())
+	secret := i2ospPad(x, curveByteSize(key.Curve))
+
+	context, err := e.marshal([]interface{}{
+		contentAlg.Value,
+		[]interface{}{nil, nil, nil},
+		[]interface{}{nil, nil, nil},
+		[]interface{}{contentAlg.KeySize, protected},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hkdf(alg.Hash, secret, nil, context, contentAlg.KeySize/8)
+}
+
+// encodeEC2Key encodes the public key as a COSE_Key.
+func encodeEC2Key(key *ecdsa.PublicKey) map[interface{}]interface{} {
+	size := curveByteSize(key.Curve)
+	return map[interface{}]interface{}{
+		int64(keyLabelKeyType): int64(keyTypeEC2),
+		int64(keyLabelCurve):   ellipticCurveID(key.Curve),
+		int64(keyLabelX):       i2ospPad(key.X, size),
+		int64(keyLabelY):       i2ospPad(key.Y, size),
+	}
+}
+
+// decodeEC2Key decodes the public key from a COSE_Key.
+func decodeEC2Key(v interface{}) (*ecdsa.PublicKey, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid COSE_Key")
+	}
+	if kty, ok := m[int64(keyLabelKeyType)].(int64); !ok || kty != keyTypeEC2 {
+		return nil, ErrUnsupportedKeyType
+	}
+	crv, _ := m[int64(keyLabelCurve)].(int64)
+	curve := ellipticCurveByID(crv)
+	if curve == nil {
+		return nil, ErrInvalidEllipticCurve
+	}
+	x, okX := m[int64(keyLabelX)].([]byte)
+	y, okY := m[int64(keyLabelY)].([]byte)
+	if !okX || !okY {
+		return nil, errors.New("invalid COSE_Key coordinates")
+	}
+
+	pub := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrInvalidEllipticCurve
+	}
+	return pub, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECDHESRecipient_EncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name  string
+		curve elliptic.Curve
+	}{
+		{name: "P-256", curve: elliptic.P256()},
+		{name: "P-384", curve: elliptic.P384()},
+		{name: "P-521", curve: elliptic.P521()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ecdsa.GenerateKey(tt.curve, rand.Reader)
+			require.NoError(t, err)
+
+			r, err := NewECDHESRecipient(&key.PublicKey)
+			require.NoError(t, err)
+			require.NoError(t, r.Headers.Set(HeaderKeyID, []byte("recipient")))
+
+			msg := NewEncryptMessage()
+			msg.SetContent([]byte("secret"))
+			msg.AddECDHESRecipient(r)
+
+			b, err := StdEncoding.Encode(msg)
+			require.NoError(t, err)
+
+			dec, err := StdEncoding.Decode(b, &Config{
+				GetDecryptKey: func(headers *Headers) (interface{}, error) {
+					kid, err := headers.Get(HeaderKeyID)
+					require.NoError(t, err)
+					assert.Equal(t, []byte("recipient"), kid)
+					return key, nil
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, msg.GetContent(), dec.GetContent())
+		})
+	}
+}
+
+func TestECDHESRecipient_WrongKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	r, err := NewECDHESRecipient(&key.PublicKey)
+	require.NoError(t, err)
+
+	msg := NewEncryptMessage()
+	msg.SetContent([]byte("secret"))
+	msg.AddECDHESRecipient(r)
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	_, err = StdEncoding.Decode(b, &Config{
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return other, nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrDecryption)
+}
+
+func TestECDHESRecipient_OnlyRecipient(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	r, err := NewECDHESRecipient(&key.PublicKey)
+	require.NoError(t, err)
+
+	msg := NewEncryptMessage()
+	msg.SetContent([]byte("secret"))
+	msg.AddECDHESRecipient(r)
+	require.NoError(t, msg.AddRecipient(AlgorithmA128KW, randomKey(t, 16), nil))
+
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrDirectRecipient)
+}
+
+func TestECDHESRecipient_InvalidKey(t *testing.T) {
+	r, err := NewECDHESRecipient(nil)
+	assert.Error(t, err)
+	assert.Nil(t, r)
+
+	key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	r, err = NewECDHESRecipient(&key.PublicKey)
+	assert.ErrorIs(t, err, ErrInvalidEllipticCurve)
+	assert.Nil(t, r)
+}
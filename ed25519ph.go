@@ -0,0 +1,28 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+// +build go1.20
+
+package cose
+
+import (
+	"crypto"
+	"crypto/ed25519"
+)
+
+const ed25519phSupported = true
+
+// signEd25519ph signs the SHA-512 hash of the message with Ed25519ph.
+func signEd25519ph(key ed25519.PrivateKey, hashed []byte) ([]byte, error) {
+	return key.Sign(nil, hashed, &ed25519.Options{Hash: crypto.SHA512})
+}
+
+// verifyEd25519ph verifies the Ed25519ph signature of the SHA-512 hash of the message.
+func verifyEd25519ph(key ed25519.PublicKey, hashed, sig []byte) error {
+	if err := ed25519.VerifyWithOptions(key, hashed, sig, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+		return ErrVerification
+	}
+	return nil
+}
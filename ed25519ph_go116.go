@@ -0,0 +1,23 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.20
+// +build !go1.20
+
+package cose
+
+import (
+	"crypto/ed25519"
+)
+
+// Ed25519ph requires Go 1.20 ed25519.Options
+const ed25519phSupported = false
+
+func signEd25519ph(key ed25519.PrivateKey, hashed []byte) ([]byte, error) {
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func verifyEd25519ph(key ed25519.PublicKey, hashed, sig []byte) error {
+	return ErrUnsupportedAlgorithm
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.20
+// +build go1.20
+
+package cose
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner_Ed25519phVector(t *testing.T) {
+	// RFC 8032 section 7.3 Ed25519ph test vector
+	key := ed25519.NewKeyFromSeed(mustHex(t, "833fe62409237b9d62ec77587520911e9a759cec1d19755b7da901b96dca3d42"))
+	assert.Equal(t, mustHex(t, "ec172b93ad5e563bf4932c70e1245034c35467ef2efd4d64ebf819683467e2bf"), []byte(key.Public().(ed25519.PublicKey)))
+	expected := mustHex(t, "98a70222f0b8121aa9d30f813d683f809e462b469c7ff87639499bb94e6dae41"+
+		"31f85042463c2a355a2003d062adf5aaa10b8c61e636062aaad11c2a26083406")
+
+	signer, err := NewSigner(AlgorithmEd25519ph, key)
+	require.NoError(t, err)
+	sig, err := signer.Sign(rand.Reader, []byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, sig)
+
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("abc"), sig))
+	assert.ErrorIs(t, verifier.Verify([]byte("abd"), sig), ErrVerification)
+}
+
+func TestEncoding_Ed25519ph(t *testing.T) {
+	key := getPrivateKey(t, "ed25519")
+	phSigner, err := NewSigner(AlgorithmEd25519ph, key)
+	require.NoError(t, err)
+	pureSigner, err := NewSigner(AlgorithmEdDSA, key)
+	require.NoError(t, err)
+	phVerifier, err := phSigner.ToVerifier()
+	require.NoError(t, err)
+	pureVerifier, err := pureSigner.ToVerifier()
+	require.NoError(t, err)
+	verifiers := func(v *Verifier) *Config {
+		return &Config{GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return []*Verifier{v}, nil
+		}}
+	}
+
+	encode := func(signer *Signer) []byte {
+		msg := NewSign1Message()
+		msg.SetContent([]byte("large payload"))
+		require.NoError(t, msg.SetSigner(signer))
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+		return b
+	}
+	ph, pure := encode(phSigner), encode(pureSigner)
+
+	dec, err := StdEncoding.Decode(ph, verifiers(phVerifier))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("large payload"), dec.GetContent())
+	alg, err := dec.(*Sign1Message).Headers.GetProtected(HeaderAlgorithm)
+	require.NoError(t, err)
+	assert.Equal(t, string(AlgorithmEd25519ph), alg)
+
+	_, err = StdEncoding.Decode(ph, verifiers(pureVerifier))
+	assert.ErrorIs(t, err, ErrVerification)
+	_, err = StdEncoding.Decode(pure, verifiers(phVerifier))
+	assert.ErrorIs(t, err, ErrVerification)
+
+	// Signatures are rejected by verifiers of the other variant regardless of the alg header
+	digest := []byte("Sig_structure")
+	sig, err := phSigner.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+	assert.ErrorIs(t, pureVerifier.Verify(digest, sig), ErrVerification)
+	sig, err = pureSigner.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+	assert.ErrorIs(t, phVerifier.Verify(digest, sig), ErrVerification)
+}
+
+func TestSetEd25519phValue(t *testing.T) {
+	defer func() {
+		require.NoError(t, SetEd25519phValue(-65537))
+	}()
+
+	assert.Error(t, SetEd25519phValue(-8))
+	assert.Error(t, SetEd25519phValue(-65535))
+	require.NoError(t, SetEd25519phValue(-70000))
+
+	info, ok := AlgorithmInfoFor(AlgorithmEd25519ph)
+	require.True(t, ok)
+	assert.Equal(t, int64(-70000), info.Value)
+	assert.Equal(t, "OKP", info.KeyType)
+	assert.Equal(t, "SHA-512", info.Hash)
+
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, AlgorithmEd25519ph))
+	assert.Equal(t, int64(-70000), h.protected[int64(1)])
+}
+
+func TestSetEd25519phValue_Concurrent(t *testing.T) {
+	defer func() {
+		require.NoError(t, SetEd25519phValue(-65537))
+	}()
+
+	signer, err := NewSigner(AlgorithmEd25519ph, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, SetEd25519phValue(-70000-int64(i%2)))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, err := StdEncoding.Encode(NewSign1Message().WithContent([]byte("test")).WithSigner(signer))
+			assert.NoError(t, err)
+			_, ok := AlgorithmInfoFor(AlgorithmEd25519ph)
+			assert.True(t, ok)
+		}
+	}()
+	wg.Wait()
+}
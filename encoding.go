@@ -5,10 +5,15 @@
 package cose
 
 import (
+	"context"
+	"crypto"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/fxamacker/cbor/v2"
 )
@@ -28,6 +33,8 @@ const (
 	MessageTagMAC = 97
 	// MessageTagMAC0 is the tag for MAC messages where recipients are not specified
 	MessageTagMAC0 = 17
+	// MessageTagCWT is the tag for CBOR Web Tokens wrapping a COSE message
+	MessageTagCWT = 61
 )
 
 // Encoding is the COSE encoding
@@ -35,33 +42,385 @@ type Encoding struct {
 	encMode cbor.EncMode
 	decMode cbor.DecMode
 	rand    io.Reader
+
+	deterministicSeed []byte
+	minKeySize        *int
+	strictHeaders     bool
+	forbiddenAlgs     []Algorithm
+	requiredAlgs      []Algorithm
+	relaxed           bool
+	lenientHeaders    bool
+	outerTag          uint64
+	coreDeterministic bool
+	metrics           MetricsCollector
+	policy            *AlgorithmPolicy
+
+	payloadDigestLabel interface{}
+	payloadDigestHash  crypto.Hash
+}
+
+// EncodingOption is an option for the COSE encoding
+type EncodingOption func(*Encoding) error
+
+// WithDeterministicSigning makes signatures reproducible for the same key, message and seed.
+//
+// The random source used for signing is derived from the seed and the signed data
+// using HKDF-SHA256 and ECDSA nonces are generated as described in RFC 6979.
+// This option is intended only for generating test vectors and golden files,
+// it must not be used for signing production messages.
+func WithDeterministicSigning(seed []byte) EncodingOption {
+	return func(e *Encoding) error {
+		if len(seed) == 0 {
+			return errors.New("deterministic signing seed can not be empty")
+		}
+		e.deterministicSeed = seed
+		return nil
+	}
+}
+
+// WithRandomSource replaces crypto/rand as the random source for signatures, IVs and keys.
+//
+// This option is intended only for reproducible tests with a seeded source,
+// it must not be used for encoding production messages.
+func WithRandomSource(r io.Reader) EncodingOption {
+	return func(e *Encoding) error {
+		if r == nil {
+			return errors.New("random source can not be nil")
+		}
+		e.rand = r
+		return nil
+	}
 }
 
 // Config is the configuration for the COSE encoding
 type Config struct {
 	// GetVerifiers returns the verifiers for the given message signature
+	//
+	// Headers contain both protected and unprotected headers, unprotected values such as kid
+	// can be modified in transit, use ProtectedOnly to select keys only by protected values.
 	GetVerifiers func(*Headers) ([]*Verifier, error)
+	// GetVerifiersWithContext returns the verifiers for the given message signature,
+	// it is used instead of GetVerifiers if set
+	GetVerifiersWithContext func(ctx context.Context, headers *Headers) ([]*Verifier, error)
+	// VerifierTimeout limits the time of resolving verifiers if not zero
+	//
+	// The resolver is not interrupted after the timeout, it should stop when the context is done.
+	// The lookup runs in a goroutine that may outlive Decode, it is given a clone of the headers
+	// and the lookup functions set when Decode was called.
+	VerifierTimeout time.Duration
 	// Verified callback
 	Verified func(*Verifier)
+	// OnSignatureVerified is called after a signature is verified with the index of the signature,
+	// always 0 for COSE_Sign1, and the message headers merged with the signature headers.
+	// With RequireAllSignatures it is called for each verified signature even if a later one fails.
+	OnSignatureVerified func(index int, headers *Headers)
+	// GetDecryptKey returns the key for decrypting the message recipient with the given headers
+	GetDecryptKey func(*Headers) (interface{}, error)
+	// GetTagVerifiers returns the MACers for verifying the COSE_Mac0 message tag with the given headers
+	GetTagVerifiers func(*Headers) ([]*MACer, error)
+	// GetKeyUnwrappers returns the key unwrappers for the AES Key Wrap or direct recipient
+	// with the given headers, it is used instead of GetDecryptKey for such recipients if set
+	GetKeyUnwrappers func(*Headers) ([]*KeyUnwrapper, error)
+	// ExpectedMessageTags restricts the accepted COSE message tags, all supported tags are accepted if empty
+	ExpectedMessageTags []uint64
+	// UnwrapCWTTag enables unwrapping of the CWT tag containing a COSE message, defaults to true if nil
+	UnwrapCWTTag *bool
+	// FetchCertificate returns the certificate referenced by the protected x5u header used for verifying
+	// the message signature, the certificate is *x509.Certificate unless built with the cose_nox509 build tag.
+	//
+	// The returned certificate is trusted as is, the callback must validate it against
+	// the trust anchors of the application.
+	FetchCertificate func(ctx context.Context, uri string) (*x509Certificate, error)
+	// AllowUnprotectedX5U fetches the certificate of the x5u header present only in unprotected headers,
+	// by default it is ignored as it can be replaced in transit.
+	AllowUnprotectedX5U bool
+	// RequireAlgorithmHeader requires the alg header to be present in protected headers, defaults to true if nil
+	//
+	// If the alg header is absent the verifier algorithm is authoritative.
+	RequireAlgorithmHeader *bool
+	// RequireProtectedAlgorithm fails decoding with ErrAlgorithmNotProtected if the alg header is present
+	// only in unprotected headers, defaults to true if nil, otherwise the unprotected alg header is used.
+	RequireProtectedAlgorithm *bool
+	// ValidateSigningTime validates the CWT iat and exp claims of the verified message payload,
+	// enables checking that the token is not expired or not yet valid.
+	// Claims are read if the content type header is CWT or if CWTPayload is set.
+	ValidateSigningTime func(iat, exp time.Time, headers *Headers) error
+	// CWTPayload treats the message payload as CWT claims regardless of the content type header
+	CWTPayload bool
+	// RequireCWTClaims makes the absence of CWT claims in the message payload an error
+	RequireCWTClaims bool
+	// TimeLeeway is the allowed clock skew when validating CWT claims and expiry header times
+	TimeLeeway time.Duration
+	// CurrentTime returns the time for validating CWT claims and the expiry header, defaults to time.Now if nil
+	CurrentTime func() time.Time
+	// ExpiryHeader is the label of the protected header with the message expiry time set by
+	// Headers.SetExpiry, the expiry is validated after verification if set
+	ExpiryHeader interface{}
+	// RequireExpiry makes the absence of the protected expiry header an error
+	RequireExpiry bool
+	// VerifyPayloadDigestHeader checks the COSE_Sign1 payload against the protected payload digest header
+	// of the encoding WithPayloadDigestHeader option before verifying the signature, failing with
+	// ErrPayloadDigestMismatch. Detached payloads given to DecodeSign1WithPayload are checked.
+	VerifyPayloadDigestHeader bool
+	// RequireProtectedKeyID fails decoding if the kid header is present only in unprotected headers,
+	// an unprotected kid is not given to GetVerifiers if the kid is protected
+	RequireProtectedKeyID bool
+	// CopyPayload copies the decoded message content and header values, by default the content
+	// of signed messages and header values decoded on access refer to the decoded data
+	// which must not be modified while the message is used
+	CopyPayload bool
+	// RequireAllSignatures requires all COSE_Sign signatures to be valid, defaults to true if nil,
+	// otherwise a single valid signature is sufficient.
+	//
+	// The failed signatures are reported by MultiVerificationError.
+	RequireAllSignatures *bool
+	// RequireDeterministicEncoding fails decoding with ErrNonDeterministicEncoding if the message
+	// or any of its protected headers does not use Core Deterministic Encoding
+	RequireDeterministicEncoding bool
+	// ValidatePayload validates the signed message payload after successful signature verification,
+	// the returned error is wrapped in ErrPayloadRejected.
+	//
+	// The content is the exact payload covered by the signature and must not be modified.
+	ValidatePayload func(content []byte, headers *Headers) error
+	// ExternalAADFromContext returns the external data for DecodeWithContext, such as a channel
+	// binding derived from the TLS connection using ExportKeyingMaterial. It takes priority over
+	// the external data given to DecodeWithContext.
+	ExternalAADFromContext func(ctx context.Context) ([]byte, error)
+}
+
+// Bool returns a pointer to the given bool value for optional configuration fields.
+func Bool(v bool) *bool {
+	return &v
+}
+
+func (c *Config) unwrapCWTTag() bool {
+	return c == nil || c.UnwrapCWTTag == nil || *c.UnwrapCWTTag
+}
+
+// checkDeterministicEncoding checks the encoding of the given data if RequireDeterministicEncoding is set.
+func (c *Config) checkDeterministicEncoding(data ...[]byte) error {
+	if c == nil || !c.RequireDeterministicEncoding {
+		return nil
+	}
+	for _, d := range data {
+		if len(d) == 0 {
+			continue
+		}
+		if err := CheckDeterministicEncoding(d); err != nil {
+			if _, ok := err.(ErrNonDeterministicEncoding); !ok {
+				err = ErrInvalidMessageStructure{err}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) requireAllSignatures() bool {
+	return c == nil || c.RequireAllSignatures == nil || *c.RequireAllSignatures
+}
+
+func (c *Config) requireAlgorithmHeader() bool {
+	return c == nil || c.RequireAlgorithmHeader == nil || *c.RequireAlgorithmHeader
+}
+
+func (c *Config) requireProtectedAlgorithm() bool {
+	return c == nil || c.RequireProtectedAlgorithm == nil || *c.RequireProtectedAlgorithm
+}
+
+// algorithmHeader returns the alg header of the decoded message enforcing RequireProtectedAlgorithm
+// or nil if the alg header is absent.
+func (c *Config) algorithmHeader(headers *Headers) (interface{}, error) {
+	alg, err := headers.GetProtected(HeaderAlgorithm)
+	if err != ErrHeaderNotFound {
+		if err == nil && alg == nil {
+			// CBOR null alg header is malformed
+			return nil, ErrInvalidHeader
+		}
+		return alg, err
+	}
+	if v, ok := headers.unprotected[getCommonHeader(HeaderAlgorithm)]; ok {
+		if c.requireProtectedAlgorithm() {
+			return nil, ErrAlgorithmNotProtected
+		}
+		if v == nil {
+			return nil, ErrInvalidHeader
+		}
+		return resolveAlgorithm(v), nil
+	}
+	return nil, nil
+}
+
+// keyIDHeaders returns the headers for resolving verifiers enforcing RequireProtectedKeyID.
+func (c *Config) keyIDHeaders(headers *Headers) (*Headers, error) {
+	if c == nil || !c.RequireProtectedKeyID {
+		return headers, nil
+	}
+	if _, ok := headers.unprotected[int64(4)]; !ok {
+		return headers, nil
+	}
+	if _, ok := headers.protected[int64(4)]; !ok {
+		return nil, ErrUnprotectedKeyID
+	}
+	h := MergeHeaders(headers, nil)
+	delete(h.unprotected, int64(4))
+	return h, nil
+}
+
+func (c *Config) payload(p []byte) []byte {
+	if c == nil || !c.CopyPayload || p == nil {
+		return p
+	}
+	return append([]byte{}, p...)
+}
+
+func (c *Config) validatePayload(payload []byte, headers *Headers) error {
+	if c == nil || c.ValidatePayload == nil {
+		return nil
+	}
+	if err := c.ValidatePayload(payload, headers); err != nil {
+		return ErrPayloadRejected{Err: err}
+	}
+	return nil
+}
+
+func (c *Config) checkMessageTag(tag uint64) error {
+	if c == nil || len(c.ExpectedMessageTags) == 0 {
+		return nil
+	}
+	for _, t := range c.ExpectedMessageTags {
+		if t == tag {
+			return nil
+		}
+	}
+	return ErrUnexpectedMessageTag{Tag: tag, Expected: c.ExpectedMessageTags}
 }
 
 var (
 	// StdEncoging is the COSE standard encoding
 	StdEncoding, stdEncodingErr = NewEncoding()
+	// RelaxedEncoding is the COSE encoding accepting COSE Core serialization when decoding
+	RelaxedEncoding, relaxedEncodingErr = NewEncodingRelaxed()
 )
 
+// WithMinKeySize overrides the minimum RSA key size in bits required for signing.
+//
+// Lowering the minimum key size below the algorithm minimum is INSECURE and should only be
+// used in test environments or with legacy devices that can not be upgraded.
+// Signers with keys smaller than the algorithm minimum must be created with NewSignerInsecure.
+func WithMinKeySize(bits int) EncodingOption {
+	return func(e *Encoding) error {
+		if bits < 0 {
+			return errors.New("minimum key size can not be negative")
+		}
+		e.minKeySize = &bits
+		return nil
+	}
+}
+
+// WithStrictHeaders makes encoding fail with ErrHeaderConflict when message and signer
+// headers set the same label with different values instead of silently prioritizing one.
+func WithStrictHeaders() EncodingOption {
+	return func(e *Encoding) error {
+		e.strictHeaders = true
+		return nil
+	}
+}
+
+// WithLenientUnprotectedHeaders decodes messages whose unprotected headers are CBOR null
+// or an empty indefinite length map as messages with empty unprotected headers.
+//
+// The unprotected headers are not signed, the protected headers are always decoded strictly.
+func WithLenientUnprotectedHeaders() EncodingOption {
+	return func(e *Encoding) error {
+		e.lenientHeaders = true
+		return nil
+	}
+}
+
+// WithCWTTag wraps encoded messages in the CWT tag as required by some CWT relying parties.
+func WithCWTTag() EncodingOption {
+	return WithOuterTag(MessageTagCWT)
+}
+
+// WithOuterTag wraps encoded messages in the given tag, the tag is not signed.
+// Only the CWT tag and the self-described CBOR tag are supported.
+func WithOuterTag(tag uint64) EncodingOption {
+	return func(e *Encoding) error {
+		if tag != MessageTagCWT && tag != tagSelfDescribedCBOR {
+			return ErrUnsupportedMessageTag{tag}
+		}
+		e.outerTag = tag
+		return nil
+	}
+}
+
+// WithForbiddenAlgorithms forbids encoding and decoding messages signed with the given algorithms.
+func WithForbiddenAlgorithms(algs ...Algorithm) EncodingOption {
+	return func(e *Encoding) error {
+		e.forbiddenAlgs = append(e.forbiddenAlgs, algs...)
+		return nil
+	}
+}
+
+// WithRequiredAlgorithms requires at least one message signer to use one of the given algorithms.
+func WithRequiredAlgorithms(algs ...Algorithm) EncodingOption {
+	return func(e *Encoding) error {
+		e.requiredAlgs = append(e.requiredAlgs, algs...)
+		return nil
+	}
+}
+
+// WithCoreDeterministicEncoding encodes messages and protected headers using Core Deterministic
+// Encoding with bytewise lexicographic map key order instead of length-first canonical order.
+func WithCoreDeterministicEncoding() EncodingOption {
+	return func(e *Encoding) error {
+		e.coreDeterministic = true
+		return nil
+	}
+}
+
+// WithMetrics reports the duration and result of encode and decode phases to the collector.
+func WithMetrics(m MetricsCollector) EncodingOption {
+	return func(e *Encoding) error {
+		e.metrics = m
+		return nil
+	}
+}
+
+// NewEncodingRelaxed creates a new COSE encoding that decodes messages using COSE Core
+// serialization, such as indefinite length items, while encoding remains COSE Canonical.
+func NewEncodingRelaxed(opts ...EncodingOption) (*Encoding, error) {
+	return NewEncoding(append([]EncodingOption{func(e *Encoding) error {
+		e.relaxed = true
+		return nil
+	}}, opts...)...)
+}
+
 // NewEncoding creates a new COSE encoding
-func NewEncoding() (*Encoding, error) {
+func NewEncoding(opts ...EncodingOption) (*Encoding, error) {
 	enc := &Encoding{
 		rand: rand.Reader,
 	}
 	var err error
+	for _, opt := range opts {
+		if err = opt(enc); err != nil {
+			return nil, err
+		}
+	}
+	if err = enc.policy.validate(enc); err != nil {
+		return nil, err
+	}
 
 	// Initialize the encoder mode
 	encOptions := cbor.EncOptions{
 		IndefLength: cbor.IndefLengthForbidden,
 		Sort:        cbor.SortCanonical,
 	}
+	if enc.coreDeterministic {
+		encOptions.Sort = cbor.SortCoreDeterministic
+		encOptions.ShortestFloat = cbor.ShortestFloat16
+	}
 	if enc.encMode, err = encOptions.EncMode(); err != nil {
 		return nil, err
 	}
@@ -82,10 +441,35 @@ func NewEncoding() (*Encoding, error) {
 	); err != nil {
 		return nil, err
 	}
+	if err = tags.Add(
+		cbor.TagOptions{EncTag: cbor.EncTagRequired, DecTag: cbor.DecTagRequired},
+		reflect.TypeOf(EncryptMessage{}),
+		MessageTagEncrypt,
+	); err != nil {
+		return nil, err
+	}
+	if err = tags.Add(
+		cbor.TagOptions{EncTag: cbor.EncTagRequired, DecTag: cbor.DecTagRequired},
+		reflect.TypeOf(Encrypt0Message{}),
+		MessageTagEncrypt0,
+	); err != nil {
+		return nil, err
+	}
+	if err = tags.Add(
+		cbor.TagOptions{EncTag: cbor.EncTagRequired, DecTag: cbor.DecTagRequired},
+		reflect.TypeOf(Mac0Message{}),
+		MessageTagMAC0,
+	); err != nil {
+		return nil, err
+	}
 	decOptions := cbor.DecOptions{
+		DupMapKey:   cbor.DupMapKeyEnforcedAPF,
 		IndefLength: cbor.IndefLengthForbidden,
 		IntDec:      cbor.IntDecConvertSigned,
 	}
+	if enc.relaxed {
+		decOptions.IndefLength = cbor.IndefLengthAllowed
+	}
 	if enc.decMode, err = decOptions.DecModeWithTags(tags); err != nil {
 		return nil, err
 	}
@@ -93,8 +477,24 @@ func NewEncoding() (*Encoding, error) {
 	return enc, nil
 }
 
-// EncodeWithExternal encodes the given message with the given external data
+// EncodeWithExternal encodes the given message with the given external data,
+// external data set on the message is used if the given external data is empty
 func (e *Encoding) EncodeWithExternal(message Message, external []byte) ([]byte, error) {
+	start := e.metricsStart()
+	m, err := e.encodeStructure(message, external)
+	e.observeEncode(messageAlgorithm(message), message.GetMessageTag(), PhaseSign, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	start = e.metricsStart()
+	b, err := e.marshal(e.outerTagged(message.GetMessageTag(), m))
+	e.observeEncode(messageAlgorithm(message), message.GetMessageTag(), PhaseMarshal, start, err)
+	return b, err
+}
+
+// encodeStructure returns the signed, encrypted or authenticated message structure.
+func (e *Encoding) encodeStructure(message Message, external []byte) (interface{}, error) {
 	var m interface{}
 	switch msg := message.(type) {
 	case *Sign1Message:
@@ -109,10 +509,245 @@ func (e *Encoding) EncodeWithExternal(message Message, external []byte) ([]byte,
 			return nil, err
 		}
 		m = sm
+	case *EncryptMessage:
+		if err := e.checkSymmetricMessage(msg); err != nil {
+			return nil, err
+		}
+		em, err := msg.encrypt(e, external)
+		if err != nil {
+			return nil, err
+		}
+		m = em
+	case *Encrypt0Message:
+		if err := e.checkSymmetricMessage(msg); err != nil {
+			return nil, err
+		}
+		em, err := msg.encrypt(e, external)
+		if err != nil {
+			return nil, err
+		}
+		m = em
+	case *Mac0Message:
+		if err := e.checkSymmetricMessage(msg); err != nil {
+			return nil, err
+		}
+		mm, err := msg.tag(e, external)
+		if err != nil {
+			return nil, err
+		}
+		m = mm
 	default:
 		return nil, ErrUnsupportedMessageTag{message.GetMessageTag()}
 	}
-	return e.encMode.Marshal(cbor.Tag{Number: message.GetMessageTag(), Content: m})
+	return m, nil
+}
+
+// EstimateEncodedSize returns the exact encoded size of the COSE_Sign1 or COSE_Sign message
+// without signing it, the signatures are assumed to have the fixed length of the signer
+// algorithm and key.
+func (e *Encoding) EstimateEncodedSize(message Message) (int, error) {
+	var m interface{}
+	var err error
+	switch msg := message.(type) {
+	case *Sign1Message:
+		m, err = msg.estimate(e)
+	case *SignMessage:
+		m, err = msg.estimate(e)
+	default:
+		return 0, ErrUnsupportedMessageTag{message.GetMessageTag()}
+	}
+	if err != nil {
+		return 0, err
+	}
+	b, err := e.marshal(e.outerTagged(message.GetMessageTag(), m))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// EstimateSize returns an upper bound of the encoded size of the COSE_Sign1, COSE_Sign, COSE_Mac0
+// or COSE_Encrypt0 message without signing or encrypting it.
+//
+// Signatures of the supported algorithms, MAC tags and AES-GCM ciphertexts have a fixed size,
+// so the estimate is equal to the encoded size unless headers are added while encoding.
+func (e *Encoding) EstimateSize(message Message) (int, error) {
+	var m interface{}
+	var err error
+	switch msg := message.(type) {
+	case *Mac0Message:
+		m, err = msg.tag(e, nil)
+	case *Encrypt0Message:
+		m, err = msg.estimate(e)
+	default:
+		return e.EstimateEncodedSize(message)
+	}
+	if err != nil {
+		return 0, err
+	}
+	b, err := e.marshal(e.outerTagged(message.GetMessageTag(), m))
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// EncodeSign1Detached encodes the COSE_Sign1 message with a detached payload, the message
+// content is signed but encoded as nil and returned separately as the payload.
+func (e *Encoding) EncodeSign1Detached(msg *Sign1Message, external []byte) (coseBytes, payload []byte, err error) {
+	if msg == nil {
+		return nil, nil, errors.New("message can not be nil")
+	}
+	sm, err := msg.sign(e, external)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := sm.(sign1Message)
+	payload, c.Payload = c.Payload, nil
+	if coseBytes, err = e.marshal(e.outerTagged(MessageTagSign1, c)); err != nil {
+		return nil, nil, err
+	}
+	return coseBytes, payload, nil
+}
+
+// EncodeRelay re-encodes the decoded COSE_Sign1 message without signing it, for forwarding
+// the message with modified unprotected headers. The received protected header bytes,
+// payload and signature are kept unchanged so the message still verifies with the
+// original key.
+//
+// ErrSignedContentModified is returned if the protected headers or content of the message
+// were modified, such messages must be signed again by setting a signer and using Encode.
+func (e *Encoding) EncodeRelay(msg *Sign1Message) ([]byte, error) {
+	if msg == nil {
+		return nil, errors.New("message can not be nil")
+	}
+	c, err := msg.relay(e)
+	if err != nil {
+		return nil, err
+	}
+	return e.marshal(e.outerTagged(MessageTagSign1, c))
+}
+
+// EncodeAssembled encodes the COSE_Sign message assembled from signatures added with AddSignature.
+//
+// Message signers are signed at encode time using the external data set on the message.
+func (e *Encoding) EncodeAssembled(msg *SignMessage) ([]byte, error) {
+	if msg == nil {
+		return nil, errors.New("message can not be nil")
+	}
+	return e.EncodeWithExternal(msg, nil)
+}
+
+// mergeHeaders merges message and signer headers respecting the strict headers option.
+func (e *Encoding) mergeHeaders(h1, h2 *Headers) (*Headers, error) {
+	if e.strictHeaders {
+		return MergeHeadersStrict(h1, h2)
+	}
+	return MergeHeaders(h1, h2), nil
+}
+
+// checkAlgorithms checks the signature algorithms against the forbidden and required algorithms.
+func (e *Encoding) checkAlgorithms(algs []Algorithm) error {
+	required := len(e.requiredAlgs) == 0
+	for _, alg := range algs {
+		if containsAlgorithm(e.forbiddenAlgs, alg) {
+			return ErrForbiddenAlgorithm{alg}
+		}
+		if err := e.policy.checkAlgorithm(alg); err != nil {
+			return err
+		}
+		if containsAlgorithm(e.requiredAlgs, alg) {
+			required = true
+		}
+	}
+	if !required {
+		return ErrRequiredAlgorithm
+	}
+	return nil
+}
+
+// signerAlgorithms returns the algorithms of the signers.
+func signerAlgorithms(signers ...*Signer) []Algorithm {
+	algs := make([]Algorithm, 0, len(signers))
+	for _, signer := range signers {
+		if signer != nil {
+			algs = append(algs, Algorithm(signer.alg.Name))
+		}
+	}
+	return algs
+}
+
+// checkAlgorithmHeader checks the decoded message alg header against the forbidden algorithms.
+func (e *Encoding) checkAlgorithmHeader(headers *Headers) error {
+	alg, err := headerAlgorithm(headers)
+	if err != nil {
+		return err
+	}
+	if alg != "" && containsAlgorithm(e.forbiddenAlgs, alg) {
+		return ErrForbiddenAlgorithm{alg}
+	}
+	return e.policy.checkAlgorithm(alg)
+}
+
+// checkSymmetricMessage checks the encryption or MAC message to encode against the algorithm policy.
+func (e *Encoding) checkSymmetricMessage(msg Message) error {
+	if err := e.policy.checkSymmetric(); err != nil {
+		return err
+	}
+	return e.policy.checkAlgorithm(messageAlgorithm(msg))
+}
+
+// checkSymmetricHeaders checks the decoded encryption or MAC message against the algorithm policy.
+func (e *Encoding) checkSymmetricHeaders(headers *Headers) error {
+	if err := e.policy.checkSymmetric(); err != nil {
+		return err
+	}
+	alg, err := headerAlgorithm(headers)
+	if err != nil {
+		return err
+	}
+	return e.policy.checkAlgorithm(alg)
+}
+
+// headerAlgorithm returns the resolved alg protected header or empty string if not known.
+func headerAlgorithm(headers *Headers) (Algorithm, error) {
+	alg, err := headers.GetProtected(HeaderAlgorithm)
+	if err == ErrHeaderNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name, _ := alg.(string)
+	return Algorithm(name), nil
+}
+
+func containsAlgorithm(algs []Algorithm, alg Algorithm) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// signDigest signs the digest with the signer using the encoding random source.
+func (e *Encoding) signDigest(signer *Signer, digest []byte) ([]byte, error) {
+	minKeySize := signer.minKeySize
+	if e.minKeySize != nil {
+		minKeySize = *e.minKeySize
+	}
+	if size := signer.keySize(); size > 0 && size < minKeySize {
+		return nil, ErrMinKeySize{minKeySize}
+	}
+	if err := e.policy.checkSigner(signer); err != nil {
+		return nil, err
+	}
+
+	if e.deterministicSeed == nil {
+		return signer.Sign(e.rand, digest)
+	}
+	return signer.sign(newHKDFReader(crypto.SHA256, e.deterministicSeed, nil, digest), digest, true)
 }
 
 // Encode encodes the given message
@@ -120,115 +755,686 @@ func (e *Encoding) Encode(message Message) ([]byte, error) {
 	return e.EncodeWithExternal(message, []byte{})
 }
 
-func verifySignature(config *Config, headers *Headers, digest, signature []byte) error {
-	var err error
-	var verifiers []*Verifier
-	if config != nil {
-		verifiers, err = config.GetVerifiers(headers)
-	}
-
-	if err == nil {
-		if len(verifiers) == 0 {
-			err = ErrVerification
-		} else {
-			var verr error
-			for _, v := range verifiers {
-				if verr = v.Verify(digest, signature); verr == nil {
-					if config != nil && config.Verified != nil {
-						config.Verified(v)
-					}
-					break
-				}
+func (e *Encoding) verifySignature(ctx context.Context, tag uint64, config *Config, headers *Headers, digest, signature []byte) error {
+	alg, err := config.algorithmHeader(headers)
+	if err != nil {
+		return err
+	}
+	if alg == nil && config.requireAlgorithmHeader() {
+		return ErrMissingAlgorithmHeader
+	}
+	name, _ := alg.(string)
+	// Signature size of algorithms with fixed size signatures is known before resolving verifiers
+	if a := getAlg(name); a != nil && a.IsSigningAlgorithm() {
+		if size := a.SignatureSize(0); size > 0 && len(signature) != size {
+			return ErrVerification
+		}
+	}
+
+	if headers, err = config.keyIDHeaders(headers); err != nil {
+		return err
+	}
+
+	start := e.metricsStart()
+	verifiers, err := config.resolveVerifiers(ctx, headers)
+	e.observeDecode(Algorithm(name), tag, PhaseResolveVerifiers, start, err)
+	if err != nil {
+		return err
+	}
+
+	start = e.metricsStart()
+	err = verifyWith(config, e.policy, e.forbiddenAlgs, verifiers, name, digest, signature)
+	e.observeDecode(Algorithm(name), tag, PhaseVerify, start, err)
+	return err
+}
+
+// verifyWith verifies the signature with the verifiers matching the algorithm name if not empty,
+// verifiers of forbidden algorithms or not permitted by the policy are skipped.
+func verifyWith(config *Config, policy *AlgorithmPolicy, forbidden []Algorithm, verifiers []*Verifier, alg string, digest, signature []byte) error {
+	err := ErrVerification
+	for _, v := range uniqueVerifiers(verifiers) {
+		// Skip verifiers not matching the algorithm header
+		if alg != "" && v.alg.Name != alg {
+			continue
+		}
+		if containsAlgorithm(forbidden, Algorithm(v.alg.Name)) {
+			err = ErrForbiddenAlgorithm{Algorithm(v.alg.Name)}
+			continue
+		}
+		if perr := policy.checkVerifier(v); perr != nil {
+			err = perr
+			continue
+		}
+		if err = v.Verify(digest, signature); err == nil {
+			if config != nil && config.Verified != nil {
+				config.Verified(v)
 			}
-			err = verr
+			return nil
 		}
 	}
 	return err
 }
 
+// signatureVerified calls the OnSignatureVerified callback if set.
+func (c *Config) signatureVerified(index int, headers *Headers) {
+	if c != nil && c.OnSignatureVerified != nil {
+		c.OnSignatureVerified(index, headers)
+	}
+}
+
+// resolveVerifiers returns the verifiers for the headers limiting the lookup time by VerifierTimeout.
+func (c *Config) resolveVerifiers(ctx context.Context, headers *Headers) ([]*Verifier, error) {
+	if c == nil {
+		return nil, nil
+	}
+	lookup := verifierLookup{
+		getVerifiers:            c.GetVerifiers,
+		getVerifiersWithContext: c.GetVerifiersWithContext,
+		fetchCertificate:        c.FetchCertificate,
+		allowUnprotectedX5U:     c.AllowUnprotectedX5U,
+	}
+	if c.VerifierTimeout <= 0 {
+		return lookup.verifiers(ctx, headers)
+	}
+
+	timeout := c.VerifierTimeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		verifiers []*Verifier
+		err       error
+	}
+	done := make(chan result, 1)
+	// The goroutine may outlive the call, it must not refer to the config or the message headers
+	headers = headers.Clone()
+	go func() {
+		verifiers, err := lookup.verifiers(ctx, headers)
+		done <- result{verifiers, err}
+	}()
+	select {
+	case r := <-done:
+		return r.verifiers, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.Canceled {
+			return nil, fmt.Errorf("verifier lookup cancelled: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("verifier lookup not completed in %s: %w", timeout, ctx.Err())
+	}
+}
+
+// verifierLookup holds the config functions resolving verifiers.
+type verifierLookup struct {
+	getVerifiers            func(*Headers) ([]*Verifier, error)
+	getVerifiersWithContext func(ctx context.Context, headers *Headers) ([]*Verifier, error)
+	fetchCertificate        func(ctx context.Context, uri string) (*x509Certificate, error)
+	allowUnprotectedX5U     bool
+}
+
+func (l verifierLookup) verifiers(ctx context.Context, headers *Headers) ([]*Verifier, error) {
+	var verifiers []*Verifier
+	var err error
+	if l.getVerifiersWithContext != nil {
+		verifiers, err = l.getVerifiersWithContext(ctx, headers)
+	} else if l.getVerifiers != nil {
+		verifiers, err = l.getVerifiers(headers)
+	}
+	if err == nil && l.fetchCertificate != nil {
+		var v *Verifier
+		if v, err = fetchCertificateVerifier(ctx, l.fetchCertificate, headers, l.allowUnprotectedX5U); v != nil {
+			verifiers = append(verifiers, v)
+		}
+	}
+	return verifiers, err
+}
+
+// fetchCertificateVerifier creates the verifier from the certificate referenced by the protected x5u header,
+// or by the unprotected one if allowed.
+func fetchCertificateVerifier(ctx context.Context, fetch func(context.Context, string) (*x509Certificate, error), headers *Headers, allowUnprotected bool) (*Verifier, error) {
+	if !allowUnprotected {
+		headers = headers.ProtectedOnly()
+	}
+	value, ok, err := headers.Lookup(HeaderX5U)
+	if err != nil || !ok {
+		return nil, err
+	}
+	uri, ok := value.(string)
+	if !ok {
+		return nil, errors.New("x5u header must be a text string")
+	}
+	alg, err := headers.GetProtected(HeaderAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	name, ok := alg.(string)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	cert, err := fetch(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("certificate can not be nil")
+	}
+	return NewVerifier(Algorithm(name), cert.PublicKey)
+}
+
 // DecodeWithExternal decodes the given data with the given external data
+//
+// If the external data is known only after inspecting the decoded message headers,
+// the signatures can be verified again using ReverifyWithExternal.
 func (e *Encoding) DecodeWithExternal(data, external []byte, config *Config) (Message, error) {
-	var raw cbor.RawTag
-	if err := e.decMode.Unmarshal(data, &raw); err != nil {
+	return e.decodeWithExternal(context.Background(), data, external, config)
+}
+
+// decodeWithExternal decodes the given data resolving verifiers with the context.
+func (e *Encoding) decodeWithExternal(ctx context.Context, data, external []byte, config *Config) (Message, error) {
+	raw, err := e.parseMessageTag(data, config)
+	if err != nil {
 		return nil, err
 	}
 
 	switch raw.Number {
 	case MessageTagSign1:
-		var c sign1Message
-		if err := e.decMode.Unmarshal(raw.Content, &c); err != nil {
+		msg, err := e.decodeSign1(ctx, raw.Content, nil, external, config)
+		if msg == nil {
+			return nil, err
+		}
+		return msg, err
+	case MessageTagSign:
+		var c signMessage
+		if err := e.unmarshal(stageMessageBody, raw.Content, &c); err != nil {
+			return nil, decodeError(err)
+		}
+		if err := config.checkDeterministicEncoding(c.protectedHeaders()...); err != nil {
 			return nil, err
 		}
 
-		msg, err := newSign1Message(e, &c)
+		msg, err := newSignMessage(e, &c)
 		if err != nil {
 			return nil, err
 		}
+		if err := msg.Headers.ValidateCritical(); err != nil {
+			return nil, err
+		}
+		msg.content = config.payload(c.Payload)
+		msg.setDecoded(e, &c, config)
 
-		var digest []byte
-		digest, err = c.GetDigest(e, external)
-		if err != nil {
+		if err := c.verify(ctx, e, msg.Headers, external, config); err != nil {
 			return msg, err
 		}
-
-		return msg, verifySignature(config, msg.Headers, digest, c.Signature)
-	case MessageTagSign:
-		var c signMessage
-		if err := e.decMode.Unmarshal(raw.Content, &c); err != nil {
+		if err := config.validatePayload(c.Payload, msg.Headers); err != nil {
+			return msg, err
+		}
+		return msg, config.validateClaims(e, msg.Headers, msg.GetContent())
+	case MessageTagEncrypt:
+		var c encryptMessage
+		if err := e.unmarshal(stageMessageBody, raw.Content, &c); err != nil {
+			return nil, decodeError(err)
+		}
+		if err := config.checkDeterministicEncoding(c.protectedHeaders()...); err != nil {
 			return nil, err
 		}
 
-		msg, err := newSignMessage(e, &c)
+		msg, err := newEncryptMessage(e, &c)
 		if err != nil {
 			return nil, err
 		}
+		if err := e.checkSymmetricHeaders(msg.Headers); err != nil {
+			return nil, err
+		}
+		if err := msg.Headers.ValidateCritical(); err != nil {
+			return nil, err
+		}
 
-		for _, sig := range c.Signatures {
-			var digest []byte
-			digest, err = c.GetDigest(e, sig.Protected, external)
-			if err != nil {
-				return msg, err
-			}
+		msg.content, err = c.decrypt(e, msg, external, config)
+		return msg, err
+	case MessageTagEncrypt0:
+		var c encrypt0Message
+		if err := e.unmarshal(stageMessageBody, raw.Content, &c); err != nil {
+			return nil, decodeError(err)
+		}
+		if err := config.checkDeterministicEncoding(c.Protected); err != nil {
+			return nil, err
+		}
 
-			sheaders, err := newHeaders(e, sig.Protected, sig.Unprotected)
-			if err != nil {
-				return msg, err
-			}
+		msg, err := newEncrypt0Message(e, &c)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.checkSymmetricHeaders(msg.Headers); err != nil {
+			return nil, err
+		}
+		if err := msg.Headers.ValidateCritical(); err != nil {
+			return nil, err
+		}
 
-			if err = verifySignature(config, MergeHeaders(msg.Headers, sheaders), digest, sig.Signature); err != nil {
-				return msg, err
-			}
+		msg.content, err = c.decrypt(e, msg, external, config)
+		return msg, err
+	case MessageTagMAC0:
+		var c mac0Message
+		if err := e.unmarshal(stageMessageBody, raw.Content, &c); err != nil {
+			return nil, decodeError(err)
+		}
+		if err := config.checkDeterministicEncoding(c.Protected); err != nil {
+			return nil, err
 		}
 
-		return msg, nil
+		msg, err := newMac0Message(e, &c)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.checkSymmetricHeaders(msg.Headers); err != nil {
+			return nil, err
+		}
+		if err := msg.Headers.ValidateCritical(); err != nil {
+			return nil, err
+		}
+		msg.content = config.payload(c.Payload)
+
+		if err := c.verify(e, msg, external, config); err != nil {
+			return msg, err
+		}
+		if err := config.validatePayload(c.Payload, msg.Headers); err != nil {
+			return msg, err
+		}
+		return msg, config.validateClaims(e, msg.Headers, msg.GetContent())
 	default:
 		return nil, ErrUnsupportedMessageTag{raw.Number}
 	}
 }
 
+// DecodeSign1 decodes the COSE_Sign1 message, other message types fail with ErrUnexpectedMessageTag.
+func (e *Encoding) DecodeSign1(data []byte, config *Config) (*Sign1Message, error) {
+	raw, err := e.parseMessageTag(data, config)
+	if err != nil {
+		return nil, err
+	}
+	if raw.Number != MessageTagSign1 {
+		return nil, ErrUnexpectedMessageTag{Tag: raw.Number, Expected: []uint64{MessageTagSign1}}
+	}
+	return e.decodeSign1(context.Background(), raw.Content, nil, []byte{}, config)
+}
+
+// DecodeSign1WithPayload decodes the COSE_Sign1 message with a detached payload,
+// the payload is reattached to the message for verifying the signature.
+func (e *Encoding) DecodeSign1WithPayload(coseData, payload, external []byte, config *Config) (*Sign1Message, error) {
+	raw, err := e.parseMessageTag(coseData, config)
+	if err != nil {
+		return nil, err
+	}
+	if raw.Number != MessageTagSign1 {
+		return nil, ErrUnexpectedMessageTag{Tag: raw.Number, Expected: []uint64{MessageTagSign1}}
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+	return e.decodeSign1(context.Background(), raw.Content, payload, external, config)
+}
+
+// parseMessageTag returns the COSE message tag of the data unwrapping the CWT tag.
+func (e *Encoding) parseMessageTag(data []byte, config *Config) (raw rawTag, err error) {
+	start := e.metricsStart()
+	defer func() {
+		e.observeDecode("", raw.Number, PhaseParse, start, err)
+	}()
+	return e.parseTag(data, config)
+}
+
+func (e *Encoding) parseTag(data []byte, config *Config) (rawTag, error) {
+	if len(data) > 0 && data[0]>>5 == 4 {
+		return rawTag{}, ErrInvalidMessageStructure{ErrUntaggedMessage{untaggedMessageCandidates(e, data)}}
+	}
+	// Malformed data items are reported by the stage that fails to decode them
+	if n, err := itemLength(data); err == nil && n < len(data) {
+		return rawTag{}, ErrInvalidMessageStructure{ErrTrailingData{Offset: n, Remaining: len(data) - n}}
+	}
+	raw, err := parseTag(data)
+	if err == nil && raw.Number == tagSelfDescribedCBOR {
+		raw, err = parseTag(raw.Content)
+	}
+	if err != nil {
+		return rawTag{}, ErrInvalidMessageStructure{ErrCBORDecode{Cause: err, Stage: stageOuterTag}}
+	}
+
+	// Only a single CWT tag directly wrapping the COSE message tag is unwrapped
+	if raw.Number == MessageTagCWT && config.unwrapCWTTag() {
+		inner, err := parseTag(raw.Content)
+		if err != nil {
+			return rawTag{}, ErrInvalidMessageStructure{ErrCBORDecode{Cause: err, Stage: stageOuterTag}}
+		}
+		if inner.Number == MessageTagCWT {
+			return rawTag{}, ErrUnsupportedMessageTag{inner.Number}
+		}
+		raw = inner
+	}
+
+	if err := config.checkMessageTag(raw.Number); err != nil {
+		return rawTag{}, err
+	}
+	if err := config.checkDeterministicEncoding(data); err != nil {
+		return rawTag{}, err
+	}
+	content, err := e.checkUnprotected(raw.Content)
+	if err != nil {
+		return rawTag{}, err
+	}
+	if config != nil && config.CopyPayload {
+		content = append([]byte{}, content...)
+	}
+	raw.Content = content
+	return raw, nil
+}
+
+// checkUnprotected rejects null and empty indefinite length unprotected headers of the message body,
+// with lenient unprotected headers they are replaced with an empty map.
+func (e *Encoding) checkUnprotected(body []byte) ([]byte, error) {
+	off, ok := unprotectedOffset(body)
+	if !ok {
+		return body, nil
+	}
+	var n int
+	var reason string
+	switch {
+	case body[off] == 0xf6:
+		n, reason = 1, "null instead of map"
+	case body[off] == 0xbf && off+1 < len(body) && body[off+1] == 0xff:
+		n, reason = 2, "indefinite length empty map"
+	default:
+		return body, nil
+	}
+	if e.lenientHeaders {
+		content := make([]byte, 0, len(body)-n+1)
+		content = append(content, body[:off]...)
+		content = append(content, 0xa0)
+		return append(content, body[off+n:]...), nil
+	}
+	if n == 2 && e.relaxed {
+		return body, nil
+	}
+	return nil, ErrInvalidMessageStructure{ErrCBORDecode{Cause: errors.New(reason), Stage: stageUnprotectedHeader}}
+}
+
+// unprotectedOffset returns the offset of the unprotected headers in the message body array.
+func unprotectedOffset(body []byte) (int, bool) {
+	if len(body) == 0 || body[0]>>5 != 4 {
+		return 0, false
+	}
+	off := 1
+	if body[0] != 0x9f {
+		count, n, err := parseHeadArgument(body)
+		if err != nil || count < 2 {
+			return 0, false
+		}
+		off = n
+	}
+	if off >= len(body) || body[off]>>5 != 2 {
+		return 0, false
+	}
+	length, n, err := parseHeadArgument(body[off:])
+	if err != nil || length >= uint64(len(body)-off-n) {
+		return 0, false
+	}
+	return off + n + int(length), true
+}
+
+// decodeSign1 decodes the COSE_Sign1 message content, the detached payload
+// is used for the message content if not nil.
+func (e *Encoding) decodeSign1(ctx context.Context, data, detached, external []byte, config *Config) (*Sign1Message, error) {
+	var c sign1Message
+	if err := e.unmarshal(stageMessageBody, data, &c); err != nil {
+		return nil, decodeError(err)
+	}
+	if err := config.checkDeterministicEncoding(c.Protected); err != nil {
+		return nil, err
+	}
+	wasDetached := c.Payload == nil
+	if detached != nil {
+		if c.Payload != nil {
+			return nil, errors.New("message payload is not detached")
+		}
+		c.Payload = detached
+	}
+
+	msg, err := newSign1Message(e, &c)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Headers.ValidateCritical(); err != nil {
+		return nil, err
+	}
+	if config != nil && config.VerifyPayloadDigestHeader {
+		if err := e.checkPayloadDigest(msg.Headers, c.Payload); err != nil {
+			return nil, err
+		}
+	}
+	msg.content = config.payload(c.Payload)
+	msg.detached = wasDetached
+	msg.setDecoded(e, &c, external, config)
+
+	if err := c.verify(ctx, e, msg.Headers, external, config); err != nil {
+		return msg, err
+	}
+	if err := config.validatePayload(c.Payload, msg.Headers); err != nil {
+		return msg, err
+	}
+	return msg, config.validateClaims(e, msg.Headers, msg.GetContent())
+}
+
 // Decode decodes the given data
+//
+// When the returned error is ErrVerification the returned message is valid for reading,
+// but its authenticity is not assured. When the returned error is ErrTokenExpired or
+// ErrTokenNotYetValid the message signature is verified, but its CWT claims are not valid.
 func (e *Encoding) Decode(data []byte, config *Config) (Message, error) {
 	return e.DecodeWithExternal(data, []byte{}, config)
 }
 
+// DecodeFirst decodes the first COSE message of the data and returns the data following it,
+// Decode rejects data following the message with ErrTrailingData.
+//
+// The rest is returned even if the message fails to verify, it is nil only if the
+// first CBOR data item of the data is malformed or incomplete.
+func (e *Encoding) DecodeFirst(data []byte, config *Config) (msg Message, rest []byte, err error) {
+	n, err := itemLength(data)
+	if err != nil {
+		return nil, nil, ErrInvalidMessageStructure{ErrCBORDecode{Cause: err, Stage: stageOuterTag}}
+	}
+	msg, err = e.Decode(data[:n:n], config)
+	return msg, data[n:], err
+}
+
+// DecodeWithContext decodes the given data with the external data returned by
+// Config.ExternalAADFromContext for the context if set, otherwise with the given external data.
+//
+// The context is given to GetVerifiersWithContext and FetchCertificate, VerifierTimeout
+// limits the lookup further.
+func (e *Encoding) DecodeWithContext(ctx context.Context, data, external []byte, config *Config) (Message, error) {
+	if config != nil && config.ExternalAADFromContext != nil {
+		aad, err := config.ExternalAADFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		external = aad
+	}
+	return e.decodeWithExternal(ctx, data, external, config)
+}
+
+// VerifiedMessage is a decoded message with its verification status.
+type VerifiedMessage struct {
+	Message Message
+	// Verified is true only if the message was decoded and verified without errors
+	Verified bool
+}
+
+// DecodeWithStatus decodes the given data and reports whether the message was verified.
+//
+// The decoded message is returned even if the verification fails, in which case
+// the message is valid for reading but its authenticity is not assured.
+func (e *Encoding) DecodeWithStatus(data []byte, config *Config) (*VerifiedMessage, error) {
+	msg, err := e.Decode(data, config)
+	if msg == nil {
+		return nil, err
+	}
+	return &VerifiedMessage{
+		Message:  msg,
+		Verified: err == nil,
+	}, err
+}
+
+// marshalProtected encodes protected headers, empty headers are encoded as a zero-length byte string.
+func (e *Encoding) marshalProtected(h map[interface{}]interface{}) ([]byte, error) {
+	if len(h) == 0 {
+		return []byte{}, nil
+	}
+	return e.marshal(h)
+}
+
+// MarshalCBOR encodes the value using the encoding CBOR options such as the map key order,
+// errors and encoder panics are returned as ErrCBOREncode.
+func (e *Encoding) MarshalCBOR(v interface{}) ([]byte, error) {
+	return e.marshal(v)
+}
+
+// UnmarshalCBOR decodes the data into the value using the encoding CBOR options such as
+// rejecting duplicate map keys, errors and decoder panics are returned as ErrCBORDecode.
+func (e *Encoding) UnmarshalCBOR(data []byte, v interface{}) error {
+	return e.unmarshal(stageValue, data, v)
+}
+
+// marshal encodes the value recovering from encoder panics.
 func (e *Encoding) marshal(o interface{}) (b []byte, err error) {
 	defer func() {
-		// Need to recover from panic
 		if r := recover(); r != nil {
-			b = nil
+			b, err = nil, ErrCBOREncode{fmt.Errorf("cbor: %v", r)}
+		}
+	}()
+	if b, err = e.encMode.Marshal(o); err != nil {
+		return nil, ErrCBOREncode{err}
+	}
+	return b, nil
+}
+
+// outerTagged returns the tagged message structure wrapped in the outer tag if set.
+func (e *Encoding) outerTagged(tag uint64, m interface{}) cbor.Tag {
+	t := cbor.Tag{Number: tag, Content: m}
+	if e.outerTag == 0 {
+		return t
+	}
+	return cbor.Tag{Number: e.outerTag, Content: t}
+}
+
+// rawTag is a CBOR tag with the content referring to the decoded data.
+type rawTag struct {
+	Number  uint64
+	Content []byte
+}
+
+// parseTag parses the CBOR tag head without copying the tag content.
+func parseTag(data []byte) (rawTag, error) {
+	if len(data) == 0 {
+		return rawTag{}, io.EOF
+	}
+	if data[0]>>5 != 6 {
+		return rawTag{}, errors.New("cbor: COSE message must be a tagged data item")
+	}
+	number, n, err := parseHeadArgument(data)
+	if err != nil {
+		return rawTag{}, err
+	}
+	if len(data) == n {
+		return rawTag{}, io.ErrUnexpectedEOF
+	}
+	return rawTag{Number: number, Content: data[n:]}, nil
+}
+
+// itemLength returns the length of the first CBOR data item of the data.
+func itemLength(data []byte) (int, error) {
+	item := itemEnd{capacity: cap(data)}
+	if err := headerDecMode.Unmarshal(data, &item); err != nil {
+		return 0, err
+	}
+	return item.end, nil
+}
+
+// itemEnd records the end offset of the decoded data item.
+type itemEnd struct {
+	capacity int
+	end      int
+}
+
+// UnmarshalCBOR receives the data item as a slice of the decoded data,
+// the offset of the slice is the difference of the capacities.
+func (i *itemEnd) UnmarshalCBOR(data []byte) error {
+	i.end = i.capacity - cap(data) + len(data)
+	return nil
+}
+
+// parseHeadArgument returns the argument of the CBOR data item head and the head length.
+func parseHeadArgument(data []byte) (uint64, int, error) {
+	ai := data[0] & 0x1f
+	if ai < 24 {
+		return uint64(ai), 1, nil
+	}
+	if ai > 27 {
+		return 0, 0, errors.New("cbor: invalid additional information " + strconv.Itoa(int(ai)))
+	}
+	n := 1 << (ai - 24)
+	if len(data) < 1+n {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	var v uint64
+	for _, b := range data[1 : 1+n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, 1 + n, nil
+}
+
+// decodeError classifies the error of decoding the message structure.
+func decodeError(err error) error {
+	err = headersDecodeError(err)
+	if _, ok := err.(ErrMalformedHeaders); ok {
+		return err
+	}
+	return ErrInvalidMessageStructure{err}
+}
+
+// Decoding stages reported by ErrCBORDecode
+const (
+	stageOuterTag          = "outer tag"
+	stageProtectedHeader   = "protected header"
+	stageUnprotectedHeader = "unprotected header"
+	stageMessageBody       = "message body"
+	stageContent           = "content"
+	stageClaims            = "CWT claims"
+	stageValue             = "value"
+)
+
+// unmarshal decodes the data recovering from decoder panics on malformed input,
+// errors are returned as ErrCBORDecode with the given decoding stage.
+func (e *Encoding) unmarshal(stage string, data []byte, v interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
 			switch x := r.(type) {
 			case error:
-				err = x
+				err = fmt.Errorf("cbor: %w", x)
 			default:
 				err = fmt.Errorf("cbor: %v", x)
 			}
 		}
+		if err != nil {
+			err = ErrCBORDecode{Cause: err, Stage: stage}
+		}
 	}()
-	return e.encMode.Marshal(o)
+	return e.decMode.Unmarshal(data, v)
 }
 
 func init() {
 	if stdEncodingErr != nil {
 		panic(stdEncodingErr)
 	}
+	if relaxedEncodingErr != nil {
+		panic(relaxedEncodingErr)
+	}
 }
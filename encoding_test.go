@@ -5,16 +5,23 @@
 package cose
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"fmt"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestEncoding_Encode(t *testing.T) {
+	requireRSA(t)
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
@@ -25,7 +32,7 @@ func TestEncoding_Encode(t *testing.T) {
 	msg.SetContent([]byte("test"))
 	signer, err := NewSigner(AlgorithmPS256, key)
 	require.NoError(t, err)
-	msg.SetSigner(signer)
+	require.NoError(t, msg.SetSigner(signer))
 
 	b, err := StdEncoding.Encode(msg)
 	require.NoError(t, err)
@@ -46,6 +53,7 @@ func TestEncoding_Encode(t *testing.T) {
 }
 
 func TestEncoding_DecodeErrorWithoutVerifier(t *testing.T) {
+	requireRSA(t)
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
@@ -56,7 +64,7 @@ func TestEncoding_DecodeErrorWithoutVerifier(t *testing.T) {
 	msg.SetContent([]byte("test"))
 	signer, err := NewSigner(AlgorithmPS256, key)
 	require.NoError(t, err)
-	msg.SetSigner(signer)
+	require.NoError(t, msg.SetSigner(signer))
 
 	b, err := StdEncoding.Encode(msg)
 	require.NoError(t, err)
@@ -72,6 +80,7 @@ func TestEncoding_DecodeErrorWithoutVerifier(t *testing.T) {
 }
 
 func TestEncoding_EncodeMultipeSigners(t *testing.T) {
+	requireRSA(t)
 	key1, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
@@ -126,6 +135,7 @@ func TestEncoding_EncodeMultipeSigners(t *testing.T) {
 }
 
 func TestEncoding_DecodeInvalidVerifier(t *testing.T) {
+	requireRSA(t)
 	key1, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
 		panic(err)
@@ -165,3 +175,1815 @@ func TestEncoding_DecodeInvalidVerifier(t *testing.T) {
 	assert.Error(t, err, ErrVerification)
 	assert.Equal(t, msg.GetContent(), dec.GetContent())
 }
+
+func TestEncoding_DecodeUnexpectedMessageTag(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	msg.AddSigner(signer)
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.Decode(b, &Config{
+		ExpectedMessageTags: []uint64{MessageTagSign1},
+	})
+	assert.Nil(t, dec)
+	var tagErr ErrUnexpectedMessageTag
+	require.ErrorAs(t, err, &tagErr)
+	assert.Equal(t, uint64(MessageTagSign), tagErr.Tag)
+	assert.Equal(t, []uint64{MessageTagSign1}, tagErr.Expected)
+}
+
+func TestEncoding_DecodeExpectedMessageTag(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.Decode(b, &Config{
+		ExpectedMessageTags: []uint64{MessageTagSign1},
+		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+			verifier, err := signer.ToVerifier()
+			if err != nil {
+				return nil, err
+			}
+			return []*Verifier{verifier}, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+}
+
+func staticVerifier(t testing.TB, signer *Signer) func(*Headers) ([]*Verifier, error) {
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	return func(*Headers) ([]*Verifier, error) {
+		return []*Verifier{verifier}, nil
+	}
+}
+
+func TestEncoding_EncodeMessageExternalAAD(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	msg.SetExternalAAD([]byte("message aad"))
+	assert.Equal(t, []byte("message aad"), msg.GetExternalAAD())
+
+	// Message external data is used when not given explicitly
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	dec, err := StdEncoding.DecodeWithExternal(b, []byte("message aad"), config)
+	require.NoError(t, err)
+	assert.Nil(t, dec.(*Sign1Message).GetExternalAAD())
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	// Explicit external data takes priority
+	b, err = StdEncoding.EncodeWithExternal(msg, []byte("explicit aad"))
+	require.NoError(t, err)
+	_, err = StdEncoding.DecodeWithExternal(b, []byte("explicit aad"), config)
+	require.NoError(t, err)
+}
+
+func TestEncoding_ReverifyWithExternal(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte("aad")))
+
+	sign1 := NewSign1Message()
+	sign1.SetContent([]byte("test"))
+	require.NoError(t, sign1.SetSigner(signer))
+	sign := NewSignMessage()
+	sign.SetContent([]byte("test"))
+	sign.AddSigner(signer)
+
+	for _, msg := range []Message{sign1, sign} {
+		b, err := StdEncoding.EncodeWithExternal(msg, []byte("aad"))
+		require.NoError(t, err)
+
+		// External data is not known before inspecting headers
+		dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+		require.ErrorIs(t, err, ErrVerification)
+
+		var reverify func([]byte) error
+		switch m := dec.(type) {
+		case *Sign1Message:
+			reverify = m.ReverifyWithExternal
+		case *SignMessage:
+			reverify = m.ReverifyWithExternal
+		}
+		assert.ErrorIs(t, reverify([]byte("wrong")), ErrVerification)
+		assert.NoError(t, reverify([]byte("aad")))
+	}
+
+	assert.ErrorIs(t, sign1.ReverifyWithExternal([]byte("aad")), ErrMessageNotDecoded)
+	assert.ErrorIs(t, sign.ReverifyWithExternal([]byte("aad")), ErrMessageNotDecoded)
+}
+
+func TestSign1Message_GetSetSigner(t *testing.T) {
+	msg := NewSign1Message()
+	assert.Nil(t, msg.GetSigner())
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, msg.SetSigner(signer))
+	assert.Same(t, signer, msg.GetSigner())
+
+	msg.SetContent([]byte("test"))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	// Decoded messages have no signer and can not be encoded again
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	decoded := dec.(*Sign1Message)
+	assert.Nil(t, decoded.GetSigner())
+	_, err = StdEncoding.Encode(decoded)
+	assert.ErrorIs(t, err, ErrNoSigner)
+	_, err = StdEncoding.Encode(NewSign1Message())
+	assert.ErrorIs(t, err, ErrNoSigner)
+	_, err = StdEncoding.Encode(NewSignMessage())
+	assert.ErrorIs(t, err, ErrNoSigner)
+
+	require.NoError(t, msg.SetSigner(nil))
+	assert.Nil(t, msg.GetSigner())
+	assert.NotPanics(t, func() {
+		_, err = StdEncoding.Encode(msg)
+	})
+	assert.ErrorIs(t, err, ErrNoSigner)
+
+	require.NoError(t, decoded.SetSigner(signer))
+	_, err = StdEncoding.Encode(decoded)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_DecodeWithStatus(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.DecodeWithStatus(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	assert.True(t, dec.Verified)
+	assert.Equal(t, msg.GetContent(), dec.Message.GetContent())
+
+	dec, err = StdEncoding.DecodeWithStatus(b, nil)
+	assert.ErrorIs(t, err, ErrVerification)
+	require.NotNil(t, dec)
+	assert.False(t, dec.Verified)
+	assert.Equal(t, msg.GetContent(), dec.Message.GetContent())
+
+	dec, err = StdEncoding.DecodeWithStatus([]byte{0xd2, 0x80}, nil)
+	assert.Error(t, err)
+	assert.Nil(t, dec)
+}
+
+func TestEncoding_DecodeCWTTag(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	cwt := append([]byte{0xd8, 0x3d}, b...)
+
+	dec, err := StdEncoding.Decode(cwt, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+
+	_, err = StdEncoding.Decode(cwt, &Config{UnwrapCWTTag: Bool(false)})
+	assert.ErrorIs(t, err, ErrUnsupportedMessageTag{MessageTagCWT})
+
+	_, err = StdEncoding.Decode(cwt, &Config{ExpectedMessageTags: []uint64{MessageTagSign}})
+	var tagErr ErrUnexpectedMessageTag
+	require.ErrorAs(t, err, &tagErr)
+	assert.Equal(t, uint64(MessageTagSign1), tagErr.Tag)
+
+	_, err = StdEncoding.Decode(append([]byte{0xd8, 0x3d}, cwt...), nil)
+	assert.ErrorIs(t, err, ErrUnsupportedMessageTag{MessageTagCWT})
+
+	_, err = StdEncoding.Decode(append([]byte{0xd8, 0x3d}, b[1:]...), nil)
+	assert.Error(t, err)
+}
+
+func TestEncoding_EncodeStrictHeaders(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte("signer")))
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("message")))
+	require.NoError(t, msg.SetSigner(signer))
+
+	_, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	enc, err := NewEncoding(WithStrictHeaders())
+	require.NoError(t, err)
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrHeaderConflict{Label: int64(4)})
+
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("signer")))
+	require.NoError(t, msg.Headers.SetProtected(HeaderAlgorithm, AlgorithmPS256))
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrHeaderConflict{Label: int64(1)})
+
+	require.NoError(t, msg.Headers.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	_, err = enc.Encode(msg)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_WithoutAlgorithmHeader(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"), WithoutAlgorithmHeader())
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	// Tag 18, array of 4 items, empty protected headers as zero-length byte string
+	assert.Equal(t, []byte{0xd2, 0x84, 0x40, 0xa0}, b[:4])
+
+	verifier, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	getVerifiers := func(*Headers) ([]*Verifier, error) {
+		return []*Verifier{verifier}, nil
+	}
+
+	_, err = StdEncoding.Decode(b, &Config{GetVerifiers: getVerifiers})
+	assert.ErrorIs(t, err, ErrMissingAlgorithmHeader)
+
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: getVerifiers, RequireAlgorithmHeader: Bool(false)})
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+}
+
+// craftSign1 returns a COSE_Sign1 message signed by the signer with the given header maps
+// encoded as is, nil protected headers are encoded as a zero-length byte string.
+func craftSign1(t *testing.T, signer *Signer, protected, unprotected map[interface{}]interface{}) []byte {
+	m := sign1Message{Protected: []byte{}, Unprotected: unprotected, Payload: []byte("test")}
+	var err error
+	if protected != nil {
+		m.Protected, err = StdEncoding.marshal(protected)
+		require.NoError(t, err)
+	}
+	digest, err := m.GetDigest(StdEncoding, nil)
+	require.NoError(t, err)
+	m.Signature, err = signer.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+	b, err := StdEncoding.marshal(cbor.Tag{Number: MessageTagSign1, Content: m})
+	require.NoError(t, err)
+	return b
+}
+
+func TestEncoding_DecodeUnprotectedAlgorithm(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	getVerifiers := staticVerifier(t, signer)
+	craft := func(protected, unprotected map[interface{}]interface{}) []byte {
+		return craftSign1(t, signer, protected, unprotected)
+	}
+
+	tests := []struct {
+		name        string
+		protected   map[interface{}]interface{}
+		unprotected map[interface{}]interface{}
+		strictErr   error
+	}{
+		{"protected", map[interface{}]interface{}{int64(1): int64(-7)}, map[interface{}]interface{}{}, nil},
+		{"unprotected", nil, map[interface{}]interface{}{int64(1): int64(-7)}, ErrAlgorithmNotProtected},
+		// Protected alg header is authoritative
+		{"both", map[interface{}]interface{}{int64(1): int64(-7)}, map[interface{}]interface{}{int64(1): int64(-37)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := craft(tt.protected, tt.unprotected)
+
+			dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: getVerifiers})
+			if tt.strictErr != nil {
+				assert.ErrorIs(t, err, tt.strictErr)
+			} else {
+				require.NoError(t, err)
+				alg, err := dec.(*Sign1Message).Headers.GetProtected(HeaderAlgorithm)
+				require.NoError(t, err)
+				assert.Equal(t, "ES256", alg)
+			}
+
+			dec, err = StdEncoding.Decode(b, &Config{GetVerifiers: getVerifiers, RequireProtectedAlgorithm: Bool(false)})
+			require.NoError(t, err)
+			alg, err := dec.(*Sign1Message).Headers.Get(HeaderAlgorithm)
+			require.NoError(t, err)
+			assert.Equal(t, "ES256", alg)
+		})
+	}
+
+	// Tolerated unprotected alg header still selects the verifiers
+	b := craft(nil, map[interface{}]interface{}{int64(1): int64(-37)})
+	_, err = StdEncoding.Decode(b, &Config{GetVerifiers: getVerifiers, RequireProtectedAlgorithm: Bool(false)})
+	assert.ErrorIs(t, err, ErrVerification)
+}
+
+func TestEncoding_DecodeNullHeaders(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+
+	// Resolver rejecting null kid and falling back to another lookup for absent kid
+	var fallback bool
+	config := &Config{GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+		kid, present, err := headers.Lookup(HeaderKeyID)
+		if err != nil {
+			return nil, err
+		}
+		if !present {
+			fallback = true
+			return []*Verifier{verifier}, nil
+		}
+		if kid == nil {
+			return nil, errors.New("null kid")
+		}
+		return []*Verifier{verifier}, nil
+	}}
+	alg := map[interface{}]interface{}{int64(1): int64(-7)}
+
+	for _, tt := range []struct {
+		name        string
+		protected   map[interface{}]interface{}
+		unprotected map[interface{}]interface{}
+	}{
+		{"protected", map[interface{}]interface{}{int64(1): int64(-7), int64(4): nil}, map[interface{}]interface{}{}},
+		{"unprotected", alg, map[interface{}]interface{}{int64(4): nil}},
+	} {
+		t.Run("null kid "+tt.name, func(t *testing.T) {
+			b := craftSign1(t, signer, tt.protected, tt.unprotected)
+			msg, err := StdEncoding.Decode(b, config)
+			assert.EqualError(t, err, "null kid")
+			require.NotNil(t, msg)
+			headers := msg.(*Sign1Message).Headers
+			assert.True(t, headers.Has(HeaderKeyID))
+			kid, err := headers.Get(HeaderKeyID)
+			require.NoError(t, err)
+			assert.Nil(t, kid)
+		})
+	}
+
+	t.Run("absent kid", func(t *testing.T) {
+		fallback = false
+		msg, err := StdEncoding.Decode(craftSign1(t, signer, alg, map[interface{}]interface{}{}), config)
+		require.NoError(t, err)
+		assert.True(t, fallback)
+		headers := msg.(*Sign1Message).Headers
+		assert.False(t, headers.Has(HeaderKeyID))
+		_, err = headers.Get(HeaderKeyID)
+		assert.ErrorIs(t, err, ErrHeaderNotFound)
+		_, err = headers.ProtectedOnly().Get(HeaderKeyID)
+		assert.ErrorIs(t, err, ErrHeaderNotFound)
+		_, err = headers.Unprotected().Get(HeaderKeyID)
+		assert.ErrorIs(t, err, ErrHeaderNotFound)
+	})
+
+	t.Run("null alg", func(t *testing.T) {
+		b := craftSign1(t, signer, map[interface{}]interface{}{int64(1): nil}, map[interface{}]interface{}{})
+		_, err := StdEncoding.Decode(b, config)
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+		_, err = StdEncoding.Decode(b, &Config{GetVerifiers: config.GetVerifiers, RequireAlgorithmHeader: Bool(false)})
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+
+		b = craftSign1(t, signer, nil, map[interface{}]interface{}{int64(1): nil})
+		_, err = StdEncoding.Decode(b, &Config{GetVerifiers: config.GetVerifiers, RequireProtectedAlgorithm: Bool(false)})
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+	})
+}
+
+func TestEncoding_DecodeAlgorithmMismatch(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	verifier, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa2048"))
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return []*Verifier{verifier}, nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrVerification)
+}
+
+func TestSign1Message_VerifySignatureOnly(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	assert.ErrorIs(t, msg.VerifySignatureOnly(verifier, nil), ErrMessageNotDecoded)
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	decoded := dec.(*Sign1Message)
+
+	decoded.SetContent(nil)
+	assert.NoError(t, decoded.VerifySignatureOnly(verifier, nil))
+	assert.ErrorIs(t, decoded.VerifySignatureOnly(verifier, []byte("aad")), ErrVerification)
+
+	decoded.raw.Signature[0] ^= 1
+	assert.ErrorIs(t, decoded.VerifySignatureOnly(verifier, nil), ErrVerification)
+
+	msg = NewSign1Message()
+	require.NoError(t, msg.SetSigner(signer))
+	b, err = StdEncoding.EncodeWithExternal(msg, []byte("aad"))
+	require.NoError(t, err)
+	dec, err = StdEncoding.DecodeWithExternal(b, []byte("aad"), &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	decoded = dec.(*Sign1Message)
+	assert.Nil(t, decoded.GetContent())
+	assert.NoError(t, decoded.VerifySignatureOnly(verifier, []byte("aad")))
+}
+
+func TestEncoding_ForbiddenAlgorithms(t *testing.T) {
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	enc, err := NewEncoding(WithForbiddenAlgorithms(AlgorithmPS256))
+	require.NoError(t, err)
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrForbiddenAlgorithm{AlgorithmPS256})
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = enc.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	assert.ErrorIs(t, err, ErrForbiddenAlgorithm{AlgorithmPS256})
+}
+
+func TestEncoding_ForbiddenAlgorithmsWithoutAlgorithmHeader(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"), WithoutAlgorithmHeader())
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	absent, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	unknown := craftSign1(t, signer, map[interface{}]interface{}{int64(1): int64(-65000)}, map[interface{}]interface{}{})
+
+	enc, err := NewEncoding(WithForbiddenAlgorithms(AlgorithmES256))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer), RequireAlgorithmHeader: Bool(false)}
+	for _, b := range [][]byte{absent, unknown} {
+		_, err = StdEncoding.Decode(b, config)
+		require.NoError(t, err)
+		_, err = enc.Decode(b, config)
+		assert.ErrorIs(t, err, ErrForbiddenAlgorithm{AlgorithmES256})
+	}
+}
+
+func TestEncoding_RequiredAlgorithms(t *testing.T) {
+	signer1, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"))
+	require.NoError(t, err)
+	signer2, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	enc, err := NewEncoding(WithRequiredAlgorithms(AlgorithmES256, AlgorithmES384))
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	msg.AddSigner(signer1)
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrRequiredAlgorithm)
+
+	msg.AddSigner(signer2)
+	_, err = enc.Encode(msg)
+	assert.NoError(t, err)
+}
+
+// nonCanonicalProtected is {4: h'6b6964', 1: -7} with reversed key order and non-minimal encoding of label 1.
+const nonCanonicalProtected = "a204436b6964180126"
+
+func TestEncoding_DecodeSign1NonCanonicalProtected(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	c := &sign1Message{
+		Protected:   mustHex(t, nonCanonicalProtected),
+		Unprotected: map[interface{}]interface{}{},
+		Payload:     []byte("test"),
+	}
+	digest, err := c.GetDigest(StdEncoding, nil)
+	require.NoError(t, err)
+	c.Signature, err = signer.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+
+	b, err := StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign1, Content: c})
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	msg := dec.(*Sign1Message)
+	kid, err := msg.Headers.GetProtected(HeaderKeyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("kid"), kid)
+
+	// Re-encoded protected headers differ from the received bytes
+	ph, err := StdEncoding.marshalProtected(msg.Headers.protected)
+	require.NoError(t, err)
+	assert.NotEqual(t, mustHex(t, nonCanonicalProtected), ph)
+
+	assert.NoError(t, msg.ReverifyWithExternal(nil))
+}
+
+func TestEncoding_DecodeSignNonCanonicalProtected(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	c := &signMessage{
+		// {3: "text/plain"} with non-minimal encoding of label 3
+		Protected:   mustHex(t, "a11803"+"6a746578742f706c61696e"),
+		Unprotected: map[interface{}]interface{}{},
+		Payload:     []byte("test"),
+	}
+	sig := &signMessageSignature{
+		Protected:   mustHex(t, nonCanonicalProtected),
+		Unprotected: map[interface{}]interface{}{},
+	}
+	digest, err := c.GetDigest(StdEncoding, sig.Protected, nil)
+	require.NoError(t, err)
+	sig.Signature, err = signer.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+	c.Signatures = []*signMessageSignature{sig}
+
+	b, err := StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign, Content: c})
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	ct, err := dec.(*SignMessage).Headers.GetProtected(HeaderContentType)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", ct)
+}
+
+func TestEncoding_EncodeAssembled(t *testing.T) {
+	signer1, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	signer2, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	msg.SetExternalAAD([]byte("external"))
+
+	_, err = StdEncoding.EncodeAssembled(msg)
+	assert.ErrorIs(t, err, ErrNoSigner)
+
+	// Detached signing service signs the Sig_structure without sharing its key
+	protected := mustHex(t, "a10126")
+	toBeSigned, err := msg.SigStructureFor(StdEncoding, protected, []byte("external"))
+	require.NoError(t, err)
+	signature, err := signer1.Sign(rand.Reader, toBeSigned)
+	require.NoError(t, err)
+
+	assert.Error(t, msg.AddSignature([]byte{0x01}, nil, signature))
+	assert.Error(t, msg.AddSignature(protected, nil, nil))
+	require.NoError(t, msg.AddSignature(protected, map[interface{}]interface{}{int64(4): []byte("kid")}, signature))
+
+	// Signers are signed at encode time
+	msg.AddSigner(signer2)
+
+	b, err := StdEncoding.EncodeAssembled(msg)
+	require.NoError(t, err)
+
+	verifier1, err := signer1.ToVerifier()
+	require.NoError(t, err)
+	verifier2, err := signer2.ToVerifier()
+	require.NoError(t, err)
+	var verified []*Verifier
+	dec, err := StdEncoding.DecodeWithExternal(b, []byte("external"), &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return []*Verifier{verifier1, verifier2}, nil
+		},
+		Verified: func(v *Verifier) {
+			verified = append(verified, v)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*Verifier{verifier1, verifier2}, verified)
+
+	raw := dec.(*SignMessage).raw
+	require.Len(t, raw.Signatures, 2)
+	assert.Equal(t, protected, raw.Signatures[0].Protected)
+	assert.Equal(t, signature, raw.Signatures[0].Signature)
+
+	enc, err := NewEncoding(WithForbiddenAlgorithms(AlgorithmES256))
+	require.NoError(t, err)
+	_, err = enc.EncodeAssembled(msg)
+	assert.ErrorIs(t, err, ErrForbiddenAlgorithm{AlgorithmES256})
+}
+
+func TestEncoding_DecodePayloadAliasing(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+
+	config.CopyPayload = true
+	decCopy, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+
+	i := bytes.Index(b, []byte("test"))
+	require.True(t, i > 0)
+	b[i] = 'b'
+	assert.Equal(t, []byte("best"), dec.GetContent())
+	assert.Equal(t, []byte("test"), decCopy.GetContent())
+}
+
+func BenchmarkEncoding_DecodeLargePayload(b *testing.B) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(b, "ecdsa256"))
+	require.NoError(b, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(b, err)
+
+	msg := NewSign1Message()
+	msg.SetContent(make([]byte, 32<<20))
+	require.NoError(b, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(b, err)
+
+	config := &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return []*Verifier{verifier}, nil
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := StdEncoding.Decode(data, config); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncoding_DecodeRelaxed(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	c := &sign1Message{
+		Protected: mustHex(t, "a10126"),
+		Payload:   []byte("test"),
+	}
+	digest, err := c.GetDigest(StdEncoding, nil)
+	require.NoError(t, err)
+	sig, err := signer.Sign(rand.Reader, digest)
+	require.NoError(t, err)
+	require.Len(t, sig, 64)
+
+	var b []byte
+	b = append(b, mustHex(t, "d28443a10126")...)
+	// Indefinite length map {"b": 1, "a": 2} with unsorted keys
+	b = append(b, mustHex(t, "bf616201616102ff")...)
+	// Indefinite length byte string "test" in two chunks
+	b = append(b, mustHex(t, "5f427465427374ff")...)
+	b = append(b, 0x58, 0x40)
+	b = append(b, sig...)
+
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	_, err = StdEncoding.Decode(b, config)
+	assert.Error(t, err)
+
+	dec, err := RelaxedEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), dec.GetContent())
+	v, err := dec.(*Sign1Message).Headers.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+}
+
+func TestEncoding_EncodeRelaxedCanonical(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.Headers.Set("b", 1))
+	require.NoError(t, msg.Headers.Set("a", 2))
+	require.NoError(t, msg.SetSigner(signer))
+
+	std, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	relaxed, err := RelaxedEncoding.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, std, relaxed)
+}
+
+func TestEncoding_DecodeRequireProtectedKeyID(t *testing.T) {
+	signer1, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	signer2, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256-2"))
+	require.NoError(t, err)
+	verifiers := map[string]*Verifier{}
+	verifiers["1"], err = signer1.ToVerifier()
+	require.NoError(t, err)
+	verifiers["2"], err = signer2.ToVerifier()
+	require.NoError(t, err)
+
+	require.NoError(t, signer1.Headers.SetProtected(HeaderKeyID, []byte("1")))
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer1))
+	// Unprotected kid added in transit
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("2")))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	var resolved []interface{}
+	config := &Config{
+		RequireProtectedKeyID: true,
+		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+			assert.Empty(t, headers.ProtectedOnly().unprotected)
+			kid, _, err := headers.Unprotected().Lookup(HeaderKeyID)
+			require.NoError(t, err)
+			resolved = append(resolved, kid)
+			kid, err = headers.Get(HeaderKeyID)
+			require.NoError(t, err)
+			return []*Verifier{verifiers[string(kid.([]byte))]}, nil
+		},
+	}
+	_, err = StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{nil}, resolved)
+
+	signer2.Headers = NewHeaders()
+	require.NoError(t, signer2.Headers.Set(HeaderKeyID, []byte("2")))
+	msg = NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer2))
+	b, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrUnprotectedKeyID)
+
+	config.RequireProtectedKeyID = false
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_DecodeVerifierTimeout(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	verifier, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	defer close(release)
+	config := &Config{
+		VerifierTimeout: 10 * time.Millisecond,
+		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+			<-release
+			return nil, nil
+		},
+	}
+	start := time.Now()
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	config.GetVerifiersWithContext = func(ctx context.Context, headers *Headers) ([]*Verifier, error) {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return []*Verifier{verifier}, nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+
+	config.GetVerifiersWithContext = func(ctx context.Context, headers *Headers) ([]*Verifier, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	config.VerifierTimeout = 0
+	config.GetVerifiersWithContext = nil
+	config.GetVerifiers = func(headers *Headers) ([]*Verifier, error) {
+		return []*Verifier{verifier}, nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_DecodeInvalidMessageStructure(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x84, 0x40, 0xa0, 0x40, 0x40},
+		{0xd2},
+		{0xd2, 0x83, 0x40, 0xa0, 0x40},
+		{0xd8, 0x3d, 0xd2, 0x40},
+	} {
+		_, err := StdEncoding.Decode(data, nil)
+		var serr ErrInvalidMessageStructure
+		assert.True(t, errors.As(err, &serr), "%x: %v", data, err)
+	}
+
+	_, err := StdEncoding.Decode([]byte{0xd2, 0x84, 0x40, 0xa2, 0x04, 0x40, 0x04, 0x40, 0x40, 0x40}, nil)
+	assert.Equal(t, ErrMalformedHeaders{Label: int64(4)}, err)
+}
+
+func TestEncoding_CBORErrors(t *testing.T) {
+	tests := []struct {
+		data  []byte
+		stage string
+	}{
+		{[]byte{}, "outer tag"},
+		{[]byte{0xd2}, "outer tag"},
+		{[]byte{0xd8, 0x3d, 0xd2}, "outer tag"},
+		{[]byte{0xd2, 0x84, 0x40, 0xa0, 0x40}, "message body"},
+		{[]byte{0xd2, 0x83, 0x40, 0xa0, 0x40}, "message body"},
+		{[]byte{0xd2, 0x84, 0x42, 0xa1, 0xff, 0xa0, 0x40, 0x40}, "protected header"},
+		{[]byte{0xd8, 0x62, 0x84, 0x41, 0xa1, 0xa0, 0x40, 0x80}, "protected header"},
+	}
+	for _, tt := range tests {
+		_, err := StdEncoding.Decode(tt.data, nil)
+		var cerr ErrCBORDecode
+		if assert.True(t, errors.As(err, &cerr), "%x: %v", tt.data, err) {
+			assert.Equal(t, tt.stage, cerr.Stage, "%x", tt.data)
+			assert.Error(t, errors.Unwrap(cerr))
+		}
+	}
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	require.NoError(t, msg.Headers.SetProtected("unsupported", make(chan int)))
+	require.NoError(t, msg.SetSigner(signer))
+	_, err = StdEncoding.Encode(msg)
+	var eerr ErrCBOREncode
+	assert.True(t, errors.As(err, &eerr), "%v", err)
+}
+
+func TestEncoding_DecodeWithContext(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	type bindingKey struct{}
+	factory := func(ctx context.Context) ([]byte, error) {
+		binding, ok := ctx.Value(bindingKey{}).([]byte)
+		if !ok {
+			return nil, errors.New("no channel binding")
+		}
+		return binding, nil
+	}
+	ctx := context.WithValue(context.Background(), bindingKey{}, []byte("exported keying material"))
+	aad, err := factory(ctx)
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("bound"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.EncodeWithExternal(msg, aad)
+	require.NoError(t, err)
+
+	config := &Config{GetVerifiers: staticVerifier(t, signer), ExternalAADFromContext: factory}
+	dec, err := StdEncoding.DecodeWithContext(ctx, b, []byte("ignored"), config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bound"), dec.GetContent())
+
+	// Different channel binding fails verification
+	other := context.WithValue(context.Background(), bindingKey{}, []byte("other connection"))
+	_, err = StdEncoding.DecodeWithContext(other, b, nil, config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	_, err = StdEncoding.DecodeWithContext(context.Background(), b, aad, config)
+	assert.EqualError(t, err, "no channel binding")
+
+	// Given external data is used without the factory
+	config.ExternalAADFromContext = nil
+	_, err = StdEncoding.DecodeWithContext(ctx, b, aad, config)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_DecodeWithContextResolvesVerifiers(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	type requestKey struct{}
+	ctx := context.WithValue(context.Background(), requestKey{}, "request")
+	config := &Config{
+		VerifierTimeout: time.Minute,
+		GetVerifiersWithContext: func(ctx context.Context, headers *Headers) ([]*Verifier, error) {
+			assert.Equal(t, "request", ctx.Value(requestKey{}))
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return []*Verifier{verifier}, nil
+		},
+	}
+	_, err = StdEncoding.DecodeWithContext(ctx, b, nil, config)
+	assert.NoError(t, err)
+
+	// Cancelling the context stops the lookup with or without the timeout
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = StdEncoding.DecodeWithContext(cancelled, b, nil, config)
+	assert.ErrorIs(t, err, context.Canceled)
+	config.VerifierTimeout = 0
+	_, err = StdEncoding.DecodeWithContext(cancelled, b, nil, config)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEncoding_DecodeMultiVerificationError(t *testing.T) {
+	keys := []string{"ecdsa256", "ecdsa256-2", "ed25519"}
+	algs := []Algorithm{AlgorithmES256, AlgorithmES256, AlgorithmEdDSA}
+	verifiers := make(map[string]*Verifier)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	for i, key := range keys {
+		signer, err := NewSigner(algs[i], getPrivateKey(t, key))
+		require.NoError(t, err)
+		require.NoError(t, signer.Headers.SetProtected(HeaderKeyID, []byte(key)))
+		msg.AddSigner(signer)
+		verifiers[key], err = signer.ToVerifier()
+		require.NoError(t, err)
+	}
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	raw, err := parseTag(b)
+	require.NoError(t, err)
+	var c signMessage
+	require.NoError(t, StdEncoding.unmarshal(stageMessageBody, raw.Content, &c))
+	c.Signatures[0].Signature[0] ^= 0xff
+	c.Signatures[2].Signature[0] ^= 0xff
+	b, err = StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign, Content: c})
+	require.NoError(t, err)
+
+	config := &Config{
+		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+			kid, err := headers.Get(HeaderKeyID)
+			if err != nil {
+				return nil, err
+			}
+			return []*Verifier{verifiers[string(kid.([]byte))]}, nil
+		},
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrVerification)
+	var merr *MultiVerificationError
+	require.ErrorAs(t, err, &merr)
+	require.Len(t, merr.Errors, 2)
+	for i, idx := range []int{0, 2} {
+		assert.Equal(t, idx, merr.Errors[i].Index)
+		assert.ErrorIs(t, merr.Errors[i].Err, ErrVerification)
+		kid, err := merr.Errors[i].SignerHeaders.GetProtected(HeaderKeyID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(keys[idx]), kid)
+	}
+
+	config.RequireAllSignatures = Bool(false)
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+
+	c.Signatures[1].Signature[0] ^= 0xff
+	b, err = StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign, Content: c})
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, config)
+	require.ErrorAs(t, err, &merr)
+	assert.Len(t, merr.Errors, 3)
+}
+
+func TestEncoding_DecodeRequireDeterministicEncoding(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{
+		GetVerifiers:                 staticVerifier(t, signer),
+		RequireDeterministicEncoding: true,
+	}
+
+	encode := func(protected []byte, payload []byte) []byte {
+		c := &sign1Message{
+			Protected:   protected,
+			Unprotected: map[interface{}]interface{}{},
+			Payload:     payload,
+		}
+		digest, err := c.GetDigest(StdEncoding, nil)
+		require.NoError(t, err)
+		c.Signature, err = signer.Sign(rand.Reader, digest)
+		require.NoError(t, err)
+		b, err := StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign1, Content: c})
+		require.NoError(t, err)
+		return b
+	}
+
+	_, err = StdEncoding.Decode(encode(mustHex(t, "a10126"), []byte("test")), config)
+	require.NoError(t, err)
+
+	// Reversed protected header map keys
+	b := encode(mustHex(t, "a20442313101"+"26"), []byte("test"))
+	_, err = StdEncoding.Decode(b, config)
+	var derr ErrNonDeterministicEncoding
+	require.ErrorAs(t, err, &derr)
+	assert.Equal(t, 5, derr.Offset)
+
+	// Payload length encoded in two bytes
+	b = encode(mustHex(t, "a10126"), []byte("test"))
+	b = append(append(append([]byte{}, b[:7]...), 0x59, 0x00, 0x04), b[8:]...)
+	_, err = StdEncoding.Decode(b, config)
+	require.ErrorAs(t, err, &derr)
+	assert.Equal(t, 7, derr.Offset)
+
+	config.RequireDeterministicEncoding = false
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+}
+
+func TestEncoding_WithCoreDeterministicEncoding(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.Headers.Set(int64(-1), 1))
+	require.NoError(t, msg.Headers.Set(int64(24), 1))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	assert.Error(t, CheckDeterministicEncoding(b))
+
+	enc, err := NewEncoding(WithCoreDeterministicEncoding())
+	require.NoError(t, err)
+	b, err = enc.Encode(msg)
+	require.NoError(t, err)
+	assert.NoError(t, CheckDeterministicEncoding(b))
+
+	_, err = enc.Decode(b, &Config{
+		GetVerifiers:                 staticVerifier(t, signer),
+		RequireDeterministicEncoding: true,
+	})
+	assert.NoError(t, err)
+}
+
+func TestEncoding_Sign1Detached(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("detached document"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, payload, err := StdEncoding.EncodeSign1Detached(msg, []byte("external"))
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), payload)
+	assert.False(t, bytes.Contains(b, payload))
+
+	dec, err := StdEncoding.DecodeSign1WithPayload(b, payload, []byte("external"), config)
+	require.NoError(t, err)
+	assert.Equal(t, payload, dec.GetContent())
+
+	_, err = StdEncoding.DecodeSign1WithPayload(b, payload, nil, config)
+	assert.ErrorIs(t, err, ErrVerification)
+	_, err = StdEncoding.DecodeSign1WithPayload(b, []byte("tampered document"), []byte("external"), config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	// Attached payload is not replaced
+	b, err = StdEncoding.EncodeWithExternal(msg, []byte("external"))
+	require.NoError(t, err)
+	_, err = StdEncoding.DecodeSign1WithPayload(b, payload, []byte("external"), config)
+	assert.Error(t, err)
+
+	smsg := NewSignMessage()
+	smsg.SetContent([]byte("test"))
+	smsg.AddSigner(signer)
+	b, err = StdEncoding.Encode(smsg)
+	require.NoError(t, err)
+	_, err = StdEncoding.DecodeSign1WithPayload(b, payload, nil, config)
+	assert.Equal(t, ErrUnexpectedMessageTag{Tag: MessageTagSign, Expected: []uint64{MessageTagSign1}}, err)
+}
+
+func TestEncoding_EstimateEncodedSize(t *testing.T) {
+	for _, tt := range vectorAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte(tt.key)))
+
+			for _, size := range []int{0, 23, 24, 255, 256, 3000} {
+				msg := NewSign1Message()
+				msg.SetContent(make([]byte, size))
+				require.NoError(t, msg.SetSigner(signer))
+				estimate, err := StdEncoding.EstimateEncodedSize(msg)
+				require.NoError(t, err)
+				b, err := StdEncoding.Encode(msg)
+				require.NoError(t, err)
+				assert.Equal(t, len(b), estimate, "sign1 payload size %d", size)
+
+				smsg := NewSignMessage()
+				smsg.SetContent(make([]byte, size))
+				smsg.AddSigner(signer)
+				smsg.AddSigner(signer)
+				estimate, err = StdEncoding.EstimateEncodedSize(smsg)
+				require.NoError(t, err)
+				b, err = StdEncoding.Encode(smsg)
+				require.NoError(t, err)
+				assert.Equal(t, len(b), estimate, "sign payload size %d", size)
+			}
+		})
+	}
+}
+
+func TestEncoding_EstimateEncodedSizeSpecialCases(t *testing.T) {
+	_, err := StdEncoding.EstimateEncodedSize(NewSign1Message())
+	assert.Error(t, err)
+
+	_, err = StdEncoding.EstimateEncodedSize(NewEncryptMessage())
+	assert.Equal(t, ErrUnsupportedMessageTag{MessageTagEncrypt}, err)
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	counterSigner, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.AddCounterSignature0(counterSigner))
+	estimate, err := StdEncoding.EstimateEncodedSize(msg)
+	require.NoError(t, err)
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, len(b), estimate)
+}
+
+func TestEncoding_EstimateSize(t *testing.T) {
+	assertEstimate := func(t *testing.T, msg Message) {
+		estimate, err := StdEncoding.EstimateSize(msg)
+		require.NoError(t, err)
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, estimate, len(b))
+		assert.LessOrEqual(t, estimate-len(b), 8)
+	}
+
+	for _, tt := range vectorAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			msg := NewSign1Message()
+			msg.SetContent(make([]byte, 300))
+			require.NoError(t, msg.SetSigner(signer))
+			assertEstimate(t, msg)
+		})
+	}
+	for _, alg := range []Algorithm{AlgorithmHMAC256_64, AlgorithmHMAC256, AlgorithmHMAC384, AlgorithmHMAC512} {
+		t.Run(string(alg), func(t *testing.T) {
+			macer, err := NewMACer(alg, make([]byte, 64))
+			require.NoError(t, err)
+			msg := NewMac0Message()
+			msg.SetContent(make([]byte, 300))
+			require.NoError(t, msg.SetMACer(macer))
+			assertEstimate(t, msg)
+		})
+	}
+	for _, alg := range []Algorithm{AlgorithmA128GCM, AlgorithmA192GCM, AlgorithmA256GCM} {
+		t.Run(string(alg), func(t *testing.T) {
+			msg := NewEncrypt0Message()
+			msg.SetContent(make([]byte, 300))
+			require.NoError(t, msg.SetAlgorithm(alg))
+			require.NoError(t, msg.SetKey(make([]byte, getAlg(string(alg)).KeySize/8)))
+			assertEstimate(t, msg)
+		})
+	}
+
+	_, err := StdEncoding.EstimateSize(NewEncryptMessage())
+	assert.Equal(t, ErrUnsupportedMessageTag{MessageTagEncrypt}, err)
+}
+
+func TestEncoding_DecodeValidatePayload(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	errTooLarge := errors.New("payload too large")
+	validate := func(content []byte, headers *Headers) error {
+		if len(content) > 64 {
+			return errTooLarge
+		}
+		var claims map[interface{}]interface{}
+		return cbor.Unmarshal(content, &claims)
+	}
+
+	claims, err := cbor.Marshal(map[int]interface{}{1: "issuer", 4: 1655280539})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		valid   bool
+		err     error
+	}{
+		{"cbor", claims, true, nil},
+		{"oversized", make([]byte, 65), false, errTooLarge},
+		{"not cbor", []byte("plain text"), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := NewSign1Message()
+			msg.SetContent(tt.payload)
+			require.NoError(t, msg.SetSigner(signer))
+			b, err := StdEncoding.Encode(msg)
+			require.NoError(t, err)
+
+			var received []byte
+			_, err = StdEncoding.Decode(b, &Config{
+				GetVerifiers: staticVerifier(t, signer),
+				ValidatePayload: func(content []byte, headers *Headers) error {
+					received = content
+					return validate(content, headers)
+				},
+			})
+			assert.Equal(t, tt.payload, received)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var rejected ErrPayloadRejected
+			require.ErrorAs(t, err, &rejected)
+			assert.NotErrorIs(t, err, ErrVerification)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+
+	t.Run("not called on verification failure", func(t *testing.T) {
+		msg := NewSign1Message()
+		msg.SetContent(claims)
+		require.NoError(t, msg.SetSigner(signer))
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+		b[len(b)-1] ^= 0xff
+
+		_, err = StdEncoding.Decode(b, &Config{
+			GetVerifiers: staticVerifier(t, signer),
+			ValidatePayload: func([]byte, *Headers) error {
+				t.Fatal("ValidatePayload called for unverified message")
+				return nil
+			},
+		})
+		assert.ErrorIs(t, err, ErrVerification)
+	})
+
+	t.Run("multiple signers", func(t *testing.T) {
+		msg := NewSignMessage()
+		msg.SetContent(make([]byte, 65))
+		msg.AddSigner(signer)
+		msg.AddSigner(signer)
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+
+		calls := 0
+		_, err = StdEncoding.Decode(b, &Config{
+			GetVerifiers: staticVerifier(t, signer),
+			ValidatePayload: func(content []byte, headers *Headers) error {
+				calls++
+				return validate(content, headers)
+			},
+		})
+		assert.Equal(t, 1, calls)
+		assert.ErrorIs(t, err, errTooLarge)
+	})
+}
+
+func TestEncoding_DecodeMessageTagErrors(t *testing.T) {
+	content := []byte{0x84, 0x40, 0xa0, 0x40, 0x40}
+	tagged := func(tag uint64) []byte {
+		b, err := cbor.Marshal(cbor.RawTag{Number: tag, Content: content})
+		require.NoError(t, err)
+		return b
+	}
+
+	tests := []struct {
+		tag uint64
+		err string
+	}{
+		{MessageTagMAC, "unsupported COSE message tag: 97 (COSE_Mac)"},
+		{MessageTagCWT, "unsupported COSE message tag: 61"},
+		{24, "unsupported COSE message tag: 24"},
+		{9999, "unsupported COSE message tag: 9999"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.tag), func(t *testing.T) {
+			_, err := StdEncoding.Decode(tagged(tt.tag), &Config{UnwrapCWTTag: Bool(false)})
+			assert.Equal(t, ErrUnsupportedMessageTag{tt.tag}, err)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	for _, tag := range []uint64{MessageTagSign1, MessageTagSign, MessageTagEncrypt, MessageTagEncrypt0, MessageTagMAC0} {
+		_, err := StdEncoding.Decode(tagged(tag), nil)
+		assert.Error(t, err, "tag %d", tag)
+		assert.False(t, errors.As(err, &ErrUnsupportedMessageTag{}), "tag %d", tag)
+	}
+}
+
+func TestEncoding_DecodeUntaggedMessage(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	sign1 := NewSign1Message()
+	sign1.SetContent([]byte("test"))
+	require.NoError(t, sign1.SetSigner(signer))
+	sign := NewSignMessage()
+	sign.SetContent([]byte("test"))
+	sign.AddSigner(signer)
+	encrypt0 := NewEncrypt0Message()
+	require.NoError(t, encrypt0.SetKey(randomKey(t, 32)))
+
+	tests := []struct {
+		name       string
+		msg        Message
+		candidates []string
+	}{
+		{"sign1", sign1, []string{"COSE_Sign1", "COSE_Mac0"}},
+		{"sign", sign, []string{"COSE_Sign", "COSE_Encrypt"}},
+		{"encrypt0", encrypt0, []string{"COSE_Encrypt0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := StdEncoding.Encode(tt.msg)
+			require.NoError(t, err)
+			raw, err := parseTag(b)
+			require.NoError(t, err)
+
+			_, err = StdEncoding.Decode(raw.Content, nil)
+			var untagged ErrUntaggedMessage
+			require.ErrorAs(t, err, &untagged)
+			assert.Equal(t, tt.candidates, untagged.Candidates)
+			assert.ErrorAs(t, err, &ErrInvalidMessageStructure{})
+		})
+	}
+
+	_, err = StdEncoding.Decode([]byte{0x82, 0x01, 0x02}, nil)
+	assert.EqualError(t, err, "invalid COSE message structure: untagged COSE message")
+}
+
+func TestEncoding_DecodeInvalidSignatureSize(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	raw, err := parseTag(b)
+	require.NoError(t, err)
+	var c sign1Message
+	require.NoError(t, StdEncoding.unmarshal(stageMessageBody, raw.Content, &c))
+	c.Signature = make([]byte, 4096)
+	b, err = StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign1, Content: c})
+	require.NoError(t, err)
+
+	_, err = StdEncoding.Decode(b, &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			t.Fatal("verifiers resolved for a signature of invalid size")
+			return nil, nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrVerification)
+}
+
+func TestEncoding_EncodeRelay(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("firmware"))
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, "application/octet-stream"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("key-1")))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	received, err := StdEncoding.DecodeSign1(data, config)
+	require.NoError(t, err)
+
+	_, err = StdEncoding.EncodeRelay(msg)
+	assert.ErrorIs(t, err, ErrMessageNotDecoded)
+
+	// Unchanged messages are forwarded byte-identical
+	forwarded, err := StdEncoding.EncodeRelay(received)
+	require.NoError(t, err)
+	assert.Equal(t, data, forwarded)
+
+	receivedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC).Unix()
+	require.NoError(t, received.Headers.Set(int64(-70001), int64(1)))
+	require.NoError(t, received.Headers.Set(int64(-70002), receivedAt))
+	forwarded, err = StdEncoding.EncodeRelay(received)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, forwarded)
+
+	relayed, err := StdEncoding.DecodeSign1(forwarded, config)
+	require.NoError(t, err)
+	assert.Equal(t, received.raw.Protected, relayed.raw.Protected)
+	assert.Equal(t, received.raw.Signature, relayed.raw.Signature)
+	assert.Equal(t, []byte("firmware"), relayed.GetContent())
+	hop, err := relayed.Headers.Get(int64(-70001))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, hop)
+	at, err := relayed.Headers.Get(int64(-70002))
+	require.NoError(t, err)
+	assert.EqualValues(t, receivedAt, at)
+	kid, err := relayed.Headers.Get(HeaderKeyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("key-1"), kid)
+
+	// The whole unprotected bucket can be replaced
+	unprotected := NewHeaders()
+	require.NoError(t, unprotected.Set(int64(-70001), int64(2)))
+	require.NoError(t, relayed.Headers.ReplaceUnprotected(unprotected))
+	forwarded, err = StdEncoding.EncodeRelay(relayed)
+	require.NoError(t, err)
+	relayed, err = StdEncoding.DecodeSign1(forwarded, config)
+	require.NoError(t, err)
+	assert.False(t, relayed.Headers.Has(HeaderKeyID))
+	assert.Len(t, relayed.Headers.GetAllUnprotected(), 1)
+	assert.Error(t, relayed.Headers.ReplaceUnprotected(relayed.Headers.ProtectedOnly()))
+
+	// Modified protected headers or content require signing again
+	require.NoError(t, relayed.Headers.SetProtected(HeaderContentType, "text/plain"))
+	_, err = StdEncoding.EncodeRelay(relayed)
+	assert.ErrorIs(t, err, ErrSignedContentModified)
+	relayed.Headers.protected = received.Headers.ProtectedOnly().protected
+	_, err = StdEncoding.EncodeRelay(relayed)
+	require.NoError(t, err)
+	relayed.SetContent([]byte("modified"))
+	_, err = StdEncoding.EncodeRelay(relayed)
+	assert.ErrorIs(t, err, ErrSignedContentModified)
+
+	require.NoError(t, relayed.SetSigner(signer))
+	resigned, err := StdEncoding.Encode(relayed)
+	require.NoError(t, err)
+	_, err = StdEncoding.DecodeSign1(resigned, config)
+	require.NoError(t, err)
+}
+
+func TestEncoding_OnSignatureVerified(t *testing.T) {
+	type call struct {
+		index int
+		kid   interface{}
+	}
+	var calls []call
+	onVerified := func(index int, headers *Headers) {
+		kid, _, _ := headers.Lookup(HeaderKeyID)
+		calls = append(calls, call{index, kid})
+	}
+
+	keys := []struct {
+		alg Algorithm
+		key string
+	}{
+		{AlgorithmES256, "ecdsa256"},
+		{AlgorithmES384, "ecdsa384"},
+		{AlgorithmEdDSA, "ed25519"},
+	}
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	signers := make([]*Signer, len(keys))
+	for i, k := range keys {
+		signer, err := NewSigner(k.alg, getPrivateKey(t, k.key))
+		require.NoError(t, err)
+		require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte{byte(i)}))
+		msg.AddSigner(signer)
+		signers[i] = signer
+	}
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	_, err = StdEncoding.Decode(data, &Config{
+		GetVerifiers:         staticVerifier(t, signers[2]),
+		RequireAllSignatures: Bool(false),
+		OnSignatureVerified:  onVerified,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []call{{2, []byte{2}}}, calls)
+
+	// All signatures are reported if all signatures are required
+	calls = nil
+	verifiers := make([]*Verifier, len(signers))
+	for i, s := range signers {
+		verifiers[i], err = s.ToVerifier()
+		require.NoError(t, err)
+	}
+	_, err = StdEncoding.Decode(data, &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return verifiers, nil
+		},
+		OnSignatureVerified: onVerified,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []call{{0, []byte{0}}, {1, []byte{1}}, {2, []byte{2}}}, calls)
+
+	calls = nil
+	sign1 := NewSign1Message()
+	sign1.SetContent([]byte("test"))
+	require.NoError(t, sign1.SetSigner(signers[1]))
+	data, err = StdEncoding.Encode(sign1)
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(data, &Config{
+		GetVerifiers:        staticVerifier(t, signers[1]),
+		OnSignatureVerified: onVerified,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []call{{0, []byte{1}}}, calls)
+
+	calls = nil
+	_, err = StdEncoding.Decode(data, &Config{
+		GetVerifiers:        staticVerifier(t, signers[0]),
+		OnSignatureVerified: onVerified,
+	})
+	assert.Error(t, err)
+	assert.Empty(t, calls)
+}
+
+func TestEncoding_LenientUnprotectedHeaders(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	// Tag 18, array of 4 and the protected headers are followed by the empty map
+	off, ok := unprotectedOffset(data[1:])
+	require.True(t, ok)
+	off++
+	require.Equal(t, byte(0xa0), data[off])
+
+	replace := func(at int, b []byte) []byte {
+		out := append([]byte{}, data[:at]...)
+		out = append(out, b...)
+		return append(out, data[at+1:]...)
+	}
+
+	lenient, err := NewEncoding(WithLenientUnprotectedHeaders())
+	require.NoError(t, err)
+	relaxed, err := NewEncodingRelaxed()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"null", replace(off, []byte{0xf6})},
+		{"indefinite map", replace(off, []byte{0xbf, 0xff})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StdEncoding.Decode(tt.data, config)
+			var serr ErrInvalidMessageStructure
+			require.ErrorAs(t, err, &serr)
+			var cerr ErrCBORDecode
+			require.ErrorAs(t, err, &cerr)
+			assert.Equal(t, "unprotected header", cerr.Stage)
+
+			decoded, err := lenient.Decode(tt.data, config)
+			require.NoError(t, err)
+			assert.Empty(t, decoded.(*Sign1Message).Headers.GetAllUnprotected())
+			assert.Equal(t, []byte("test"), decoded.(*Sign1Message).GetContent())
+		})
+	}
+
+	_, err = relaxed.Decode(tests[1].data, config)
+	assert.NoError(t, err)
+
+	// Leniency does not apply to the protected headers
+	nullProtected := append([]byte{data[0], data[1], 0xf6}, data[off:]...)
+	_, err = lenient.Decode(nullProtected, config)
+	assert.Error(t, err)
+}
+
+func TestEncoding_MarshalCBOR(t *testing.T) {
+	v := map[interface{}]interface{}{
+		int64(1):  "one",
+		int64(-2): []interface{}{int64(1), []byte{2}, map[interface{}]interface{}{"nested": true}},
+		"map": map[interface{}]interface{}{
+			int64(3): map[interface{}]interface{}{int64(4): []byte("deep")},
+		},
+	}
+	b, err := StdEncoding.MarshalCBOR(v)
+	require.NoError(t, err)
+	assert.NoError(t, CheckDeterministicEncoding(b))
+
+	var decoded map[interface{}]interface{}
+	require.NoError(t, StdEncoding.UnmarshalCBOR(b, &decoded))
+	assert.Equal(t, v, decoded)
+
+	_, err = StdEncoding.MarshalCBOR(make(chan int))
+	assert.ErrorAs(t, err, &ErrCBOREncode{})
+
+	// Duplicate map keys are rejected
+	err = StdEncoding.UnmarshalCBOR(mustHex(t, "a201010102"), &decoded)
+	var derr ErrCBORDecode
+	assert.ErrorAs(t, err, &derr)
+}
+
+func TestEncoding_WithOuterTag(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	tests := []struct {
+		name   string
+		opt    EncodingOption
+		prefix string
+	}{
+		{"CWT", WithCWTTag(), "d83dd284"},
+		{"self-described", WithOuterTag(55799), "d9d9f7d284"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := NewEncoding(tt.opt)
+			require.NoError(t, err)
+			data, err := enc.Encode(msg)
+			require.NoError(t, err)
+			assert.Equal(t, mustHex(t, tt.prefix), data[:len(tt.prefix)/2])
+
+			size, err := enc.EstimateEncodedSize(msg)
+			require.NoError(t, err)
+			assert.Equal(t, len(data), size)
+
+			decoded, err := StdEncoding.Decode(data, config)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("test"), decoded.(*Sign1Message).GetContent())
+		})
+	}
+
+	_, err = NewEncoding(WithOuterTag(MessageTagSign1))
+	assert.Error(t, err)
+}
+
+func TestEncoding_TrailingData(t *testing.T) {
+	msg := mustHex(t, "d83d"+dgcTestMessage)
+	for _, tt := range []struct {
+		data      []byte
+		remaining int
+	}{
+		{append(append([]byte{}, msg...), 0x00), 1},
+		{append(append([]byte{}, msg...), msg...), len(msg)},
+	} {
+		_, err := StdEncoding.Decode(tt.data, dgcTestConfig(t))
+		var terr ErrTrailingData
+		require.ErrorAs(t, err, &terr)
+		assert.Equal(t, ErrTrailingData{Offset: len(msg), Remaining: tt.remaining}, terr)
+		assert.ErrorAs(t, err, &ErrInvalidMessageStructure{})
+
+		_, err = StdEncoding.DecodeSign1(tt.data, dgcTestConfig(t))
+		assert.ErrorAs(t, err, &terr)
+	}
+}
+
+func TestEncoding_DecodeFirst(t *testing.T) {
+	msg := mustHex(t, dgcTestMessage)
+	data := append(append(append([]byte{}, msg...), msg...), 0x01)
+
+	dec, rest, err := StdEncoding.DecodeFirst(data, dgcTestConfig(t))
+	require.NoError(t, err)
+	assert.IsType(t, &Sign1Message{}, dec)
+	assert.Equal(t, data[len(msg):], rest)
+
+	dec, rest, err = StdEncoding.DecodeFirst(rest, dgcTestConfig(t))
+	require.NoError(t, err)
+	assert.NotEmpty(t, dec.GetContent())
+	assert.Equal(t, []byte{0x01}, rest)
+
+	_, rest, err = StdEncoding.DecodeFirst(rest, dgcTestConfig(t))
+	assert.ErrorAs(t, err, &ErrInvalidMessageStructure{})
+	assert.Empty(t, rest)
+
+	// The rest is returned with verification errors
+	dec, rest, err = StdEncoding.DecodeFirst(data, &Config{GetVerifiers: staticVerifier(t, MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256")))})
+	assert.ErrorIs(t, err, ErrVerification)
+	assert.NotNil(t, dec)
+	assert.Len(t, rest, len(msg)+1)
+
+	_, rest, err = StdEncoding.DecodeFirst(msg[:len(msg)-1], dgcTestConfig(t))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Nil(t, rest)
+}
+
+func TestEncoding_TextAlgorithmHeader(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	for _, tt := range []struct {
+		name      string
+		protected string
+		alg       Algorithm
+		err       error
+	}{
+		{"Integer", "a10126", AlgorithmES256, nil},
+		{"Text", "a101654553323536", AlgorithmES256, nil},
+		// The verifier algorithm does not match the unknown algorithm
+		{"UnknownText", "a101674553323536584c", "ES256XL", ErrVerification},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// The signature is created over the protected header bytes as given
+			c := sign1Message{
+				Protected:   mustHex(t, tt.protected),
+				Unprotected: headerMap{},
+				Payload:     []byte("content"),
+			}
+			digest, err := c.signedDigest(StdEncoding, 0, nil)
+			require.NoError(t, err)
+			c.Signature, err = signer.Sign(rand.Reader, digest)
+			require.NoError(t, err)
+			data, err := StdEncoding.marshal(StdEncoding.outerTagged(MessageTagSign1, c))
+			require.NoError(t, err)
+
+			msg, err := StdEncoding.DecodeSign1(data, config)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			alg, err := msg.Headers.GetAlgorithm()
+			require.NoError(t, err)
+			assert.Equal(t, tt.alg, alg)
+			v, err := msg.Headers.GetProtected(HeaderAlgorithm)
+			require.NoError(t, err)
+			assert.Equal(t, string(tt.alg), v)
+
+			// Relayed messages keep the received protected header bytes
+			relayed, err := StdEncoding.EncodeRelay(msg)
+			require.NoError(t, err)
+			assert.Equal(t, data, relayed)
+		})
+	}
+}
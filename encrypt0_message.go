@@ -0,0 +1,182 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"errors"
+	"io"
+)
+
+// Encrypt0Message represents a COSE_Encrypt0 message, the content encryption key
+// is known to the recipient and is not included in the message.
+type Encrypt0Message struct {
+	Headers *Headers
+	alg     *algorithm
+	key     []byte
+	content []byte
+}
+
+// NewEncrypt0Message creates a new Encrypt0Message instance using A256GCM content encryption.
+func NewEncrypt0Message() *Encrypt0Message {
+	return &Encrypt0Message{
+		Headers: NewHeaders(),
+		alg:     getAlg(string(AlgorithmA256GCM)),
+	}
+}
+
+// GetMessageTag returns the COSE_Encrypt0 message tag.
+func (m *Encrypt0Message) GetMessageTag() uint64 {
+	return MessageTagEncrypt0
+}
+
+// GetContent returns the message content.
+func (m *Encrypt0Message) GetContent() []byte {
+	return m.content
+}
+
+// SetContent sets the message content.
+func (m *Encrypt0Message) SetContent(content []byte) {
+	m.content = content
+}
+
+// Validate checks that the critical headers of the message are present.
+func (m *Encrypt0Message) Validate() error {
+	return validateMessage(m.Headers)
+}
+
+// SetAlgorithm sets the content encryption algorithm.
+func (m *Encrypt0Message) SetAlgorithm(alg Algorithm) error {
+	a := getAlg(string(alg))
+	if a == nil || a.Type != algorithmTypeContentEncryption {
+		return ErrUnsupportedAlgorithm
+	}
+	m.alg = a
+	return nil
+}
+
+// SetKey sets the content encryption key, the key size must match the content encryption algorithm.
+func (m *Encrypt0Message) SetKey(key []byte) error {
+	if len(key) == 0 {
+		return ErrInvalidKeySize
+	}
+	m.key = key
+	return nil
+}
+
+func (m *Encrypt0Message) encrypt(e *Encoding, external []byte) (interface{}, error) {
+	if len(m.key) == 0 {
+		return nil, errors.New("no content encryption key")
+	}
+	if len(m.key)*8 != m.alg.KeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	iv := make([]byte, gcmNonceSize)
+	if _, err := io.ReadFull(e.rand, iv); err != nil {
+		return nil, err
+	}
+	msg, err := m.unencrypted(e, iv)
+	if err != nil {
+		return nil, err
+	}
+	aad, err := msg.GetAAD(e, external)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Ciphertext, err = sealContent(m.key, iv, m.GetContent(), aad); err != nil {
+		return nil, err
+	}
+	return *msg, nil
+}
+
+// unencrypted returns the message structure with the headers and IV but without ciphertext.
+func (m *Encrypt0Message) unencrypted(e *Encoding, iv []byte) (*encrypt0Message, error) {
+	h := MergeHeaders(m.Headers, nil)
+	if err := h.SetProtected(HeaderAlgorithm, m.alg.Value); err != nil {
+		return nil, err
+	}
+	if err := h.Set(HeaderIV, iv); err != nil {
+		return nil, err
+	}
+	ph, err := e.marshalProtected(h.protected)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypt0Message{
+		Protected:   ph,
+		Unprotected: h.unprotected,
+	}, nil
+}
+
+// estimate returns the message structure with a placeholder IV and ciphertext of the encrypted size.
+func (m *Encrypt0Message) estimate(e *Encoding) (interface{}, error) {
+	msg, err := m.unencrypted(e, make([]byte, gcmNonceSize))
+	if err != nil {
+		return nil, err
+	}
+	msg.Ciphertext = make([]byte, len(m.GetContent())+gcmTagSize)
+	return *msg, nil
+}
+
+type encrypt0Message struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected headerMap
+	Ciphertext  []byte
+}
+
+func (m *encrypt0Message) GetAAD(e *Encoding, external []byte) ([]byte, error) {
+	return e.marshal([]interface{}{
+		"Encrypt0",
+		m.Protected,
+		externalAAD(external),
+	})
+}
+
+func (m *encrypt0Message) decrypt(e *Encoding, msg *Encrypt0Message, external []byte, config *Config) ([]byte, error) {
+	rawIV, _, err := msg.Headers.Lookup(HeaderIV)
+	if err != nil {
+		return nil, err
+	}
+	iv, ok := rawIV.([]byte)
+	if !ok {
+		return nil, ErrDecryption
+	}
+	if config == nil || config.GetDecryptKey == nil {
+		return nil, ErrDecryption
+	}
+	key, err := config.GetDecryptKey(msg.Headers)
+	if err != nil {
+		return nil, err
+	}
+	cek, ok := key.([]byte)
+	if !ok || len(cek)*8 != msg.alg.KeySize {
+		return nil, ErrInvalidKeySize
+	}
+	aad, err := m.GetAAD(e, external)
+	if err != nil {
+		return nil, err
+	}
+	return openContent(cek, iv, m.Ciphertext, aad)
+}
+
+func newEncrypt0Message(e *Encoding, c *encrypt0Message) (*Encrypt0Message, error) {
+	h, err := newHeaders(e, c.Protected, c.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	a, err := getHeaderAlg(h)
+	if err != nil {
+		return nil, err
+	}
+	if a.Type != algorithmTypeContentEncryption {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &Encrypt0Message{
+		Headers: h,
+		alg:     a,
+	}, nil
+}
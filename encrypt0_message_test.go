@@ -0,0 +1,73 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypt0Message_RoundTrip(t *testing.T) {
+	tests := []struct {
+		alg     Algorithm
+		keySize int
+	}{
+		{AlgorithmA128GCM, 16},
+		{AlgorithmA192GCM, 24},
+		{AlgorithmA256GCM, 32},
+	}
+	external := []byte("external")
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			key := randomKey(t, tt.keySize)
+			msg := NewEncrypt0Message()
+			require.NoError(t, msg.SetAlgorithm(tt.alg))
+			require.NoError(t, msg.SetKey(key))
+			msg.SetContent([]byte("secret"))
+
+			b, err := StdEncoding.EncodeWithExternal(msg, external)
+			require.NoError(t, err)
+			assert.Equal(t, byte(0xd0), b[0])
+			assert.False(t, bytes.Contains(b, []byte("secret")))
+
+			config := &Config{
+				GetDecryptKey: func(*Headers) (interface{}, error) {
+					return key, nil
+				},
+			}
+			dec, err := StdEncoding.DecodeWithExternal(b, external, config)
+			require.NoError(t, err)
+			require.IsType(t, &Encrypt0Message{}, dec)
+			assert.Equal(t, []byte("secret"), dec.GetContent())
+
+			_, err = StdEncoding.DecodeWithExternal(b, []byte("other"), config)
+			assert.ErrorIs(t, err, ErrDecryption)
+
+			_, err = StdEncoding.DecodeWithExternal(b, external, &Config{
+				GetDecryptKey: func(*Headers) (interface{}, error) {
+					return randomKey(t, tt.keySize), nil
+				},
+			})
+			assert.ErrorIs(t, err, ErrDecryption)
+		})
+	}
+}
+
+func TestEncrypt0Message_Invalid(t *testing.T) {
+	msg := NewEncrypt0Message()
+	assert.ErrorIs(t, msg.SetAlgorithm(AlgorithmES256), ErrUnsupportedAlgorithm)
+	assert.ErrorIs(t, msg.SetKey(nil), ErrInvalidKeySize)
+
+	msg.SetContent([]byte("secret"))
+	_, err := StdEncoding.Encode(msg)
+	assert.Error(t, err)
+
+	require.NoError(t, msg.SetKey(randomKey(t, 16)))
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+}
@@ -0,0 +1,435 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"errors"
+	"io"
+)
+
+// gcmNonceSize is the IV size used with AES-GCM content encryption
+const gcmNonceSize = 12
+
+// gcmTagSize is the authentication tag size appended to AES-GCM ciphertext
+const gcmTagSize = 16
+
+// EncryptMessage represents a COSE_Encrypt message.
+type EncryptMessage struct {
+	Headers    *Headers
+	alg        *algorithm
+	recipients []recipient
+	content    []byte
+}
+
+// recipient is a COSE_recipient that protects the content encryption key.
+type recipient interface {
+	// directKey reports whether the recipient determines the content encryption key.
+	directKey() bool
+	// encrypt encodes the recipient, direct key recipients return the derived content encryption key.
+	encrypt(e *Encoding, contentAlg *algorithm, cek []byte) (*recipientMessage, []byte, error)
+}
+
+// Recipient represents a COSE_recipient of an encrypted message using AES Key Wrap or a direct shared key.
+type Recipient struct {
+	Headers *Headers
+	alg     *algorithm
+	key     []byte
+}
+
+// NewEncryptMessage creates a new EncryptMessage instance using A256GCM content encryption.
+func NewEncryptMessage() *EncryptMessage {
+	return &EncryptMessage{
+		Headers:    NewHeaders(),
+		alg:        getAlg(string(AlgorithmA256GCM)),
+		recipients: make([]recipient, 0),
+	}
+}
+
+// GetMessageTag returns the COSE_Encrypt message tag.
+func (m *EncryptMessage) GetMessageTag() uint64 {
+	return MessageTagEncrypt
+}
+
+// GetContent returns the message content.
+func (m *EncryptMessage) GetContent() []byte {
+	return m.content
+}
+
+// SetContent sets the message content.
+func (m *EncryptMessage) SetContent(content []byte) {
+	m.content = content
+}
+
+// Validate checks that the critical headers of the message are present.
+func (m *EncryptMessage) Validate() error {
+	return validateMessage(m.Headers)
+}
+
+// SetAlgorithm sets the content encryption algorithm.
+func (m *EncryptMessage) SetAlgorithm(alg Algorithm) error {
+	a := getAlg(string(alg))
+	if a == nil || a.Type != algorithmTypeContentEncryption {
+		return ErrUnsupportedAlgorithm
+	}
+	m.alg = a
+	return nil
+}
+
+// AddRecipient adds a recipient that receives the content encryption key
+// wrapped with the given key encryption key.
+//
+// With AlgorithmDirect the key is used as the content encryption key and
+// the recipient must be the only recipient of the message.
+func (m *EncryptMessage) AddRecipient(alg Algorithm, kek []byte, headers *Headers) error {
+	a := getAlg(string(alg))
+	if a == nil || (a.Type != algorithmTypeKeyWrap && a.Type != algorithmTypeDirect) {
+		return ErrUnsupportedAlgorithm
+	}
+	if len(kek) == 0 || (a.Type == algorithmTypeKeyWrap && len(kek)*8 != a.KeySize) {
+		return ErrInvalidKeySize
+	}
+	if headers == nil {
+		headers = NewHeaders()
+	}
+	m.recipients = append(m.recipients, &Recipient{
+		Headers: headers,
+		alg:     a,
+		key:     kek,
+	})
+	return nil
+}
+
+// AddECDHESRecipient adds a recipient that derives the content encryption key with ECDH-ES.
+// Direct key agreement recipients must be the only recipient of the message.
+func (m *EncryptMessage) AddECDHESRecipient(r *ECDHESRecipient) {
+	if r == nil {
+		return
+	}
+	m.recipients = append(m.recipients, r)
+}
+
+func (m *EncryptMessage) encrypt(e *Encoding, external []byte) (interface{}, error) {
+	if len(m.recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	for _, r := range m.recipients {
+		if r.directKey() && len(m.recipients) > 1 {
+			return nil, ErrDirectRecipient
+		}
+	}
+
+	h := MergeHeaders(m.Headers, nil)
+	if err := h.SetProtected(HeaderAlgorithm, m.alg.Value); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, gcmNonceSize)
+	if _, err := io.ReadFull(e.rand, iv); err != nil {
+		return nil, err
+	}
+	if err := h.Set(HeaderIV, iv); err != nil {
+		return nil, err
+	}
+
+	ph, err := e.marshalProtected(h.protected)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := encryptMessage{
+		Protected:   ph,
+		Unprotected: h.unprotected,
+		Recipients:  make([]*recipientMessage, len(m.recipients)),
+	}
+
+	var cek []byte
+	if m.recipients[0].directKey() {
+		if msg.Recipients[0], cek, err = m.recipients[0].encrypt(e, m.alg, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		cek = make([]byte, m.alg.KeySize/8)
+		if _, err = io.ReadFull(e.rand, cek); err != nil {
+			return nil, err
+		}
+		for i, r := range m.recipients {
+			if msg.Recipients[i], _, err = r.encrypt(e, m.alg, cek); err != nil {
+				return nil, err
+			}
+		}
+	}
+	aad, err := msg.GetAAD(e, external)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Ciphertext, err = sealContent(cek, iv, m.GetContent(), aad); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (r *Recipient) directKey() bool {
+	return r.alg.Type == algorithmTypeDirect
+}
+
+func (r *Recipient) encrypt(_ *Encoding, contentAlg *algorithm, cek []byte) (*recipientMessage, []byte, error) {
+	var wrapped []byte
+	if r.directKey() {
+		if len(r.key)*8 != contentAlg.KeySize {
+			return nil, nil, ErrInvalidKeySize
+		}
+		cek, wrapped = r.key, []byte{}
+	} else {
+		var err error
+		if wrapped, err = wrapKey(r.key, cek); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Protected headers must be empty for AES Key Wrap and direct recipients
+	unprotected := make(map[interface{}]interface{})
+	for k, v := range r.Headers.protected {
+		unprotected[k] = v
+	}
+	for k, v := range r.Headers.unprotected {
+		unprotected[k] = v
+	}
+	unprotected[int64(1)] = r.alg.Value
+
+	return &recipientMessage{
+		Protected:   []byte{},
+		Unprotected: unprotected,
+		Ciphertext:  wrapped,
+	}, cek, nil
+}
+
+type recipientMessage struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected headerMap
+	Ciphertext  []byte
+}
+
+type encryptMessage struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected headerMap
+	Ciphertext  []byte
+	Recipients  []*recipientMessage
+}
+
+// protectedHeaders returns the encoded protected headers of the message and its recipients.
+func (m *encryptMessage) protectedHeaders() [][]byte {
+	protected := [][]byte{m.Protected}
+	for _, r := range m.Recipients {
+		if r != nil {
+			protected = append(protected, r.Protected)
+		}
+	}
+	return protected
+}
+
+func (m *encryptMessage) GetAAD(e *Encoding, external []byte) ([]byte, error) {
+	return e.marshal([]interface{}{
+		"Encrypt",
+		m.Protected,
+		externalAAD(external),
+	})
+}
+
+func (m *encryptMessage) decrypt(e *Encoding, msg *EncryptMessage, external []byte, config *Config) ([]byte, error) {
+	rawIV, _, err := msg.Headers.Lookup(HeaderIV)
+	if err != nil {
+		return nil, err
+	}
+	iv, ok := rawIV.([]byte)
+	if !ok {
+		return nil, ErrDecryption
+	}
+	aad, err := m.GetAAD(e, external)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]*recipientMessage, 0, len(m.Recipients))
+	headers := make([]*Headers, 0, len(m.Recipients))
+	for _, r := range m.Recipients {
+		if r == nil {
+			continue
+		}
+		rheaders, err := newHeaders(e, r.Protected, r.Unprotected)
+		if err != nil {
+			return nil, err
+		}
+		// The unprotected recipient alg header replaces the content alg header for the recipient
+		if v, ok := rheaders.unprotected[int64(1)]; ok {
+			delete(rheaders.unprotected, int64(1))
+			rheaders.protected[int64(1)] = v
+		}
+		h := MergeHeaders(msg.Headers, rheaders)
+		// Direct key recipients must be the only recipient, checked before any recipient is tried
+		if a, err := getHeaderAlg(h); err == nil && isDirectKeyAlg(a) && len(m.Recipients) > 1 {
+			return nil, ErrDirectRecipient
+		}
+		recipients = append(recipients, r)
+		headers = append(headers, h)
+	}
+
+	for i, r := range recipients {
+		keys, err := r.decryptKeys(e, msg.alg, headers[i], config)
+		if err != nil {
+			continue
+		}
+		// Fall through to other keys and recipients if the content can not be decrypted
+		for _, cek := range keys {
+			if len(cek)*8 != msg.alg.KeySize {
+				continue
+			}
+			if content, err := openContent(cek, iv, m.Ciphertext, aad); err == nil {
+				return content, nil
+			}
+		}
+	}
+	return nil, ErrDecryption
+}
+
+// isDirectKeyAlg returns true if the recipient algorithm uses the shared or agreed key directly
+// as the content encryption key.
+func isDirectKeyAlg(a *algorithm) bool {
+	return a.Type == algorithmTypeDirect || a.Type == algorithmTypeECDHES
+}
+
+// decryptKeys returns the candidate content encryption keys of the recipient.
+func (r *recipientMessage) decryptKeys(e *Encoding, contentAlg *algorithm, headers *Headers, config *Config) ([][]byte, error) {
+	a, err := getHeaderAlg(headers)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, ErrDecryption
+	}
+
+	switch a.Type {
+	case algorithmTypeKeyWrap, algorithmTypeDirect:
+		if config.GetKeyUnwrappers != nil {
+			unwrappers, err := config.GetKeyUnwrappers(headers)
+			if err != nil {
+				return nil, err
+			}
+			keys := make([][]byte, 0, len(unwrappers))
+			for _, u := range unwrappers {
+				// Skip unwrappers not matching the recipient algorithm
+				if u == nil || u.alg != a {
+					continue
+				}
+				if cek, err := u.Unwrap(r.Ciphertext); err == nil {
+					keys = append(keys, cek)
+				}
+			}
+			return keys, nil
+		}
+		if config.GetDecryptKey == nil {
+			return nil, ErrDecryption
+		}
+		key, err := config.GetDecryptKey(headers)
+		if err != nil {
+			return nil, err
+		}
+		kek, _ := key.([]byte)
+		u, err := NewKeyUnwrapper(Algorithm(a.Name), kek)
+		if err != nil {
+			return nil, err
+		}
+		cek, err := u.Unwrap(r.Ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{cek}, nil
+	case algorithmTypeECDHES:
+		if config.GetDecryptKey == nil {
+			return nil, ErrDecryption
+		}
+		key, err := config.GetDecryptKey(headers)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		cek, err := deriveECDHESKey(e, a, contentAlg, priv, headers, r.Protected)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{cek}, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+func getHeaderAlg(headers *Headers) (*algorithm, error) {
+	name, _, err := headers.Lookup(HeaderAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := name.(string); ok {
+		if a := getAlg(n); a != nil {
+			return a, nil
+		}
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func sealContent(key, iv, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, iv, plaintext, aad), nil
+}
+
+func openContent(key, iv, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() {
+		return nil, ErrDecryption
+	}
+	plaintext, err := aead.Open(nil, iv, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return plaintext, nil
+}
+
+func newEncryptMessage(e *Encoding, c *encryptMessage) (*EncryptMessage, error) {
+	h, err := newHeaders(e, c.Protected, c.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	a, err := getHeaderAlg(h)
+	if err != nil {
+		return nil, err
+	}
+	if a.Type != algorithmTypeContentEncryption {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &EncryptMessage{
+		Headers: h,
+		alg:     a,
+	}, nil
+}
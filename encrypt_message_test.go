@@ -0,0 +1,236 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKey(t *testing.T, size int) []byte {
+	key := make([]byte, size)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func keyByKeyID(keys map[string][]byte) func(*Headers) (interface{}, error) {
+	return func(headers *Headers) (interface{}, error) {
+		kid, err := headers.Get(HeaderKeyID)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := kid.([]byte)
+		if key, ok := keys[string(b)]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown kid %v", kid)
+	}
+}
+
+func TestEncryptMessage_KeyWrapRecipients(t *testing.T) {
+	keys := map[string][]byte{
+		"kek-128": randomKey(t, 16),
+		"kek-256": randomKey(t, 32),
+	}
+
+	msg := NewEncryptMessage()
+	msg.SetContent([]byte("secret"))
+	h1 := NewHeaders()
+	require.NoError(t, h1.Set(HeaderKeyID, []byte("kek-128")))
+	require.NoError(t, msg.AddRecipient(AlgorithmA128KW, keys["kek-128"], h1))
+	h2 := NewHeaders()
+	require.NoError(t, h2.Set(HeaderKeyID, []byte("kek-256")))
+	require.NoError(t, msg.AddRecipient(AlgorithmA256KW, keys["kek-256"], h2))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(b, []byte("secret")))
+
+	for kid, key := range keys {
+		t.Run(kid, func(t *testing.T) {
+			dec, err := StdEncoding.Decode(b, &Config{
+				GetDecryptKey: keyByKeyID(map[string][]byte{kid: key}),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, msg.GetContent(), dec.GetContent())
+		})
+	}
+}
+
+func TestEncryptMessage_WrongKey(t *testing.T) {
+	msg := NewEncryptMessage()
+	msg.SetContent([]byte("secret"))
+	require.NoError(t, msg.AddRecipient(AlgorithmA256KW, randomKey(t, 32), nil))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.Decode(b, &Config{
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return randomKey(t, 32), nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrDecryption)
+	require.NotNil(t, dec)
+	assert.Nil(t, dec.GetContent())
+}
+
+func TestEncryptMessage_ExternalAAD(t *testing.T) {
+	kek := randomKey(t, 32)
+	msg := NewEncryptMessage()
+	require.NoError(t, msg.SetAlgorithm(AlgorithmA128GCM))
+	msg.SetContent([]byte("secret"))
+	require.NoError(t, msg.AddRecipient(AlgorithmA256KW, kek, nil))
+
+	b, err := StdEncoding.EncodeWithExternal(msg, []byte("aad"))
+	require.NoError(t, err)
+
+	config := &Config{
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return kek, nil
+		},
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrDecryption)
+
+	dec, err := StdEncoding.DecodeWithExternal(b, []byte("aad"), config)
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+}
+
+func TestEncryptMessage_AddRecipientInvalid(t *testing.T) {
+	msg := NewEncryptMessage()
+	assert.ErrorIs(t, msg.AddRecipient(AlgorithmA256KW, randomKey(t, 16), nil), ErrInvalidKeySize)
+	assert.ErrorIs(t, msg.AddRecipient(AlgorithmES256, randomKey(t, 32), nil), ErrUnsupportedAlgorithm)
+	assert.ErrorIs(t, msg.SetAlgorithm(AlgorithmA256KW), ErrUnsupportedAlgorithm)
+}
+
+func TestEncryptMessage_KeyUnwrappers(t *testing.T) {
+	keys := map[string][]byte{
+		"kek-1": randomKey(t, 16),
+		"kek-2": randomKey(t, 32),
+	}
+
+	msg := NewEncryptMessage()
+	msg.SetContent([]byte("secret"))
+	h1 := NewHeaders()
+	require.NoError(t, h1.Set(HeaderKeyID, []byte("kek-1")))
+	require.NoError(t, msg.AddRecipient(AlgorithmA128KW, keys["kek-1"], h1))
+	h2 := NewHeaders()
+	require.NoError(t, h2.Set(HeaderKeyID, []byte("kek-2")))
+	require.NoError(t, msg.AddRecipient(AlgorithmA256KW, keys["kek-2"], h2))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	var resolved []string
+	config := &Config{
+		GetKeyUnwrappers: func(headers *Headers) ([]*KeyUnwrapper, error) {
+			kid, err := headers.Get(HeaderKeyID)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, string(kid.([]byte)))
+			if string(kid.([]byte)) != "kek-2" {
+				return nil, nil
+			}
+			u, err := NewKeyUnwrapper(AlgorithmA256KW, keys["kek-2"])
+			if err != nil {
+				return nil, err
+			}
+			return []*KeyUnwrapper{u}, nil
+		},
+	}
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+	assert.Equal(t, []string{"kek-1", "kek-2"}, resolved)
+
+	// Unwrap failure of the first recipient falls through to the next one
+	wrong, err := NewKeyUnwrapper(AlgorithmA128KW, randomKey(t, 16))
+	require.NoError(t, err)
+	first, err := NewKeyUnwrapper(AlgorithmA128KW, keys["kek-1"])
+	require.NoError(t, err)
+	config.GetKeyUnwrappers = func(headers *Headers) ([]*KeyUnwrapper, error) {
+		return []*KeyUnwrapper{wrong, first}, nil
+	}
+	dec, err = StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+
+	config.GetKeyUnwrappers = func(headers *Headers) ([]*KeyUnwrapper, error) {
+		return []*KeyUnwrapper{wrong}, nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrDecryption)
+}
+
+func TestEncryptMessage_DirectRecipient(t *testing.T) {
+	key := randomKey(t, 16)
+
+	msg := NewEncryptMessage()
+	require.NoError(t, msg.SetAlgorithm(AlgorithmA128GCM))
+	msg.SetContent([]byte("secret"))
+	require.NoError(t, msg.AddRecipient(AlgorithmDirect, key, nil))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	unwrapper, err := NewKeyUnwrapper(AlgorithmDirect, key)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, &Config{
+		GetKeyUnwrappers: func(*Headers) ([]*KeyUnwrapper, error) {
+			return []*KeyUnwrapper{unwrapper}, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+
+	dec, err = StdEncoding.Decode(b, &Config{
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return key, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+
+	// Direct key must match the content encryption key size
+	require.NoError(t, msg.SetAlgorithm(AlgorithmA256GCM))
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+
+	require.NoError(t, msg.SetAlgorithm(AlgorithmA128GCM))
+	require.NoError(t, msg.AddRecipient(AlgorithmA128KW, randomKey(t, 16), nil))
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrDirectRecipient)
+
+	// Decoded messages with a direct key recipient among other recipients are rejected
+	raw, err := parseTag(b)
+	require.NoError(t, err)
+	var c encryptMessage
+	require.NoError(t, StdEncoding.unmarshal(stageMessageBody, raw.Content, &c))
+	protected, err := StdEncoding.marshal(map[interface{}]interface{}{int64(1): int64(-3)})
+	require.NoError(t, err)
+	c.Recipients = append([]*recipientMessage{{
+		Protected:   protected,
+		Unprotected: headerMap{},
+		Ciphertext:  randomKey(t, 24),
+	}}, c.Recipients...)
+	b, err = StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagEncrypt, Content: c})
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, &Config{
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return key, nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrDirectRecipient)
+}
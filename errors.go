@@ -7,6 +7,7 @@ package cose
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -16,12 +17,69 @@ var (
 	ErrUnavailableHashAlgorithm = errors.New("hash algorithm unavailable")
 	// ErrUnsupportedAlgorithm represents an error when an algorithm is not supported.
 	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	// ErrAlgorithmNotForSigning represents an error when an encryption or MAC algorithm is used for signing.
+	ErrAlgorithmNotForSigning = errors.New("algorithm is not a signing algorithm")
 	// ErrAlgorithmNotMatchKey represents an error when an algorithm does not match the key type.
 	ErrAlgorithmNotMatchKey = errors.New("algorithm does not match key type")
 	// ErrInvalidEllipticCurve represents an error when an elliptic curve size does not match the key.
 	ErrInvalidEllipticCurve = errors.New("invalid elliptic curve")
 	// ErrVerification represents a failure to verify a signature.
 	ErrVerification = errors.New("verification error")
+	// ErrMessageNotDecoded represents an error when an operation requires a decoded message.
+	ErrMessageNotDecoded = errors.New("message is not decoded")
+	// ErrInvalidKeySize represents an error when a symmetric key size does not match the algorithm.
+	ErrInvalidKeySize = errors.New("invalid key size")
+	// ErrDirectRecipient represents an error when a direct key recipient is not the only recipient.
+	ErrDirectRecipient = errors.New("direct key recipient must be the only recipient")
+	// ErrDecryption represents a failure to decrypt a message or unwrap a key.
+	ErrDecryption = errors.New("decryption error")
+	// ErrMissingAlgorithmHeader represents an error when a required alg header is absent.
+	ErrMissingAlgorithmHeader = errors.New("missing algorithm header")
+	// ErrAlgorithmNotProtected represents an error when the alg header is present only in unprotected headers.
+	ErrAlgorithmNotProtected = errors.New("algorithm header is not protected")
+	// ErrPayloadAlreadyConsumed represents an error when the content reader of a message was already read by a previous encode.
+	ErrPayloadAlreadyConsumed = errors.New("payload reader already consumed")
+	// ErrSignedContentModified represents an error when relaying a decoded message whose protected headers or payload were modified.
+	ErrSignedContentModified = errors.New("signed content of decoded message is modified")
+	// ErrHeaderNotFound represents an error when a required header is absent.
+	ErrHeaderNotFound = errors.New("header not found")
+	// ErrReservedHeaderLabel represents an error when setting a header with a reserved or unassigned integer label.
+	ErrReservedHeaderLabel = errors.New("reserved header label")
+	// ErrHeaderRegistered represents an error when registering a private header name or label
+	// that is a common header or registered with different parameters.
+	ErrHeaderRegistered = errors.New("header name or label is already registered")
+	// ErrInvalidCurvePoint represents an error when public key coordinates are not a valid elliptic curve point.
+	ErrInvalidCurvePoint = errors.New("invalid elliptic curve point")
+	// ErrRequiredAlgorithm represents an error when no signer uses one of the required algorithms.
+	ErrRequiredAlgorithm = errors.New("no signer uses a required algorithm")
+	// ErrUnprotectedKeyID represents an error when the kid header is present only in unprotected headers.
+	ErrUnprotectedKeyID = errors.New("key id is not protected")
+	// ErrTokenExpired represents an error when the CWT exp claim is in the past.
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenNotYetValid represents an error when the CWT iat or nbf claim is in the future.
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+	// ErrInvalidHashSize represents an error when the hashed digest size does not match the hash function.
+	ErrInvalidHashSize = errors.New("invalid hash size")
+	// ErrMessageExpired represents an error when the protected expiry header time is in the past.
+	ErrMessageExpired = errors.New("message expired")
+	// ErrMissingExpiry represents an error when the required protected expiry header is absent.
+	ErrMissingExpiry = errors.New("missing expiry header")
+	// ErrPayloadDigestMismatch represents an error when the payload does not match the protected payload digest header.
+	ErrPayloadDigestMismatch = errors.New("payload digest mismatch")
+	// ErrMissingCWTClaims represents an error when the message payload is not a CWT claims map.
+	ErrMissingCWTClaims = errors.New("missing CWT claims")
+	// ErrNoSigner represents an error when encoding a signed message without signers.
+	ErrNoSigner = errors.New("message has no signer")
+	// ErrNoContent represents an error when a message that is not detached has no content.
+	ErrNoContent = errors.New("message has no content")
+	// ErrMissingX5Chain represents an error when the message has no x5chain header.
+	ErrMissingX5Chain = errors.New("missing x5chain header")
+	// ErrMissingCounterSignature represents an error when the message has no countersignature.
+	ErrMissingCounterSignature = errors.New("missing countersignature")
+	// ErrInvalidHeader represents an error when a header value has an invalid type.
+	ErrInvalidHeader = errors.New("invalid header value")
+	// ErrConflictingIV represents an error when both IV and Partial IV headers are set.
+	ErrConflictingIV = errors.New("IV and Partial IV headers can not both be present")
 )
 
 // ErrMinKeySize represents an error when a key is too small.
@@ -39,5 +97,254 @@ type ErrUnsupportedMessageTag struct {
 }
 
 func (e ErrUnsupportedMessageTag) Error() string {
+	if name, ok := messageTagNames[e.Tag]; ok {
+		return fmt.Sprintf("unsupported COSE message tag: %d (%s)", e.Tag, name)
+	}
 	return fmt.Sprintf("unsupported COSE message tag: %d", e.Tag)
 }
+
+// ErrUntaggedMessage represents an error when the message is a CBOR array without a COSE message tag.
+type ErrUntaggedMessage struct {
+	// Candidates are the names of COSE messages matching the array shape
+	Candidates []string
+}
+
+func (e ErrUntaggedMessage) Error() string {
+	if len(e.Candidates) == 0 {
+		return "untagged COSE message"
+	}
+	return fmt.Sprintf("untagged COSE message, likely %s", strings.Join(e.Candidates, " or "))
+}
+
+// ErrUnexpectedMessageTag represents an error when a message tag is not one of the expected tags.
+type ErrUnexpectedMessageTag struct {
+	Tag      uint64
+	Expected []uint64
+}
+
+func (e ErrUnexpectedMessageTag) Error() string {
+	return fmt.Sprintf("unexpected COSE message tag: %d, expected one of %v", e.Tag, e.Expected)
+}
+
+// ErrContentTypeMismatch represents an error when the content type header claims a different COSE message type.
+type ErrContentTypeMismatch struct {
+	ContentType interface{}
+	Tag         uint64
+}
+
+func (e ErrContentTypeMismatch) Error() string {
+	return fmt.Sprintf("content type %v does not match COSE message tag: %d", e.ContentType, e.Tag)
+}
+
+// ErrInvalidMessageStructure represents an error when the message is not a valid COSE message structure.
+type ErrInvalidMessageStructure struct {
+	Err error
+}
+
+func (e ErrInvalidMessageStructure) Error() string {
+	return fmt.Sprintf("invalid COSE message structure: %v", e.Err)
+}
+
+func (e ErrInvalidMessageStructure) Unwrap() error {
+	return e.Err
+}
+
+// ErrTrailingData represents an error when data follows the decoded COSE message,
+// concatenated messages are decoded with Encoding.DecodeFirst.
+type ErrTrailingData struct {
+	// Offset is the byte offset of the data following the message
+	Offset    int
+	Remaining int
+}
+
+func (e ErrTrailingData) Error() string {
+	return fmt.Sprintf("%d bytes of trailing data at offset %d", e.Remaining, e.Offset)
+}
+
+// ErrCBORDecode represents an error of the CBOR decoder, the stage describes the decoded item.
+type ErrCBORDecode struct {
+	Cause error
+	Stage string
+}
+
+func (e ErrCBORDecode) Error() string {
+	return fmt.Sprintf("cbor decode error in %s: %v", e.Stage, e.Cause)
+}
+
+func (e ErrCBORDecode) Unwrap() error {
+	return e.Cause
+}
+
+// ErrCBOREncode represents an error of the CBOR encoder.
+type ErrCBOREncode struct {
+	Cause error
+}
+
+func (e ErrCBOREncode) Error() string {
+	return fmt.Sprintf("cbor encode error: %v", e.Cause)
+}
+
+func (e ErrCBOREncode) Unwrap() error {
+	return e.Cause
+}
+
+// ErrPayloadRejected represents an error when the verified message payload is rejected by Config.ValidatePayload.
+type ErrPayloadRejected struct {
+	Err error
+}
+
+func (e ErrPayloadRejected) Error() string {
+	return fmt.Sprintf("payload rejected: %v", e.Err)
+}
+
+func (e ErrPayloadRejected) Unwrap() error {
+	return e.Err
+}
+
+// ErrNonDeterministicEncoding represents an error when CBOR data does not use Core Deterministic Encoding.
+type ErrNonDeterministicEncoding struct {
+	// Offset is the byte offset of the violating data item
+	Offset int
+	Reason string
+}
+
+func (e ErrNonDeterministicEncoding) Error() string {
+	return fmt.Sprintf("non-deterministic CBOR encoding at offset %d: %s", e.Offset, e.Reason)
+}
+
+// ErrMessageBuild represents the first error of the message builder methods, returned when the message is encoded.
+type ErrMessageBuild struct {
+	// Step is the name of the builder method that failed
+	Step string
+	Err  error
+}
+
+func (e ErrMessageBuild) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+func (e ErrMessageBuild) Unwrap() error {
+	return e.Err
+}
+
+// ErrMalformedHeaders represents an error when message headers contain a duplicate label.
+type ErrMalformedHeaders struct {
+	Label interface{}
+}
+
+func (e ErrMalformedHeaders) Error() string {
+	return fmt.Sprintf("malformed headers: duplicate label %v", e.Label)
+}
+
+// ErrCriticalHeaderMissing represents an error when a label listed in the crit header is not present in protected headers.
+type ErrCriticalHeaderMissing struct {
+	Label interface{}
+}
+
+func (e ErrCriticalHeaderMissing) Error() string {
+	return fmt.Sprintf("critical header %v is not present in protected headers", e.Label)
+}
+
+// ErrHeaderConflict represents an error when the same header label is set with different values.
+type ErrHeaderConflict struct {
+	Label interface{}
+}
+
+func (e ErrHeaderConflict) Error() string {
+	return fmt.Sprintf("conflicting values for header label %v", e.Label)
+}
+
+// ErrForbiddenAlgorithm represents an error when a message is signed with a forbidden algorithm.
+type ErrForbiddenAlgorithm struct {
+	Algorithm Algorithm
+}
+
+func (e ErrForbiddenAlgorithm) Error() string {
+	return fmt.Sprintf("algorithm %s is forbidden", e.Algorithm)
+}
+
+// ErrPolicyViolation represents an error when an algorithm or key is not permitted by the encoding AlgorithmPolicy.
+type ErrPolicyViolation struct {
+	Detail string
+}
+
+func (e ErrPolicyViolation) Error() string {
+	return fmt.Sprintf("algorithm policy violation: %s", e.Detail)
+}
+
+// SignatureError represents a verification error of a COSE_Sign message signature.
+type SignatureError struct {
+	// Index is the position of the signature in the message
+	Index int
+	Err   error
+	// SignerHeaders are the signature headers, nil if they could not be decoded
+	SignerHeaders *Headers
+}
+
+func (e SignatureError) Error() string {
+	return fmt.Sprintf("signature %d: %v", e.Index, e.Err)
+}
+
+func (e SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// ErrMessageTooLarge represents an error when a message read by MessageDecoder exceeds the size limit.
+type ErrMessageTooLarge struct {
+	Size int
+}
+
+func (e ErrMessageTooLarge) Error() string {
+	return fmt.Sprintf("message exceeds the maximum size of %d bytes", e.Size)
+}
+
+// ErrBatchEncode represents an encoding error of a message in a batch.
+type ErrBatchEncode struct {
+	// Index is the position of the message in the batch
+	Index int
+	Err   error
+}
+
+func (e ErrBatchEncode) Error() string {
+	return fmt.Sprintf("message %d: %v", e.Index, e.Err)
+}
+
+func (e ErrBatchEncode) Unwrap() error {
+	return e.Err
+}
+
+// MultiVerificationError represents the verification errors of all failed COSE_Sign message signatures.
+type MultiVerificationError struct {
+	Errors []SignatureError
+}
+
+func (e *MultiVerificationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "verification failed: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether the target is ErrVerification or matches any of the signature errors.
+func (e *MultiVerificationError) Is(target error) bool {
+	if target == ErrVerification {
+		return true
+	}
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first signature error matching the target.
+func (e *MultiVerificationError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/zzdats/go-cose"
+)
+
+// externalAAD is authenticated but not included in the message, both sides must use the same value
+var externalAAD = []byte("example external data")
+
+func main() {
+	var err error
+
+	// Random AES-256 content encryption key shared by sender and recipient
+	key := make([]byte, 32)
+	if _, err = rand.Read(key); err != nil {
+		panic(err)
+	}
+
+	// Create new COSE_Encrypt0 message
+	msg := cose.NewEncrypt0Message()
+	msg.SetContent([]byte("test"))
+	if err := msg.SetAlgorithm(cose.AlgorithmA256GCM); err != nil {
+		panic(err)
+	}
+	if err := msg.SetKey(key); err != nil {
+		panic(err)
+	}
+
+	// Encode to COSE byte array
+	b, err := cose.StdEncoding.EncodeWithExternal(msg, externalAAD)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Encrypted message: %s\n", hex.EncodeToString(b))
+
+	// Decode from COSE byte array
+	dec, err := cose.StdEncoding.DecodeWithExternal(b, externalAAD, &cose.Config{
+		// Provide decryption key resolver
+		GetDecryptKey: func(headers *cose.Headers) (interface{}, error) {
+			// You can use kid or some other info from headers to select the key
+			return key, nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Decrypted: %s\n", string(dec.GetContent()))
+}
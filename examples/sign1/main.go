@@ -17,15 +17,14 @@ func main() {
 		panic(err)
 	}
 
-	// Create new COSE_Sign1 message
-	msg := cose.NewSign1Message()
-	msg.SetContent([]byte("test"))
-	// Add signer
-	signer, err := cose.NewSigner(cose.AlgorithmPS256, key)
-	if err != nil {
-		panic(err)
-	}
-	msg.SetSigner(signer)
+	// Create signer, panics if the key does not match the algorithm
+	signer := cose.MustNewSigner(cose.AlgorithmPS256, key)
+
+	// Create new COSE_Sign1 message, builder errors are returned by Encode
+	msg := cose.NewSign1Message().
+		WithContent([]byte("test")).
+		WithSigner(signer).
+		WithProtectedHeader(cose.HeaderContentType, "text/plain")
 
 	// Encode to COSE byte array
 	b, err := cose.StdEncoding.Encode(msg)
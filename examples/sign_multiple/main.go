@@ -28,21 +28,15 @@ func main() {
 	msg.SetContent([]byte("test"))
 
 	// Add first signer
-	signer1, err := cose.NewSigner(cose.AlgorithmPS256, key1)
-	if err != nil {
-		panic(err)
-	}
-	if err := signer1.Headers.Set(cose.HeaderKeyID, 1); err != nil {
+	signer1 := cose.MustNewSigner(cose.AlgorithmPS256, key1)
+	if err := signer1.Headers.SetProtected(cose.HeaderKeyID, 1); err != nil {
 		panic(err)
 	}
 	msg.AddSigner(signer1)
 
 	// Add second signer
-	signer2, err := cose.NewSigner(cose.AlgorithmPS512, key2)
-	if err != nil {
-		panic(err)
-	}
-	if err := signer2.Headers.Set(cose.HeaderKeyID, 2); err != nil {
+	signer2 := cose.MustNewSigner(cose.AlgorithmPS512, key2)
+	if err := signer2.Headers.SetProtected(cose.HeaderKeyID, 2); err != nil {
 		panic(err)
 	}
 	msg.AddSigner(signer2)
@@ -56,12 +50,14 @@ func main() {
 	fmt.Printf("Signed message: %s\n", hex.EncodeToString(b))
 
 	// Decode from COSE byte array
-	dec, err := cose.StdEncoding.Decode(b, &cose.Config{
+	dec, err := cose.StdEncoding.DecodeWithStatus(b, &cose.Config{
+		// Fail if kid is not protected
+		RequireProtectedKeyID: true,
 		// Provide signature verifier resolver
 		GetVerifiers: func(headers *cose.Headers) ([]*cose.Verifier, error) {
 			// You can use kid or some other info from headers to detect needed verification certificate
-			// or just provide static verifier
-			kid, err := headers.Get(cose.HeaderKeyID)
+			// or just provide static verifier, protected kid can not be modified in transit
+			kid, err := headers.ProtectedOnly().Get(cose.HeaderKeyID)
 			if err != nil {
 				return nil, err
 			}
@@ -84,12 +80,12 @@ func main() {
 			}
 		},
 	})
-	if err != nil && err != cose.ErrVerification {
+	if dec == nil {
 		panic(err)
 	}
 
-	fmt.Printf("Decoded: %s\n", string(dec.GetContent()))
-	if err == nil {
+	fmt.Printf("Decoded: %s\n", string(dec.Message.GetContent()))
+	if dec.Verified {
 		fmt.Println("Signature verified")
 	} else {
 		fmt.Println("Signature is NOT valid")
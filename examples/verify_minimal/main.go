@@ -0,0 +1,52 @@
+// Minimal verify-only consumer for measuring constrained builds, for example
+//
+//	GOOS=js GOARCH=wasm go build -tags "cose_norsa cose_noexpvar" ./examples/verify_minimal
+//
+// The verifier is created from raw key coordinates so crypto/x509 certificate
+// parsing is not linked.
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/zzdats/go-cose"
+)
+
+// Public key of the DGC test certificate used in the verify example
+const (
+	keyX = "4401ea9b708fe58d6b52fd402f621178b9b775450a4e77f36b80bba765bdc701"
+	keyY = "b056ffc8757713ebd43496426c715e5191fd38ad62d2f566ec5e4567e5f1b183"
+)
+
+const coseData = `d28443a10126a104484dfc0b3070d7230b59015ca401624c56041a62a9939b061a60c8601b390103a101a46376657265312e302e30636e616da462666e67c4b6656c70697363666e74664b454c50495362676e6a4dc481727469c586c5a163676e74674d415254494e5363646f626a313939332d30392d3133617481aa62746769383430353339303036627474684c50363436342d34626e6d7832412a5354415220466f72746974756465204b697420322e30202853696e6761706f72652048534129203f20504352206b697462736374323032312d30362d31325430393a30303a30305a62647274323032312d30362d31325430393a30303a30305a62747269323630343135303030627463634e564462636f624c5662697378204e6163696f6ec4816c61697320766573656cc4ab626173206469656e65737473626369782f75726e3a757663693a30313a6c763a3363653362623365383033346364376561653236646639656435636130383962584049232f3562692ca90585994d02e0131058e9800797449e5fbc4ba323a339adc4895872959e813ae34e4dcb9e0157113f97c6307db2bbe54b66767482fe571363`
+
+func main() {
+	coseHex := coseData
+	if len(os.Args) > 1 {
+		coseHex = os.Args[1]
+	}
+
+	b, err := hex.DecodeString(coseHex)
+	if err != nil {
+		panic(err)
+	}
+	x, _ := hex.DecodeString(keyX)
+	y, _ := hex.DecodeString(keyY)
+
+	verifier, err := cose.NewECDSAVerifier(cose.AlgorithmES256, x, y)
+	if err != nil {
+		panic(err)
+	}
+
+	msg, err := cose.StdEncoding.DecodeSign1(b, &cose.Config{
+		GetVerifiers: func(*cose.Headers) ([]*cose.Verifier, error) {
+			return []*cose.Verifier{verifier}, nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Verified %d bytes of content\n", len(msg.GetContent()))
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"math"
+	"time"
+)
+
+// SetExpiry sets the message expiry time as an integer epoch time in the protected header
+// with the given label and adds the label to the crit header.
+//
+// The expiry is validated when decoding if Config.ExpiryHeader is set to the same label.
+func (h *Headers) SetExpiry(label interface{}, t time.Time) error {
+	l, err := normalizeLabel(label)
+	if err != nil {
+		return err
+	}
+	if l == getCommonHeader(HeaderAlgorithm) || l == getCommonHeader(HeaderCritical) {
+		return ErrInvalidHeader
+	}
+	crit, err := h.GetCritical()
+	if err != nil {
+		return err
+	}
+	if err = h.SetProtected(l, t.Unix()); err != nil {
+		return err
+	}
+	for _, c := range crit {
+		if c == l {
+			return nil
+		}
+	}
+	return h.SetProtected(HeaderCritical, append(crit, l))
+}
+
+// validateExpiry checks the protected expiry header of the verified message if Config.ExpiryHeader is set.
+func (c *Config) validateExpiry(headers *Headers) error {
+	if c == nil || c.ExpiryHeader == nil {
+		return nil
+	}
+	label, err := normalizeLabel(c.ExpiryHeader)
+	if err != nil {
+		return err
+	}
+	v, ok := headers.protected[label]
+	v = resolveHeaderValue(v)
+	if !ok {
+		if c.RequireExpiry {
+			return ErrMissingExpiry
+		}
+		return nil
+	}
+
+	var exp time.Time
+	switch t := v.(type) {
+	case int64:
+		exp = time.Unix(t, 0)
+	case uint64:
+		if t > math.MaxInt64 {
+			return ErrInvalidHeader
+		}
+		exp = time.Unix(int64(t), 0)
+	case time.Time:
+		// Epoch time tagged with tag 1
+		exp = t
+	default:
+		return ErrInvalidHeader
+	}
+
+	now := time.Now()
+	if c.CurrentTime != nil {
+		now = c.CurrentTime()
+	}
+	if now.After(exp.Add(c.TimeLeeway)) {
+		return ErrMessageExpired
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders_SetExpiry(t *testing.T) {
+	h := NewHeaders()
+	exp := time.Unix(1700000000, 0)
+	require.NoError(t, h.SetExpiry(-70001, exp))
+	require.NoError(t, h.SetExpiry(-70001, exp))
+
+	v, err := h.GetProtected(-70001)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000), v)
+	crit, err := h.GetCritical()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(-70001)}, crit)
+
+	assert.ErrorIs(t, h.SetExpiry(HeaderAlgorithm, exp), ErrInvalidHeader)
+}
+
+func TestEncoding_DecodeExpiry(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	const label = -70001
+	exp := time.Unix(1700000000, 0)
+	encode := func(set func(h *Headers)) []byte {
+		msg := NewSign1Message()
+		msg.SetContent([]byte("expiring"))
+		set(msg.Headers)
+		require.NoError(t, msg.SetSigner(signer))
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+		return b
+	}
+	config := func(now time.Time) *Config {
+		return &Config{
+			GetVerifiers: staticVerifier(t, signer),
+			ExpiryHeader: label,
+			CurrentTime:  func() time.Time { return now },
+		}
+	}
+
+	withExpiry := encode(func(h *Headers) { require.NoError(t, h.SetExpiry(label, exp)) })
+	withoutExpiry := encode(func(h *Headers) {})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		config  *Config
+		wantErr error
+	}{
+		{"valid", withExpiry, config(exp.Add(-time.Minute)), nil},
+		{"expired", withExpiry, config(exp.Add(time.Minute)), ErrMessageExpired},
+		{"expired within leeway", withExpiry, func() *Config {
+			c := config(exp.Add(time.Minute))
+			c.TimeLeeway = 2 * time.Minute
+			return c
+		}(), nil},
+		{"missing", withoutExpiry, config(exp), nil},
+		{"missing required", withoutExpiry, func() *Config {
+			c := config(exp)
+			c.RequireExpiry = true
+			return c
+		}(), ErrMissingExpiry},
+		{"tag 1", encode(func(h *Headers) {
+			require.NoError(t, h.SetProtected(label, cbor.Tag{Number: 1, Content: exp.Unix()}))
+		}), config(exp.Add(time.Second)), ErrMessageExpired},
+		{"not an integer", encode(func(h *Headers) {
+			require.NoError(t, h.SetProtected(label, "tomorrow"))
+		}), config(exp), ErrInvalidHeader},
+		{"not validated", withExpiry, &Config{GetVerifiers: staticVerifier(t, signer)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := StdEncoding.Decode(tt.data, tt.config)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []byte("expiring"), msg.GetContent())
+		})
+	}
+}
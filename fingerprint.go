@@ -0,0 +1,86 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"errors"
+)
+
+// Fingerprint returns the hash of the signed components of the decoded COSE_Sign1 or COSE_Sign
+// message for deduplication and caching.
+//
+// The fingerprint covers the message tag, the received protected headers, the payload and
+// the signatures with their protected headers. Unprotected headers are excluded so messages
+// differing only in unprotected headers have the same fingerprint.
+func Fingerprint(msg Message, h crypto.Hash) ([]byte, error) {
+	switch m := msg.(type) {
+	case *Sign1Message:
+		if m.raw == nil {
+			return nil, ErrMessageNotDecoded
+		}
+		return fingerprintSign1(m.raw, h)
+	case *SignMessage:
+		if m.raw == nil {
+			return nil, ErrMessageNotDecoded
+		}
+		return fingerprintSign(m.raw, h)
+	case nil:
+		return nil, errors.New("message can not be nil")
+	}
+	return nil, ErrUnsupportedMessageTag{msg.GetMessageTag()}
+}
+
+// FingerprintRaw returns the fingerprint of the encoded COSE_Sign1 or COSE_Sign message without
+// verifying it, see Fingerprint.
+func FingerprintRaw(data []byte, h crypto.Hash) ([]byte, error) {
+	raw, err := StdEncoding.parseMessageTag(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch raw.Number {
+	case MessageTagSign1:
+		var m sign1Message
+		if err := StdEncoding.unmarshal(stageMessageBody, raw.Content, &m); err != nil {
+			return nil, decodeError(err)
+		}
+		return fingerprintSign1(&m, h)
+	case MessageTagSign:
+		var m signMessage
+		if err := StdEncoding.unmarshal(stageMessageBody, raw.Content, &m); err != nil {
+			return nil, decodeError(err)
+		}
+		return fingerprintSign(&m, h)
+	}
+	return nil, ErrUnsupportedMessageTag{raw.Number}
+}
+
+func fingerprintSign1(m *sign1Message, h crypto.Hash) ([]byte, error) {
+	return fingerprint(h, MessageTagSign1, m.Protected, []byte(m.Payload), m.Signature)
+}
+
+func fingerprintSign(m *signMessage, h crypto.Hash) ([]byte, error) {
+	signatures := make([]interface{}, 0, len(m.Signatures))
+	for _, s := range m.Signatures {
+		if s == nil {
+			return nil, ErrInvalidMessageStructure{errors.New("missing signature")}
+		}
+		signatures = append(signatures, []interface{}{s.Protected, s.Signature})
+	}
+	return fingerprint(h, MessageTagSign, m.Protected, []byte(m.Payload), signatures)
+}
+
+func fingerprint(h crypto.Hash, tag uint64, protected, payload []byte, signatures interface{}) ([]byte, error) {
+	if !h.Available() {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	data, err := StdEncoding.marshal([]interface{}{tag, protected, payload, signatures})
+	if err != nil {
+		return nil, err
+	}
+	hasher := h.New()
+	hasher.Write(data)
+	return hasher.Sum(nil), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint_Sign1(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("fingerprint"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, msg.SetSigner(signer))
+
+	_, err = Fingerprint(msg, crypto.SHA256)
+	assert.ErrorIs(t, err, ErrMessageNotDecoded)
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	decoded, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+
+	expected, err := FingerprintRaw(b, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Len(t, expected, 32)
+	fp, err := Fingerprint(decoded, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fp)
+
+	mutate := func(f func(m *sign1Message)) []byte {
+		raw, err := StdEncoding.parseMessageTag(b, nil)
+		require.NoError(t, err)
+		var m sign1Message
+		require.NoError(t, StdEncoding.unmarshal(stageMessageBody, raw.Content, &m))
+		f(&m)
+		data, err := StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign1, Content: m})
+		require.NoError(t, err)
+		return data
+	}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		equal bool
+	}{
+		{"unprotected added", mutate(func(m *sign1Message) { m.Unprotected[int64(33)] = "relay" }), true},
+		{"unprotected removed", mutate(func(m *sign1Message) { m.Unprotected = map[interface{}]interface{}{} }), true},
+		{"protected", mutate(func(m *sign1Message) { m.Protected = append(m.Protected[:len(m.Protected):len(m.Protected)], 0) }), false},
+		{"payload", mutate(func(m *sign1Message) { m.Payload = []byte("fingerprinT") }), false},
+		{"detached payload", mutate(func(m *sign1Message) { m.Payload = nil }), false},
+		{"signature", mutate(func(m *sign1Message) {
+			m.Signature = append([]byte{}, m.Signature...)
+			m.Signature[0] ^= 1
+		}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp, err := FingerprintRaw(tt.data, crypto.SHA256)
+			require.NoError(t, err)
+			if tt.equal {
+				assert.Equal(t, expected, fp)
+			} else {
+				assert.NotEqual(t, expected, fp)
+			}
+		})
+	}
+
+	_, err = FingerprintRaw(b, crypto.Hash(0))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestFingerprint_Sign(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("fingerprint"))
+	msg.AddSigner(signer)
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	decoded, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+
+	expected, err := FingerprintRaw(b, crypto.SHA256)
+	require.NoError(t, err)
+	fp, err := Fingerprint(decoded, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fp)
+
+	mutate := func(f func(m *signMessage)) []byte {
+		raw, err := StdEncoding.parseMessageTag(b, nil)
+		require.NoError(t, err)
+		var m signMessage
+		require.NoError(t, StdEncoding.unmarshal(stageMessageBody, raw.Content, &m))
+		f(&m)
+		data, err := StdEncoding.encMode.Marshal(cbor.Tag{Number: MessageTagSign, Content: m})
+		require.NoError(t, err)
+		return data
+	}
+
+	fp, err = FingerprintRaw(mutate(func(m *signMessage) {
+		m.Signatures[0].Unprotected = map[interface{}]interface{}{int64(4): []byte("kid")}
+	}), crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fp)
+
+	fp, err = FingerprintRaw(mutate(func(m *signMessage) {
+		m.Signatures[0].Protected = append(m.Signatures[0].Protected[:len(m.Signatures[0].Protected):len(m.Signatures[0].Protected)], 0)
+	}), crypto.SHA256)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, fp)
+
+	fp, err = FingerprintRaw(mutate(func(m *signMessage) {
+		m.Signatures = append(m.Signatures, m.Signatures[0])
+	}), crypto.SHA256)
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, fp)
+}
+
+func TestFingerprint_Unsupported(t *testing.T) {
+	_, err := Fingerprint(NewEncrypt0Message(), crypto.SHA256)
+	assert.Equal(t, ErrUnsupportedMessageTag{MessageTagEncrypt0}, err)
+	_, err = Fingerprint(nil, crypto.SHA256)
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Inputs causing failures are saved by the fuzzing engine in testdata/fuzz
+// and are run as regression tests by go test.
+
+func FuzzDecode(f *testing.F) {
+	var verifiers []*Verifier
+	for _, tt := range vectorAlgorithms {
+		signer, err := NewSigner(tt.alg, getPrivateKey(f, tt.key))
+		require.NoError(f, err)
+		verifier, err := signer.ToVerifier()
+		require.NoError(f, err)
+		verifiers = append(verifiers, verifier)
+
+		msg := NewSign1Message()
+		msg.SetContent([]byte("fuzz"))
+		require.NoError(f, msg.SetSigner(signer))
+		b, err := StdEncoding.Encode(msg)
+		require.NoError(f, err)
+		f.Add(b)
+
+		smsg := NewSignMessage()
+		smsg.SetContent([]byte("fuzz"))
+		smsg.AddSigner(signer)
+		b, err = StdEncoding.Encode(smsg)
+		require.NoError(f, err)
+		f.Add(b)
+	}
+
+	key := make([]byte, 16)
+	emsg := NewEncryptMessage()
+	emsg.SetContent([]byte("fuzz"))
+	require.NoError(f, emsg.AddRecipient(AlgorithmA128KW, key, nil))
+	b, err := StdEncoding.Encode(emsg)
+	require.NoError(f, err)
+	f.Add(b)
+
+	for _, seed := range fuzzSeedMessages(f) {
+		f.Add(seed)
+	}
+
+	config := &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return verifiers, nil
+		},
+		GetDecryptKey: func(*Headers) (interface{}, error) {
+			return key, nil
+		},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = StdEncoding.Decode(data, config)
+		_, _ = StdEncoding.DecodeWithExternal(data, []byte("external"), nil)
+		_ = CheckDeterministicEncoding(data)
+	})
+}
+
+func FuzzHeaders(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(mustHex(f, "a2012604436b6964"))
+	f.Add(mustHex(f, "a204436b6964180126"))
+	f.Add(mustHex(f, "a2012663616c67654553323536"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, err := ParseProtectedHeaders(StdEncoding, data)
+		if err != nil {
+			return
+		}
+		_ = h.GetAllProtected()
+		_, _ = h.GetProtected(HeaderAlgorithm)
+	})
+}
+
+// fuzzSeedMessages returns the test vectors and the DGC test suite messages if available.
+func fuzzSeedMessages(f *testing.F) [][]byte {
+	seeds := [][]byte{}
+	if b, err := hex.DecodeString(dgcTestMessage); err == nil {
+		seeds = append(seeds, b)
+	}
+
+	files, _ := filepath.Glob(filepath.Join("testdata", "vectors", "*.hex"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		if b, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil {
+			seeds = append(seeds, b)
+		}
+	}
+
+	_ = filepath.Walk("test-data/dgc", func(path string, info os.FileInfo, err error) error {
+		if err != nil || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var j map[string]interface{}
+		if json.Unmarshal(data, &j) != nil {
+			return nil
+		}
+		if s, ok := j["COSE"].(string); ok {
+			if b, err := hex.DecodeString(s); err == nil {
+				seeds = append(seeds, b)
+			}
+		}
+		return nil
+	})
+	return seeds
+}
+
+func FuzzECDSASignatureValues(f *testing.F) {
+	var verifiers []*Verifier
+	for _, tt := range vectorAlgorithms {
+		signer, err := NewSigner(tt.alg, getPrivateKey(f, tt.key))
+		require.NoError(f, err)
+		if _, ok := signer.GetPrivateKey().(*ecdsa.PrivateKey); !ok {
+			continue
+		}
+		verifier, err := signer.ToVerifier()
+		require.NoError(f, err)
+		verifiers = append(verifiers, verifier)
+
+		sig, err := signer.Sign(rand.Reader, []byte("fuzz"))
+		require.NoError(f, err)
+		f.Add(sig)
+	}
+
+	f.Fuzz(func(t *testing.T, sig []byte) {
+		for _, v := range verifiers {
+			n := curveByteSize(v.alg.KeyEllipticCurve)
+			r, s, err := ecdsaSignatureValues(sig, n)
+			if err == nil {
+				b, err := ecdsaSignature(r, s, n)
+				require.NoError(t, err)
+				require.Equal(t, sig, b)
+			}
+			_ = v.Verify([]byte("fuzz"), sig)
+		}
+	})
+}
@@ -4,22 +4,46 @@
 
 package cose
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
 
 const (
-	HeaderAlgorithm        = "alg"
-	HeaderCritical         = "crit"
-	HeaderContentType      = "content type"
-	HeaderKeyID            = "kid"
-	HeaderIV               = "IV"
-	HeaderPartialIV        = "Partial IV"
-	HeaderCounterSignature = "counter signature"
+	HeaderAlgorithm         = "alg"
+	HeaderCritical          = "crit"
+	HeaderContentType       = "content type"
+	HeaderKeyID             = "kid"
+	HeaderIV                = "IV"
+	HeaderPartialIV         = "Partial IV"
+	HeaderCounterSignature  = "counter signature"
+	HeaderCounterSignature0 = "counter signature0"
+	HeaderX5U               = "x5u"
+	// HeaderPayloadHashAlgorithm is the hash algorithm of a pre-hashed payload
+	HeaderPayloadHashAlgorithm = "payload hash alg"
 )
 
+// HeaderEntry represents a single header label and value.
+type HeaderEntry struct {
+	Key   interface{}
+	Value interface{}
+	// Name is the name of a common or registered private header label, empty for other labels
+	Name string
+}
+
 // Headers represents COSE protected and unprotected headers.
+//
+// Comparing *Headers with == compares the pointers, use HeadersEqual to compare the contents.
 type Headers struct {
 	protected   map[interface{}]interface{}
 	unprotected map[interface{}]interface{}
+	// gen is incremented when the headers are modified, it invalidates cached encodings
+	gen uint64
 }
 
 // NewHeaders creates a new Headers instance.
@@ -30,23 +54,54 @@ func NewHeaders() *Headers {
 	}
 }
 
+// generation returns the modification counter of the headers.
+func (h *Headers) generation() uint64 {
+	if h == nil {
+		return 0
+	}
+	return h.gen
+}
+
 func newHeaders(e *Encoding, protected []byte, unprotected map[interface{}]interface{}) (*Headers, error) {
+	var prot headerMap
+	if len(protected) > 0 {
+		if err := e.unmarshal(stageProtectedHeader, protected, &prot); err != nil {
+			return nil, headersDecodeError(err)
+		}
+	}
+	return NewHeadersFromMaps(prot, unprotected)
+}
+
+// NewHeadersFromMaps creates a new Headers instance from protected and unprotected header maps.
+// Header labels are normalized and labels occurring more than once in a map are rejected.
+func NewHeadersFromMaps(protected, unprotected map[interface{}]interface{}) (*Headers, error) {
 	h := NewHeaders()
 
+	if err := checkDuplicateLabels(unprotected); err != nil {
+		return nil, err
+	}
 	for k, v := range unprotected {
-		if err := h.Set(k, v); err != nil {
+		label, err := normalizeLabel(k)
+		if err != nil {
+			return nil, err
+		}
+		// Decoded unprotected alg and crit headers must not be moved to protected headers
+		if label == int64(1) || label == int64(2) {
+			h.unprotected[label] = v
+		} else if err := h.Set(label, v); err != nil {
 			return nil, err
 		}
 	}
 
-	var prot map[interface{}]interface{}
-	if len(protected) > 0 {
-		if err := e.decMode.Unmarshal(protected, &prot); err != nil {
+	if err := checkDuplicateLabels(protected); err != nil {
+		return nil, err
+	}
+	for k, v := range protected {
+		label, err := normalizeLabel(k)
+		if err != nil {
 			return nil, err
 		}
-	}
-	for k, v := range prot {
-		if err := h.SetProtected(k, v); err != nil {
+		if err := h.SetProtected(label, v); err != nil {
 			return nil, err
 		}
 	}
@@ -54,6 +109,71 @@ func newHeaders(e *Encoding, protected []byte, unprotected map[interface{}]inter
 	return h, nil
 }
 
+// ParseProtectedHeaders parses the encoded protected headers,
+// empty data results in empty headers.
+func ParseProtectedHeaders(e *Encoding, data []byte) (*Headers, error) {
+	if e == nil {
+		e = StdEncoding
+	}
+	return newHeaders(e, data, nil)
+}
+
+// normalizeLabel converts the header label to int64 for integer and common header labels.
+func normalizeLabel(key interface{}) (interface{}, error) {
+	switch label := key.(type) {
+	case string:
+		if k := headerLabel(label); k != 0 {
+			return k, nil
+		}
+		return label, nil
+	case int:
+		return int64(label), nil
+	case int64:
+		return label, nil
+	case uint64:
+		if label > math.MaxInt64 {
+			return nil, errors.New("invalid key value")
+		}
+		return int64(label), nil
+	default:
+		return nil, errors.New("invalid key type")
+	}
+}
+
+// checkLabel returns ErrReservedHeaderLabel for the reserved label 0 and the unassigned labels -4 to -7.
+// Labels -1 to -3 are the ECDH key agreement parameters and labels -65536 and below are for private use.
+func checkLabel(label int64) error {
+	if label == 0 || (label >= -7 && label <= -4) {
+		return ErrReservedHeaderLabel
+	}
+	return nil
+}
+
+// checkDuplicateLabels returns ErrMalformedHeaders if labels are duplicated after normalization.
+func checkDuplicateLabels(m map[interface{}]interface{}) error {
+	seen := make(map[interface{}]struct{}, len(m))
+	for k := range m {
+		label, err := normalizeLabel(k)
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[label]; ok {
+			return ErrMalformedHeaders{Label: label}
+		}
+		seen[label] = struct{}{}
+	}
+	return nil
+}
+
+// headersDecodeError converts duplicate map key errors to malformed headers errors.
+func headersDecodeError(err error) error {
+	var dup *cbor.DupMapKeyError
+	if errors.As(err, &dup) {
+		return ErrMalformedHeaders{Label: dup.Key}
+	}
+	return err
+}
+
 // MergeHeaders merges the given headers into the new Headers instance.
 func MergeHeaders(h1, h2 *Headers) *Headers {
 	h := NewHeaders()
@@ -63,6 +183,29 @@ func MergeHeaders(h1, h2 *Headers) *Headers {
 	return h
 }
 
+// HeadersEqual reports whether the headers have the same protected and unprotected headers,
+// integer and common header name labels are equal to the same int64 labels.
+// Values are compared with reflect.DeepEqual so int and int64 values are not equal.
+func HeadersEqual(a, b *Headers) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return headerMapsEqual(normalizedKeys(a.protected), normalizedKeys(b.protected)) &&
+		headerMapsEqual(normalizedKeys(a.unprotected), normalizedKeys(b.unprotected))
+}
+
+// normalizedKeys returns the header map with normalized labels, invalid labels are kept as is.
+func normalizedKeys(m map[interface{}]interface{}) map[interface{}]interface{} {
+	n := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		if label, err := normalizeLabel(k); err == nil {
+			k = label
+		}
+		n[k] = v
+	}
+	return n
+}
+
 // Merge merges the given headers into the current headers.
 func (h *Headers) Merge(other *Headers) {
 	if other == nil {
@@ -78,6 +221,78 @@ func (h *Headers) Merge(other *Headers) {
 		}
 		h.unprotected[k] = v
 	}
+	h.gen++
+}
+
+// MergeHeadersStrict merges the given headers into the new Headers instance
+// returning ErrHeaderConflict if the same label is set with different values.
+func MergeHeadersStrict(h1, h2 *Headers) (*Headers, error) {
+	h := NewHeaders()
+	if err := h.MergeStrict(h1); err != nil {
+		return nil, err
+	}
+	if err := h.MergeStrict(h2); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// MergeStrict merges the given headers into the current headers
+// returning ErrHeaderConflict if the same label is set with different values.
+// Current headers are not modified if a conflict is found.
+func (h *Headers) MergeStrict(other *Headers) error {
+	if other == nil {
+		return nil
+	}
+	for k, v := range other.protected {
+		if err := checkHeaderConflict(k, v, h.protected, h.unprotected); err != nil {
+			return err
+		}
+	}
+	for k, v := range other.unprotected {
+		if err := checkHeaderConflict(k, v, h.protected, h.unprotected); err != nil {
+			return err
+		}
+	}
+	h.Merge(other)
+	return nil
+}
+
+func checkHeaderConflict(label, value interface{}, buckets ...map[interface{}]interface{}) error {
+	for _, b := range buckets {
+		if v, ok := b[label]; ok && !headerValuesEqual(v, value) {
+			return ErrHeaderConflict{Label: label}
+		}
+	}
+	return nil
+}
+
+// headerLabel returns the integer label of the common or registered private header name,
+// zero is returned for other names.
+func headerLabel(name string) int64 {
+	if k := getCommonHeader(name); k != 0 {
+		return k
+	}
+	k, _ := privateHeaderLabel(name)
+	return k
+}
+
+// CommonHeaderLabel returns the integer label of the common header name
+// and whether the name is a known common header.
+func CommonHeaderLabel(name string) (int64, bool) {
+	label := getCommonHeader(name)
+	return label, label != 0
+}
+
+// CommonHeaderName returns the name of the common header label
+// and whether the label is a known common header.
+func CommonHeaderName(label int64) (string, bool) {
+	for _, name := range commonHeaderNames {
+		if getCommonHeader(name) == label {
+			return name, true
+		}
+	}
+	return "", false
 }
 
 func getCommonHeader(key string) int64 {
@@ -96,6 +311,12 @@ func getCommonHeader(key string) int64 {
 		return 6
 	case HeaderCounterSignature:
 		return 7
+	case HeaderCounterSignature0:
+		return 9
+	case HeaderX5U:
+		return 35
+	case HeaderPayloadHashAlgorithm:
+		return 258
 	default:
 		return 0
 	}
@@ -105,23 +326,42 @@ func getCommonHeader(key string) int64 {
 func (h *Headers) SetProtected(key, value interface{}) error {
 	switch label := key.(type) {
 	case string:
-		if k := getCommonHeader(label); k != 0 {
+		if k := headerLabel(label); k != 0 {
 			return h.SetProtected(k, value)
 		}
 		h.protected[key] = value
+		h.gen++
 	case int:
 		return h.SetProtected(int64(label), value)
 	case int64:
+		if err := checkLabel(label); err != nil {
+			return err
+		}
+		if err := checkPrivateHeaderValue(label, value); err != nil {
+			return err
+		}
+		if label == 2 {
+			crit, err := normalizeCritical(value)
+			if err != nil {
+				return err
+			}
+			value = crit
+		}
 		// Reslove alg value
 		if label == 1 {
-			if alg, ok := value.(string); ok {
-				a := getAlg(alg)
-				if a != nil {
-					value = a.Value
-				}
+			var a *algorithm
+			switch alg := value.(type) {
+			case string:
+				a = getAlg(alg)
+			case Algorithm:
+				a = getAlg(string(alg))
+			}
+			if a != nil {
+				value = a.Value
 			}
 		}
 		h.protected[key] = value
+		h.gen++
 	default:
 		return errors.New("invalid key type")
 	}
@@ -129,31 +369,36 @@ func (h *Headers) SetProtected(key, value interface{}) error {
 }
 
 // GetProtected returns the header with the given key from protected headers.
+//
+// Values of the alg header are returned as the algorithm name whether the algorithm
+// is encoded as an integer or as its name, values of unknown algorithms are returned as is.
 func (h *Headers) GetProtected(key interface{}) (interface{}, error) {
 	switch label := key.(type) {
 	case string:
-		if k := getCommonHeader(label); k != 0 {
+		if k := headerLabel(label); k != 0 {
 			return h.GetProtected(k)
 		}
-		return h.protected[label], nil
+		return resolveHeaderValue(h.protected[label]), nil
 	case int:
 		return h.GetProtected(int64(label))
 	case int64:
 		// Resolve algorithm value
 		if label == 1 {
-			value := h.protected[label]
-			var a *algorithm
-			switch v := value.(type) {
-			case int:
-				a = getAlgByValue(int64(v))
-			case int64:
-				a = getAlgByValue(v)
-			}
-			if a != nil {
-				return a.Name, nil
+			value, ok := h.protected[label]
+			if !ok {
+				return nil, ErrHeaderNotFound
 			}
+			return resolveAlgorithm(value), nil
 		}
-		return h.protected[label], nil
+		value, ok := h.protected[label]
+		if !ok {
+			return nil, nil
+		}
+		value = resolveHeaderValue(value)
+		if err := checkPrivateHeaderValue(label, value); err != nil {
+			return nil, err
+		}
+		return value, nil
 	default:
 		return nil, errors.New("invalid key type")
 	}
@@ -161,55 +406,231 @@ func (h *Headers) GetProtected(key interface{}) (interface{}, error) {
 
 // Set sets the header with the given key in unprotected headers.
 // `alg` and `crit` will always be set in protected headers.
+//
+// []byte values are stored as is and encoded as byte strings, their content is never interpreted as CBOR.
 func (h *Headers) Set(key, value interface{}) error {
 	switch label := key.(type) {
 	case string:
-		if k := getCommonHeader(label); k != 0 {
+		if k := headerLabel(label); k != 0 {
 			return h.Set(k, value)
 		}
 		h.unprotected[label] = value
+		h.gen++
 	case int:
 		return h.Set(int64(label), value)
 	case int64:
+		if err := checkLabel(label); err != nil {
+			return err
+		}
+		if err := checkPrivateHeaderValue(label, value); err != nil {
+			return err
+		}
 		// alg and crit MUST be set in protected headers
 		if label == 1 || label == 2 {
 			return h.SetProtected(label, value)
 		}
 		h.unprotected[label] = value
+		h.gen++
 	default:
 		return errors.New("invalid key type")
 	}
 	return nil
 }
 
+// MustSet is like Set but panics if the header can not be set,
+// it simplifies setting headers with labels and values known to be valid.
+func (h *Headers) MustSet(key, value interface{}) {
+	if err := h.Set(key, value); err != nil {
+		panic(fmt.Sprintf("cose: Set header %v: %v", key, err))
+	}
+}
+
 // Get returns the header with the given key from both protected and unprotected headers,
-// prioritizing protected headers.
+// prioritizing protected headers. ErrHeaderNotFound is returned if the header is not present,
+// a header present with CBOR null value is returned as nil value without error.
 func (h *Headers) Get(key interface{}) (interface{}, error) {
-	if v, err := h.GetProtected(key); err != nil {
+	v, ok, err := h.Lookup(key)
+	if err != nil {
 		return nil, err
-	} else if v != nil {
-		return v, nil
 	}
-	switch label := key.(type) {
-	case string:
-		if k := getCommonHeader(label); k != 0 {
-			return h.Get(k)
+	if !ok {
+		return nil, ErrHeaderNotFound
+	}
+	return v, nil
+}
+
+// Lookup returns the header with the given key from both protected and unprotected headers,
+// prioritizing protected headers, and whether the header is present.
+//
+// A header present with CBOR null value is returned as nil value with present set to true.
+func (h *Headers) Lookup(key interface{}) (value interface{}, present bool, err error) {
+	label, err := normalizeLabel(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if value, present = h.protected[label]; !present {
+		value, present = h.unprotected[label]
+	}
+	value = resolveHeaderValue(value)
+	if present && label == int64(1) {
+		value = resolveAlgorithm(value)
+	}
+	if present {
+		if err := checkPrivateHeaderValue(label, value); err != nil {
+			return nil, true, err
 		}
-		return h.unprotected[key], nil
+	}
+	return value, present, nil
+}
+
+// Has reports whether the header with the given key is present in protected or unprotected headers.
+func (h *Headers) Has(key interface{}) bool {
+	_, ok, _ := h.Lookup(key)
+	return ok
+}
+
+// resolveAlgorithm returns the algorithm name for a known algorithm header value.
+func resolveAlgorithm(value interface{}) interface{} {
+	var a *algorithm
+	switch v := value.(type) {
 	case int:
-		return h.Get(int64(label))
+		a = getAlgByValue(int64(v))
+	case int64:
+		a = getAlgByValue(v)
+	}
+	if a != nil {
+		return a.Name
+	}
+	return value
+}
+
+// SetIV sets the IV header in unprotected headers.
+func (h *Headers) SetIV(iv []byte) error {
+	return h.setIV(HeaderIV, HeaderPartialIV, iv)
+}
+
+// GetIV returns the IV header, ErrInvalidHeader is returned if the value is not a byte string.
+func (h *Headers) GetIV() ([]byte, error) {
+	return h.getBytes(HeaderIV)
+}
+
+// SetPartialIV sets the Partial IV header in unprotected headers.
+func (h *Headers) SetPartialIV(iv []byte) error {
+	return h.setIV(HeaderPartialIV, HeaderIV, iv)
+}
+
+// GetPartialIV returns the Partial IV header, ErrInvalidHeader is returned if the value is not a byte string.
+func (h *Headers) GetPartialIV() ([]byte, error) {
+	return h.getBytes(HeaderPartialIV)
+}
+
+// setIV sets the IV or Partial IV header, both must not be present at the same time.
+func (h *Headers) setIV(key, other string, iv []byte) error {
+	if len(iv) == 0 {
+		return errors.New("IV can not be empty")
+	}
+	if h.Has(other) {
+		return ErrConflictingIV
+	}
+	return h.Set(key, iv)
+}
+
+func (h *Headers) getBytes(key string) ([]byte, error) {
+	v, ok, err := h.Lookup(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, ErrInvalidHeader
+	}
+	return b, nil
+}
+
+// GetAlgorithm returns the algorithm of the protected alg header, the same algorithm is returned
+// whether it is encoded as an integer or as its name. Unknown algorithm names are returned as is,
+// unknown integer algorithms as ErrUnsupportedAlgorithm.
+func (h *Headers) GetAlgorithm() (Algorithm, error) {
+	v, err := h.GetProtected(HeaderAlgorithm)
+	if err != nil {
+		return "", err
+	}
+	switch alg := v.(type) {
+	case string:
+		return Algorithm(alg), nil
+	case Algorithm:
+		return alg, nil
 	case int64:
-		return h.unprotected[key], nil
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedAlgorithm, alg)
 	default:
-		return nil, errors.New("invalid key type")
+		return "", fmt.Errorf("%w: alg header value %T", ErrInvalidHeader, v)
+	}
+}
+
+// GetCritical returns the labels of the crit header or nil if the header is not present.
+func (h *Headers) GetCritical() ([]interface{}, error) {
+	v, err := h.GetProtected(HeaderCritical)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return normalizeCritical(v)
+}
+
+// normalizeCritical converts the crit header value to a non-empty array of int64 and string labels,
+// string labels of common headers are converted to their integer labels.
+func normalizeCritical(value interface{}) ([]interface{}, error) {
+	var labels []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		labels = v
+	case []string:
+		for _, l := range v {
+			labels = append(labels, l)
+		}
+	case []int:
+		for _, l := range v {
+			labels = append(labels, l)
+		}
+	case []int64:
+		for _, l := range v {
+			labels = append(labels, l)
+		}
+	default:
+		return nil, ErrInvalidHeader
+	}
+	if len(labels) == 0 {
+		return nil, ErrInvalidHeader
+	}
+	crit := make([]interface{}, len(labels))
+	for i, l := range labels {
+		label, err := normalizeLabel(l)
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+		crit[i] = label
+	}
+	return crit, nil
+}
+
+// ValidateCritical checks that every label listed in the crit header is present in protected headers.
+func (h *Headers) ValidateCritical() error {
+	labels, err := h.GetCritical()
+	if err != nil {
+		return err
 	}
+	for _, l := range labels {
+		if _, ok := h.protected[l]; !ok {
+			return ErrCriticalHeaderMissing{Label: l}
+		}
+	}
+	return nil
 }
 
 // Delete removes the header with the given key from protected and unprotected headers.
 func (h *Headers) Delete(key interface{}) {
 	switch label := key.(type) {
 	case string:
-		if k := getCommonHeader(label); k != 0 {
+		if k := headerLabel(label); k != 0 {
 			key = k
 		}
 	case int:
@@ -217,4 +638,218 @@ func (h *Headers) Delete(key interface{}) {
 	}
 	delete(h.protected, key)
 	delete(h.unprotected, key)
+	h.gen++
+}
+
+// GetAllProtected returns all protected headers sorted by label,
+// integer labels are sorted before string labels.
+func (h *Headers) GetAllProtected() []HeaderEntry {
+	entries := make([]HeaderEntry, 0, len(h.protected))
+	for k := range h.protected {
+		v, err := h.GetProtected(k)
+		if err != nil {
+			v = resolveHeaderValue(h.protected[k])
+		}
+		entries = append(entries, HeaderEntry{Key: k, Value: v, Name: headerName(k)})
+	}
+	sortHeaderEntries(entries)
+	return entries
+}
+
+// GetAllUnprotected returns all unprotected headers sorted by label,
+// integer labels are sorted before string labels.
+func (h *Headers) GetAllUnprotected() []HeaderEntry {
+	entries := make([]HeaderEntry, 0, len(h.unprotected))
+	for k, v := range h.unprotected {
+		entries = append(entries, HeaderEntry{Key: k, Value: resolveHeaderValue(v), Name: headerName(k)})
+	}
+	sortHeaderEntries(entries)
+	return entries
+}
+
+// commonHeaderNames are the names of the common headers
+var commonHeaderNames = []string{
+	HeaderAlgorithm, HeaderCritical, HeaderContentType, HeaderKeyID, HeaderIV, HeaderPartialIV,
+	HeaderCounterSignature, HeaderCounterSignature0, HeaderX5U, HeaderPayloadHashAlgorithm,
+}
+
+// headerName returns the name of the common or registered private header label.
+func headerName(label interface{}) string {
+	l, ok := label.(int64)
+	if !ok {
+		return ""
+	}
+	if name, ok := CommonHeaderName(l); ok {
+		return name
+	}
+	if p, ok := privateHeaderFor(l); ok {
+		return p.name
+	}
+	return ""
+}
+
+// String returns the diagnostic representation of the headers with the names of
+// common and registered private header labels, such as protected {alg(1): ES256}.
+func (h *Headers) String() string {
+	return "protected " + formatHeaderEntries(h.GetAllProtected()) +
+		" unprotected " + formatHeaderEntries(h.GetAllUnprotected())
+}
+
+func formatHeaderEntries(entries []HeaderEntry) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		switch {
+		case e.Name != "":
+			fmt.Fprintf(&b, "%s(%v)", e.Name, e.Key)
+		case isTextLabel(e.Key):
+			fmt.Fprintf(&b, "%q", e.Key)
+		default:
+			fmt.Fprint(&b, e.Key)
+		}
+		b.WriteString(": ")
+		switch v := e.Value.(type) {
+		case []byte:
+			fmt.Fprintf(&b, "h'%x'", v)
+		case string:
+			fmt.Fprintf(&b, "%q", v)
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func isTextLabel(label interface{}) bool {
+	_, ok := label.(string)
+	return ok
+}
+
+func sortHeaderEntries(entries []HeaderEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return headerLabelLess(entries[i].Key, entries[j].Key)
+	})
+}
+
+func headerLabelLess(a, b interface{}) bool {
+	ai, aok := a.(int64)
+	bi, bok := b.(int64)
+	switch {
+	case aok && bok:
+		return ai < bi
+	case aok != bok:
+		return aok
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	switch {
+	case aok && bok:
+		return as < bs
+	case aok != bok:
+		return aok
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+// ProtectedOnly returns a copy of the headers containing only protected headers,
+// values in it are covered by the message signature.
+func (h *Headers) ProtectedOnly() *Headers {
+	c := NewHeaders()
+	for k, v := range h.protected {
+		c.protected[k] = v
+	}
+	return c
+}
+
+// Unprotected returns a copy of the headers containing only unprotected headers,
+// values in it are not covered by the message signature and can be modified in transit.
+func (h *Headers) Unprotected() *Headers {
+	c := NewHeaders()
+	for k, v := range h.unprotected {
+		c.unprotected[k] = v
+	}
+	return c
+}
+
+// ReplaceUnprotected replaces all unprotected headers with the unprotected headers of u,
+// protected headers are kept. u must not contain protected headers.
+func (h *Headers) ReplaceUnprotected(u *Headers) error {
+	if u == nil {
+		h.unprotected = make(map[interface{}]interface{})
+		h.gen++
+		return nil
+	}
+	if len(u.protected) > 0 {
+		return errors.New("unprotected headers contain protected headers")
+	}
+	h.unprotected = make(map[interface{}]interface{}, len(u.unprotected))
+	for k, v := range u.unprotected {
+		h.unprotected[k] = v
+	}
+	h.gen++
+	return nil
+}
+
+// Clone returns a shallow copy of the headers, the header maps are copied but values
+// such as byte slices, arrays and maps are shared with the original headers.
+func (h *Headers) Clone() *Headers {
+	c := NewHeaders()
+	for k, v := range h.protected {
+		c.protected[k] = v
+	}
+	for k, v := range h.unprotected {
+		c.unprotected[k] = v
+	}
+	return c
+}
+
+// Copy returns a deep copy of the headers, byte slices, arrays and maps are copied
+// recursively so the copy does not refer to the original headers or the decoded data.
+func (h *Headers) Copy() *Headers {
+	c := NewHeaders()
+	for k, v := range h.protected {
+		c.protected[k] = copyHeaderValue(v)
+	}
+	for k, v := range h.unprotected {
+		c.unprotected[k] = copyHeaderValue(v)
+	}
+	return c
+}
+
+func copyHeaderValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		if value == nil {
+			return value
+		}
+		return append([]byte{}, value...)
+	case []interface{}:
+		if value == nil {
+			return value
+		}
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = copyHeaderValue(item)
+		}
+		return items
+	case map[interface{}]interface{}:
+		if value == nil {
+			return value
+		}
+		m := make(map[interface{}]interface{}, len(value))
+		for k, item := range value {
+			m[k] = copyHeaderValue(item)
+		}
+		return m
+	case cbor.Tag:
+		value.Content = copyHeaderValue(value.Content)
+		return value
+	case *lazyHeaderValue:
+		return &lazyHeaderValue{raw: append([]byte{}, value.raw...)}
+	}
+	return v
 }
@@ -0,0 +1,174 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// HeaderValueType is the value type of a registered private header.
+type HeaderValueType int
+
+// Value types of registered private headers
+const (
+	// HeaderValueAny allows values of any type
+	HeaderValueAny HeaderValueType = iota
+	// HeaderValueInt allows integer values
+	HeaderValueInt
+	// HeaderValueText allows text string values
+	HeaderValueText
+	// HeaderValueBytes allows byte string values
+	HeaderValueBytes
+	// HeaderValueBool allows boolean values
+	HeaderValueBool
+	// HeaderValueArray allows array values
+	HeaderValueArray
+	// HeaderValueMap allows map values
+	HeaderValueMap
+)
+
+func (t HeaderValueType) String() string {
+	switch t {
+	case HeaderValueAny:
+		return "any"
+	case HeaderValueInt:
+		return "int"
+	case HeaderValueText:
+		return "text"
+	case HeaderValueBytes:
+		return "bytes"
+	case HeaderValueBool:
+		return "bool"
+	case HeaderValueArray:
+		return "array"
+	case HeaderValueMap:
+		return "map"
+	default:
+		return fmt.Sprintf("HeaderValueType(%d)", int(t))
+	}
+}
+
+// allows reports whether the value is of the type.
+func (t HeaderValueType) allows(value interface{}) bool {
+	switch t {
+	case HeaderValueAny:
+		return true
+	case HeaderValueText:
+		_, ok := value.(string)
+		return ok
+	case HeaderValueBytes:
+		_, ok := value.([]byte)
+		return ok
+	case HeaderValueBool:
+		_, ok := value.(bool)
+		return ok
+	}
+	if value == nil {
+		return false
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return t == HeaderValueInt
+	case reflect.Slice, reflect.Array:
+		_, isBytes := value.([]byte)
+		return t == HeaderValueArray && !isBytes
+	case reflect.Map:
+		return t == HeaderValueMap
+	default:
+		return false
+	}
+}
+
+type privateHeader struct {
+	name      string
+	label     int64
+	valueType HeaderValueType
+}
+
+// privateHeaders is the process-wide registry of private headers.
+var privateHeaders = struct {
+	sync.RWMutex
+	byName  map[string]privateHeader
+	byLabel map[int64]privateHeader
+}{
+	byName:  make(map[string]privateHeader),
+	byLabel: make(map[int64]privateHeader),
+}
+
+// RegisterPrivateHeader registers the name and integer label of a private header, after which the
+// name can be used as the header label and header values are validated against the value type.
+// Values of received messages are validated when the header is read.
+//
+// ErrHeaderRegistered is returned if the name or label is a common header or registered with
+// different parameters, registering the same header again has no effect. The registry is
+// process-wide so headers should be registered during initialization.
+func RegisterPrivateHeader(name string, label int64, valueType HeaderValueType) error {
+	if name == "" {
+		return errors.New("header name can not be empty")
+	}
+	if valueType < HeaderValueAny || valueType > HeaderValueMap {
+		return fmt.Errorf("invalid header value type %v", valueType)
+	}
+	if err := checkLabel(label); err != nil {
+		return err
+	}
+	// Labels -1 to -3 are the ECDH key agreement parameters
+	if getCommonHeader(name) != 0 || isCommonLabel(label) || (label >= -3 && label <= -1) {
+		return ErrHeaderRegistered
+	}
+
+	h := privateHeader{name: name, label: label, valueType: valueType}
+	privateHeaders.Lock()
+	defer privateHeaders.Unlock()
+	if r, ok := privateHeaders.byName[name]; ok {
+		if r == h {
+			return nil
+		}
+		return ErrHeaderRegistered
+	}
+	if _, ok := privateHeaders.byLabel[label]; ok {
+		return ErrHeaderRegistered
+	}
+	privateHeaders.byName[name] = h
+	privateHeaders.byLabel[label] = h
+	return nil
+}
+
+// privateHeaderLabel returns the label of the registered private header name.
+func privateHeaderLabel(name string) (int64, bool) {
+	privateHeaders.RLock()
+	defer privateHeaders.RUnlock()
+	h, ok := privateHeaders.byName[name]
+	return h.label, ok
+}
+
+// privateHeaderFor returns the registered private header of the label.
+func privateHeaderFor(label interface{}) (privateHeader, bool) {
+	l, ok := label.(int64)
+	if !ok {
+		return privateHeader{}, false
+	}
+	privateHeaders.RLock()
+	defer privateHeaders.RUnlock()
+	h, ok := privateHeaders.byLabel[l]
+	return h, ok
+}
+
+// checkPrivateHeaderValue returns ErrInvalidHeader if the value is not of the type of the registered
+// private header label, values decoded on access are checked when read.
+func checkPrivateHeaderValue(label, value interface{}) error {
+	if _, lazy := value.(*lazyHeaderValue); lazy {
+		return nil
+	}
+	h, ok := privateHeaderFor(label)
+	if !ok || h.valueType.allows(value) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s header value %T is not %v", ErrInvalidHeader, h.name, value, h.valueType)
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterPrivateHeader(t *testing.T) {
+	require.NoError(t, RegisterPrivateHeader("x-registry-profile", -80001, HeaderValueText))
+	// Identical registration is idempotent, also when done concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, RegisterPrivateHeader("x-registry-profile", -80001, HeaderValueText))
+		}()
+	}
+	wg.Wait()
+
+	tests := []struct {
+		name      string
+		label     int64
+		valueType HeaderValueType
+		err       error
+	}{
+		{HeaderKeyID, -80002, HeaderValueBytes, ErrHeaderRegistered},
+		{"x-registry-kid", 4, HeaderValueBytes, ErrHeaderRegistered},
+		{"x-registry-ephemeral", -1, HeaderValueMap, ErrHeaderRegistered},
+		{"x-registry-profile", -80002, HeaderValueText, ErrHeaderRegistered},
+		{"x-registry-profile", -80001, HeaderValueInt, ErrHeaderRegistered},
+		{"x-registry-other", -80001, HeaderValueText, ErrHeaderRegistered},
+		{"x-registry-reserved", 0, HeaderValueText, ErrReservedHeaderLabel},
+	}
+	for _, tt := range tests {
+		assert.ErrorIs(t, RegisterPrivateHeader(tt.name, tt.label, tt.valueType), tt.err, tt.name)
+	}
+	assert.Error(t, RegisterPrivateHeader("", -80003, HeaderValueText))
+	assert.Error(t, RegisterPrivateHeader("x-registry-type", -80003, HeaderValueType(100)))
+}
+
+func TestRegisterPrivateHeader_Headers(t *testing.T) {
+	require.NoError(t, RegisterPrivateHeader("x-registry-version", -80010, HeaderValueInt))
+	require.NoError(t, RegisterPrivateHeader("x-registry-tags", -80011, HeaderValueArray))
+
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected("x-registry-version", 2))
+	require.NoError(t, h.Set("x-registry-tags", []string{"a", "b"}))
+	assert.Equal(t, 2, h.protected[int64(-80010)])
+	v, err := h.Get("x-registry-version")
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+
+	assert.ErrorIs(t, h.SetProtected("x-registry-version", "two"), ErrInvalidHeader)
+	assert.ErrorIs(t, h.Set(int64(-80010), []byte{2}), ErrInvalidHeader)
+	assert.ErrorIs(t, h.Set("x-registry-tags", []byte("ab")), ErrInvalidHeader)
+	assert.EqualError(t, h.Set("x-registry-tags", true), "invalid header value: x-registry-tags header value bool is not array")
+
+	// Received values are validated when read
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	msg := NewSign1Message().WithContent([]byte("test")).WithSigner(signer).WithProtectedHeader(int64(-80012), "v1")
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	require.NoError(t, RegisterPrivateHeader("x-registry-late", -80012, HeaderValueInt))
+	dec, err := StdEncoding.DecodeSign1(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	_, err = dec.Headers.Get("x-registry-late")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	_, err = dec.Headers.GetProtected(int64(-80012))
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	assert.Equal(t, []HeaderEntry{
+		{Key: int64(-80012), Value: "v1", Name: "x-registry-late"},
+		{Key: int64(1), Value: "ES256", Name: HeaderAlgorithm},
+	}, dec.Headers.GetAllProtected())
+}
+
+func TestHeaders_String(t *testing.T) {
+	require.NoError(t, RegisterPrivateHeader("x-registry-app", -80020, HeaderValueText))
+
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	require.NoError(t, h.SetProtected("x-registry-app", "wallet"))
+	require.NoError(t, h.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, h.Set(-80021, true))
+	require.NoError(t, h.Set("label", int64(1)))
+	assert.Equal(t, `protected {x-registry-app(-80020): "wallet", alg(1): "ES256"} `+
+		`unprotected {-80021: true, kid(4): h'6b6964', "label": 1}`, h.String())
+	assert.Equal(t, "protected {} unprotected {}", NewHeaders().String())
+}
@@ -40,6 +40,67 @@ func TestHeadersMergeHeaders(t *testing.T) {
 	assert.Equal(t, 2, h.unprotected[HeaderKeyID])
 }
 
+func TestHeadersEqual(t *testing.T) {
+	h1 := NewHeaders()
+	require.NoError(t, h1.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	require.NoError(t, h1.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, h1.Set(-70000, "value"))
+	h2 := NewHeaders()
+	require.NoError(t, h2.Set(int64(-70000), "value"))
+	require.NoError(t, h2.Set(int64(4), []byte("kid")))
+	require.NoError(t, h2.Set(1, int64(-7)))
+
+	assert.False(t, h1 == h2)
+	assert.True(t, HeadersEqual(h1, h2))
+	assert.True(t, HeadersEqual(h1, MergeHeaders(h2, nil)))
+
+	// Labels set directly in the maps are compared by their normalized value
+	h3 := NewHeaders()
+	h3.protected[1] = int64(-7)
+	h3.unprotected[HeaderKeyID] = []byte("kid")
+	h3.unprotected[-70000] = "value"
+	assert.True(t, HeadersEqual(h1, h3))
+
+	require.NoError(t, h2.Set(HeaderKeyID, []byte("other")))
+	assert.False(t, HeadersEqual(h1, h2))
+	assert.False(t, HeadersEqual(h1, h1.Unprotected()))
+	assert.False(t, HeadersEqual(h1, nil))
+	assert.True(t, HeadersEqual(nil, nil))
+}
+
+func TestCommonHeaderLabel(t *testing.T) {
+	tests := []struct {
+		name  string
+		label int64
+	}{
+		{HeaderAlgorithm, 1},
+		{HeaderCritical, 2},
+		{HeaderContentType, 3},
+		{HeaderKeyID, 4},
+		{HeaderIV, 5},
+		{HeaderPartialIV, 6},
+		{HeaderCounterSignature, 7},
+		{HeaderCounterSignature0, 9},
+		{HeaderX5U, 35},
+		{HeaderPayloadHashAlgorithm, 258},
+	}
+	for _, tt := range tests {
+		label, ok := CommonHeaderLabel(tt.name)
+		assert.True(t, ok, tt.name)
+		assert.Equal(t, tt.label, label, tt.name)
+		name, ok := CommonHeaderName(tt.label)
+		assert.True(t, ok, tt.name)
+		assert.Equal(t, tt.name, name)
+	}
+
+	label, ok := CommonHeaderLabel("unknown")
+	assert.False(t, ok)
+	assert.Zero(t, label)
+	name, ok := CommonHeaderName(8)
+	assert.False(t, ok)
+	assert.Empty(t, name)
+}
+
 func TestHeaders_GetSet(t *testing.T) {
 	type args struct {
 		key           interface{}
@@ -68,8 +129,8 @@ func TestHeaders_GetSet(t *testing.T) {
 			args: args{
 				key:           HeaderCritical,
 				expectedKey:   getCommonHeader(HeaderCritical),
-				value:         []string{"reserved"},
-				expectedValue: []string{"reserved"},
+				value:         []interface{}{"reserved"},
+				expectedValue: []interface{}{"reserved"},
 			},
 			protected: true,
 		},
@@ -118,6 +179,15 @@ func TestHeaders_GetSet(t *testing.T) {
 				expectedValue: 1,
 			},
 		},
+		{
+			name: HeaderCounterSignature0,
+			args: args{
+				key:           HeaderCounterSignature0,
+				expectedKey:   int64(9),
+				value:         []byte{1},
+				expectedValue: []byte{1},
+			},
+		},
 		{
 			name: "string key",
 			args: args{
@@ -194,8 +264,8 @@ func TestHeaders_GetSetProtected(t *testing.T) {
 			args: args{
 				key:           HeaderCritical,
 				expectedKey:   getCommonHeader(HeaderCritical),
-				value:         []string{"reserved"},
-				expectedValue: []string{"reserved"},
+				value:         []interface{}{"reserved"},
+				expectedValue: []interface{}{"reserved"},
 			},
 		},
 		{
@@ -262,3 +332,436 @@ func TestHeaders_Delete(t *testing.T) {
 
 	assert.Len(t, h.protected, 0)
 }
+
+func TestHeaders_DecodeDuplicateLabels(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		label interface{}
+	}{
+		{
+			name:  "protected alg",
+			data:  "d28446a20126013822a0447465737440",
+			label: int64(1),
+		},
+		{
+			name:  "protected kid",
+			data:  "d28447a2044101044102a0447465737440",
+			label: int64(4),
+		},
+		{
+			name:  "unprotected alg",
+			data:  "d28440a20126013822447465737440",
+			label: int64(1),
+		},
+		{
+			name:  "unprotected kid",
+			data:  "d28443a10126a2044101044102447465737440",
+			label: int64(4),
+		},
+		{
+			name:  "sign protected kid",
+			data:  "d8628440a044746573748183" + "47a2044101044102" + "a040",
+			label: int64(4),
+		},
+		{
+			name:  "sign unprotected kid",
+			data:  "d8628440a044746573748183" + "43a10126" + "a2044101044102" + "40",
+			label: int64(4),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StdEncoding.Decode(mustHex(t, tt.data), nil)
+			var headersErr ErrMalformedHeaders
+			require.ErrorAs(t, err, &headersErr)
+			assert.Equal(t, tt.label, headersErr.Label)
+		})
+	}
+}
+
+func TestHeadersMergeHeadersStrict(t *testing.T) {
+	h1 := NewHeaders()
+	h2 := NewHeaders()
+
+	require.NoError(t, h1.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	require.NoError(t, h1.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, h2.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	require.NoError(t, h2.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, h2.Set(HeaderContentType, "text/plain"))
+
+	h, err := MergeHeadersStrict(h1, h2)
+	require.NoError(t, err)
+	assert.Len(t, h.protected, 1)
+	assert.Len(t, h.unprotected, 2)
+
+	require.NoError(t, h2.Set(HeaderKeyID, []byte("other")))
+	_, err = MergeHeadersStrict(h1, h2)
+	assert.ErrorIs(t, err, ErrHeaderConflict{Label: int64(4)})
+}
+
+func TestHeaders_MergeStrictAcrossBuckets(t *testing.T) {
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected(HeaderKeyID, []byte("kid")))
+
+	other := NewHeaders()
+	require.NoError(t, other.Set(HeaderKeyID, []byte("other")))
+
+	err := h.MergeStrict(other)
+	assert.ErrorIs(t, err, ErrHeaderConflict{Label: int64(4)})
+	assert.Len(t, h.unprotected, 0)
+}
+
+func TestHeaders_GetAll(t *testing.T) {
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected("custom", "b"))
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, AlgorithmES256))
+	require.NoError(t, h.SetProtected(HeaderContentType, "text/plain"))
+	require.NoError(t, h.SetProtected("another", "a"))
+	require.NoError(t, h.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, h.Set(-1, "negative"))
+	require.NoError(t, h.Set("label", "value"))
+
+	expectedProtected := []HeaderEntry{
+		{Key: int64(1), Value: "ES256", Name: HeaderAlgorithm},
+		{Key: int64(3), Value: "text/plain", Name: HeaderContentType},
+		{Key: "another", Value: "a"},
+		{Key: "custom", Value: "b"},
+	}
+	expectedUnprotected := []HeaderEntry{
+		{Key: int64(-1), Value: "negative"},
+		{Key: int64(4), Value: []byte("kid"), Name: HeaderKeyID},
+		{Key: "label", Value: "value"},
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expectedProtected, h.GetAllProtected())
+		assert.Equal(t, expectedUnprotected, h.GetAllUnprotected())
+	}
+}
+
+func TestHeaders_NewHeadersFromMaps(t *testing.T) {
+	h, err := NewHeadersFromMaps(
+		map[interface{}]interface{}{"alg": "ES256", 3: "text/plain"},
+		map[interface{}]interface{}{HeaderKeyID: []byte("kid"), uint64(33): []byte{1}, "custom": "value"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, map[interface{}]interface{}{int64(1): int64(-7), int64(3): "text/plain"}, h.protected)
+	assert.Equal(t, map[interface{}]interface{}{int64(4): []byte("kid"), int64(33): []byte{1}, "custom": "value"}, h.unprotected)
+
+	_, err = NewHeadersFromMaps(map[interface{}]interface{}{"alg": "ES256", int64(1): -7}, nil)
+	assert.ErrorIs(t, err, ErrMalformedHeaders{Label: int64(1)})
+
+	_, err = NewHeadersFromMaps(nil, map[interface{}]interface{}{"kid": []byte("a"), 4: []byte("b")})
+	assert.ErrorIs(t, err, ErrMalformedHeaders{Label: int64(4)})
+
+	_, err = NewHeadersFromMaps(map[interface{}]interface{}{1.5: "value"}, nil)
+	assert.Error(t, err)
+}
+
+func TestHeaders_GetProtectedAlgorithmNotFound(t *testing.T) {
+	h := NewHeaders()
+	_, err := h.GetProtected(HeaderAlgorithm)
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+	alg, err := h.Get(HeaderAlgorithm)
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+	assert.Nil(t, alg)
+
+	// Decoded unprotected alg header is not moved to protected headers
+	h, err = NewHeadersFromMaps(nil, map[interface{}]interface{}{int64(1): int64(-7)})
+	require.NoError(t, err)
+	_, err = h.GetProtected(HeaderAlgorithm)
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+	alg, err = h.Get(HeaderAlgorithm)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", alg)
+}
+
+func TestHeaders_ParseProtectedHeaders(t *testing.T) {
+	h, err := ParseProtectedHeaders(StdEncoding, mustHex(t, "a2012604436b6964"))
+	require.NoError(t, err)
+	alg, err := h.GetProtected(HeaderAlgorithm)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", alg)
+	kid, err := h.GetProtected(HeaderKeyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("kid"), kid)
+
+	h, err = ParseProtectedHeaders(nil, []byte{})
+	require.NoError(t, err)
+	assert.Empty(t, h.protected)
+	assert.Empty(t, h.unprotected)
+
+	// {1: -7, "alg": "ES256"}
+	_, err = ParseProtectedHeaders(StdEncoding, mustHex(t, "a2012663616c67654553323536"))
+	assert.ErrorIs(t, err, ErrMalformedHeaders{Label: int64(1)})
+}
+
+func TestHeaders_IV(t *testing.T) {
+	h := NewHeaders()
+	iv, err := h.GetIV()
+	require.NoError(t, err)
+	assert.Nil(t, iv)
+
+	assert.Error(t, h.SetIV(nil))
+	require.NoError(t, h.SetIV([]byte{1, 2, 3}))
+	iv, err = h.GetIV()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, iv)
+	assert.Equal(t, []byte{1, 2, 3}, h.unprotected[getCommonHeader(HeaderIV)])
+	assert.Empty(t, h.protected)
+
+	assert.ErrorIs(t, h.SetPartialIV([]byte{4}), ErrConflictingIV)
+	h.Delete(HeaderIV)
+	require.NoError(t, h.SetPartialIV([]byte{4}))
+	iv, err = h.GetPartialIV()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{4}, iv)
+	assert.ErrorIs(t, h.SetIV([]byte{1}), ErrConflictingIV)
+
+	require.NoError(t, h.SetProtected(HeaderPartialIV, 123))
+	_, err = h.GetPartialIV()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	require.NoError(t, h.Set(HeaderIV, "iv"))
+	_, err = h.GetIV()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestHeaders_ValidateCritical(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.Headers.SetProtected(HeaderCritical, []interface{}{"x-custom", int64(-70000)}))
+	require.NoError(t, msg.Headers.SetProtected(int64(-70000), true))
+	require.NoError(t, msg.Headers.Set("x-custom", "unprotected"))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, config)
+	assert.Equal(t, ErrCriticalHeaderMissing{Label: "x-custom"}, err)
+
+	require.NoError(t, msg.Headers.SetProtected("x-custom", "protected"))
+	b, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	crit, err := dec.(*Sign1Message).Headers.GetCritical()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"x-custom", int64(-70000)}, crit)
+
+	h := NewHeaders()
+	crit, err = h.GetCritical()
+	require.NoError(t, err)
+	assert.Nil(t, crit)
+	assert.ErrorIs(t, h.SetProtected(HeaderCritical, []interface{}{}), ErrInvalidHeader)
+	assert.ErrorIs(t, h.SetProtected(HeaderCritical, []int64{}), ErrInvalidHeader)
+	assert.ErrorIs(t, h.SetProtected(HeaderCritical, "x-custom"), ErrInvalidHeader)
+	assert.ErrorIs(t, h.SetProtected(HeaderCritical, []interface{}{true}), ErrInvalidHeader)
+	h.protected[getCommonHeader(HeaderCritical)] = []interface{}{}
+	assert.ErrorIs(t, h.ValidateCritical(), ErrInvalidHeader)
+}
+
+func TestHeaders_CriticalNormalized(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []interface{}
+	}{
+		{"mixed", []interface{}{HeaderKeyID, 3, int64(-70000), uint64(70000), "x-custom"}, []interface{}{int64(4), int64(3), int64(-70000), int64(70000), "x-custom"}},
+		{"strings", []string{"x-custom", HeaderContentType}, []interface{}{"x-custom", int64(3)}},
+		{"ints", []int{3, -70000}, []interface{}{int64(3), int64(-70000)}},
+		{"int64s", []int64{4}, []interface{}{int64(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHeaders()
+			require.NoError(t, h.Set(HeaderCritical, tt.value))
+			crit, err := h.GetCritical()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, crit)
+		})
+	}
+
+	// Labels listed as common header names are encoded as integers and match decoded labels
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.Headers.SetProtected(HeaderCritical, []string{HeaderContentType, "x-unknown"}))
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, "text/plain"))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	prot, err := StdEncoding.marshalProtected(msg.Headers.protected)
+	require.NoError(t, err)
+	var raw map[int64]interface{}
+	require.NoError(t, StdEncoding.UnmarshalCBOR(prot, &raw))
+	assert.Equal(t, []interface{}{int64(3), "x-unknown"}, raw[2])
+
+	_, err = StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	assert.Equal(t, ErrCriticalHeaderMissing{Label: "x-unknown"}, err)
+	require.NoError(t, msg.Headers.SetProtected("x-unknown", 1))
+	b, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+}
+
+func TestHeaders_CloneCopy(t *testing.T) {
+	kid := []byte("kid")
+	chain := []interface{}{[]byte{1, 2}, []interface{}{[]byte{3}}}
+	nested := map[interface{}]interface{}{int64(1): []byte{4}}
+	h, err := NewHeadersFromMaps(
+		map[interface{}]interface{}{int64(1): int64(-7), "nested": nested},
+		map[interface{}]interface{}{int64(4): kid, int64(33): chain},
+	)
+	require.NoError(t, err)
+
+	clone := h.Clone()
+	require.NoError(t, clone.Set("added", "value"))
+	v, err := h.Get("added")
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+	assert.Nil(t, v)
+	// Clone shares values with the original headers
+	v, err = clone.Get(HeaderKeyID)
+	require.NoError(t, err)
+	v.([]byte)[0] = 'K'
+	assert.Equal(t, []byte("Kid"), kid)
+	kid[0] = 'k'
+
+	c := h.Copy()
+	assert.True(t, HeadersEqual(h, c))
+
+	v, err = c.Get(HeaderKeyID)
+	require.NoError(t, err)
+	v.([]byte)[0] = 'K'
+	v, err = c.Get(33)
+	require.NoError(t, err)
+	v.([]interface{})[0].([]byte)[0] = 9
+	v.([]interface{})[1].([]interface{})[0].([]byte)[0] = 9
+	v, err = c.GetProtected("nested")
+	require.NoError(t, err)
+	v.(map[interface{}]interface{})[int64(1)].([]byte)[0] = 9
+
+	assert.Equal(t, []byte("kid"), kid)
+	assert.Equal(t, []interface{}{[]byte{1, 2}, []interface{}{[]byte{3}}}, chain)
+	assert.Equal(t, map[interface{}]interface{}{int64(1): []byte{4}}, nested)
+	assert.False(t, HeadersEqual(h, c))
+}
+
+func TestHeaders_Lookup(t *testing.T) {
+	h, err := NewHeadersFromMaps(
+		map[interface{}]interface{}{int64(1): int64(-7), int64(3): nil},
+		map[interface{}]interface{}{int64(4): nil, "custom": []byte{1}},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		key     interface{}
+		value   interface{}
+		present bool
+	}{
+		{HeaderAlgorithm, "ES256", true},
+		{HeaderContentType, nil, true},
+		{HeaderKeyID, nil, true},
+		{"custom", []byte{1}, true},
+		{HeaderIV, nil, false},
+		{"absent", nil, false},
+	}
+	for _, tt := range tests {
+		v, present, err := h.Lookup(tt.key)
+		require.NoError(t, err)
+		assert.Equal(t, tt.present, present, "%v", tt.key)
+		assert.Equal(t, tt.value, v, "%v", tt.key)
+		assert.Equal(t, tt.present, h.Has(tt.key), "%v", tt.key)
+
+		v, err = h.Get(tt.key)
+		if tt.present {
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, v)
+		} else {
+			assert.ErrorIs(t, err, ErrHeaderNotFound)
+		}
+	}
+
+	_, _, err = h.Lookup(1.5)
+	assert.Error(t, err)
+	assert.False(t, h.Has(1.5))
+}
+
+func TestHeaders_PrivateUseLabel(t *testing.T) {
+	const label = int64(-65537)
+	h := NewHeaders()
+	require.NoError(t, h.SetProtected(label, "protected"))
+	require.NoError(t, h.Set(label-1, []byte{1}))
+
+	v, err := h.GetProtected(label)
+	require.NoError(t, err)
+	assert.Equal(t, "protected", v)
+	v, err = h.Get(label)
+	require.NoError(t, err)
+	assert.Equal(t, "protected", v)
+	v, err = h.Get(label - 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, v)
+
+	data, err := StdEncoding.marshalProtected(h.protected)
+	require.NoError(t, err)
+	decoded, err := ParseProtectedHeaders(StdEncoding, data)
+	require.NoError(t, err)
+	v, err = decoded.GetProtected(label)
+	require.NoError(t, err)
+	assert.Equal(t, "protected", v)
+
+	h.Delete(label)
+	h.Delete(label - 1)
+	assert.False(t, h.Has(label))
+	assert.False(t, h.Has(label-1))
+}
+
+func TestHeaders_ReservedLabel(t *testing.T) {
+	h := NewHeaders()
+	for _, label := range []interface{}{0, int64(0), int64(-4), -7} {
+		assert.ErrorIs(t, h.SetProtected(label, 1), ErrReservedHeaderLabel, "%v", label)
+		assert.ErrorIs(t, h.Set(label, 1), ErrReservedHeaderLabel, "%v", label)
+	}
+	assert.NoError(t, h.Set(HeaderEphemeralKey, 1))
+	assert.NoError(t, h.Set(int64(-8), 1))
+}
+
+func TestHeaders_MustSet(t *testing.T) {
+	h := NewHeaders()
+	h.MustSet(HeaderKeyID, []byte("kid"))
+	assert.Equal(t, []byte("kid"), h.unprotected[getCommonHeader(HeaderKeyID)])
+	assert.Panics(t, func() { h.MustSet(0, true) })
+	assert.Panics(t, func() { h.MustSet(1.5, true) })
+}
+
+func TestHeaders_GetAlgorithm(t *testing.T) {
+	h := NewHeaders()
+	_, err := h.GetAlgorithm()
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+
+	for _, value := range []interface{}{int64(-7), -7, "ES256", AlgorithmES256} {
+		require.NoError(t, h.SetProtected(HeaderAlgorithm, value))
+		alg, err := h.GetAlgorithm()
+		require.NoError(t, err)
+		assert.Equal(t, AlgorithmES256, alg)
+	}
+
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, "custom"))
+	alg, err := h.GetAlgorithm()
+	require.NoError(t, err)
+	assert.Equal(t, Algorithm("custom"), alg)
+
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, int64(-65000)))
+	_, err = h.GetAlgorithm()
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	require.NoError(t, h.SetProtected(HeaderAlgorithm, []byte("alg")))
+	_, err = h.GetAlgorithm()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// headerDecMode decodes header maps and values, the well-formedness and indefinite length
+// items of the message are already checked by the message decoder.
+var headerDecMode, _ = cbor.DecOptions{
+	DupMapKey: cbor.DupMapKeyEnforcedAPF,
+	IntDec:    cbor.IntDecConvertSigned,
+}.DecMode()
+
+// headerMap is a decoded header map, values of common headers are decoded
+// and values of other headers are decoded when accessed.
+type headerMap map[interface{}]interface{}
+
+// UnmarshalCBOR decodes the header map keeping the encoded values of uncommon headers.
+func (m *headerMap) UnmarshalCBOR(data []byte) error {
+	if len(data) == 1 && data[0] == 0xf6 {
+		*m = nil
+		return nil
+	}
+	// Encoded values refer to a single copy of the map instead of the message data
+	var raw map[interface{}]rawHeaderValue
+	if err := headerDecMode.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	h := make(headerMap, len(raw))
+	for k, v := range raw {
+		if !isCommonLabel(k) {
+			h[k] = &lazyHeaderValue{raw: v}
+			continue
+		}
+		var value interface{}
+		if err := headerDecMode.Unmarshal([]byte(v), &value); err != nil {
+			return err
+		}
+		h[k] = value
+	}
+	*m = h
+	return nil
+}
+
+// rawHeaderValue is an encoded header value referring to the decoded data.
+type rawHeaderValue []byte
+
+func (v *rawHeaderValue) UnmarshalCBOR(data []byte) error {
+	*v = data[:len(data):len(data)]
+	return nil
+}
+
+// isCommonLabel reports whether the label is the integer label of a common header.
+func isCommonLabel(label interface{}) bool {
+	switch label {
+	case int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(9), int64(35), int64(258):
+		return true
+	}
+	return false
+}
+
+// lazyHeaderValue is a header value decoded on first access, it is encoded as received.
+type lazyHeaderValue struct {
+	raw   []byte
+	once  sync.Once
+	value interface{}
+}
+
+// MarshalCBOR returns the received encoding of the value.
+func (v *lazyHeaderValue) MarshalCBOR() ([]byte, error) {
+	return v.raw, nil
+}
+
+// decode returns the decoded value, values that can not be decoded
+// such as integers overflowing int64 are returned as cbor.RawMessage.
+func (v *lazyHeaderValue) decode() interface{} {
+	v.once.Do(func() {
+		if err := headerDecMode.Unmarshal(v.raw, &v.value); err != nil {
+			v.value = cbor.RawMessage(v.raw)
+		}
+	})
+	return v.value
+}
+
+// resolveHeaderValue returns the decoded header value.
+func resolveHeaderValue(v interface{}) interface{} {
+	if l, ok := v.(*lazyHeaderValue); ok {
+		return l.decode()
+	}
+	return v
+}
+
+// headerValuesEqual reports whether the header values are equal,
+// values decoded on access are equal if their encoding is equal.
+func headerValuesEqual(a, b interface{}) bool {
+	la, aLazy := a.(*lazyHeaderValue)
+	lb, bLazy := b.(*lazyHeaderValue)
+	if aLazy && bLazy && bytes.Equal(la.raw, lb.raw) {
+		return true
+	}
+	return reflect.DeepEqual(resolveHeaderValue(a), resolveHeaderValue(b))
+}
+
+// headerMapsEqual reports whether the header maps have the same labels and values.
+func headerMapsEqual(a, b map[interface{}]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		other, ok := b[k]
+		if !ok || !headerValuesEqual(v, other) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// x5chainHeader returns the encoded x5chain header value of two certificates of the total size.
+func x5chainHeader(size int) cbor.RawMessage {
+	chain := []interface{}{bytes.Repeat([]byte{1}, size/2), bytes.Repeat([]byte{2}, size/2)}
+	b, err := cbor.Marshal(chain)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestHeaders_LazyValues(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	data := craftSign1(t, signer,
+		map[interface{}]interface{}{int64(1): int64(-7), int64(-70001): "protected"},
+		map[interface{}]interface{}{
+			int64(4):  []byte("kid"),
+			int64(33): x5chainHeader(4096),
+			// Epoch time tag and an integer overflowing int64
+			int64(-70002): cbor.RawMessage(mustHex(t, "c11a5f5e1000")),
+			int64(-70003): cbor.RawMessage(mustHex(t, "1bffffffffffffffff")),
+		})
+	msg, err := StdEncoding.Decode(data, config)
+	require.NoError(t, err)
+	h := msg.(*Sign1Message).Headers
+
+	// Common headers are decoded, other headers are decoded on access
+	assert.Equal(t, []byte("kid"), h.unprotected[int64(4)])
+	assert.IsType(t, &lazyHeaderValue{}, h.unprotected[int64(33)])
+	assert.IsType(t, &lazyHeaderValue{}, h.protected[int64(-70001)])
+
+	v, err := h.Get(HeaderAlgorithm)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", v)
+	v, err = h.GetProtected(int64(-70001))
+	require.NoError(t, err)
+	assert.Equal(t, "protected", v)
+	v, err = h.Get(int64(33))
+	require.NoError(t, err)
+	require.Len(t, v, 2)
+	assert.Len(t, v.([]interface{})[0], 2048)
+	v, err = h.Get(int64(-70003))
+	require.NoError(t, err)
+	assert.Equal(t, cbor.RawMessage(mustHex(t, "1bffffffffffffffff")), v)
+	for _, e := range h.GetAllUnprotected() {
+		_, lazy := e.Value.(*lazyHeaderValue)
+		assert.False(t, lazy, "%v", e.Key)
+	}
+
+	// Values are encoded as received even after access
+	relayed, err := StdEncoding.EncodeRelay(msg.(*Sign1Message))
+	require.NoError(t, err)
+	assert.Equal(t, data, relayed)
+
+	clone := CloneSign1Message(msg.(*Sign1Message))
+	assert.True(t, MessageEqual(msg, clone))
+	v, err = clone.Headers.Get(int64(-70002))
+	require.NoError(t, err)
+	assert.NotNil(t, v)
+}
+
+func TestHeaders_LazyValuesDuplicateLabel(t *testing.T) {
+	var m headerMap
+	err := StdEncoding.unmarshal(stageProtectedHeader, mustHex(t, "a23a0001117041013a000111704102"), &m)
+	var malformed ErrMalformedHeaders
+	assert.ErrorAs(t, headersDecodeError(err), &malformed)
+}
+
+func TestHeaders_LazyValuesCopyPayload(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	data := craftSign1(t, signer, map[interface{}]interface{}{int64(1): int64(-7)},
+		map[interface{}]interface{}{int64(-70001): []byte("value")})
+
+	b := append([]byte{}, data...)
+	msg, err := StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer), CopyPayload: true})
+	require.NoError(t, err)
+	for i := range b {
+		b[i] = 0
+	}
+	v, err := msg.(*Sign1Message).Headers.Get(int64(-70001))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), v)
+}
+
+func TestHeaders_OpaqueByteStringValues(t *testing.T) {
+	coreDeterministic, err := NewEncoding(WithCoreDeterministicEncoding())
+	require.NoError(t, err)
+	relaxed, err := NewEncodingRelaxed()
+	require.NoError(t, err)
+
+	// Byte string values that are themselves well-formed or malformed CBOR items
+	values := map[string]string{
+		"map":               "a2016161026162",
+		"array":             "83010203",
+		"nested array":      "8283010203a10102",
+		"tag":               "c11a5f5e1000",
+		"encoded cbor tag":  "d81843a10101",
+		"indefinite array":  "9f0102ff",
+		"indefinite map":    "bf0102ff",
+		"indefinite bstr":   "5f42010243030405ff",
+		"bstr head":         "5806010203040506",
+		"text":              "6474657374",
+		"null":              "f6",
+		"break":             "ff",
+		"truncated map":     "a301",
+		"empty":             "",
+		"COSE_Sign1 prefix": "d28443a10126",
+	}
+	for name, value := range values {
+		t.Run(name, func(t *testing.T) {
+			value := mustHex(t, value)
+			bstr, err := cbor.Marshal(value)
+			require.NoError(t, err)
+
+			signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+			require.NoError(t, err)
+			msg := NewSign1Message()
+			msg.SetContent([]byte("test"))
+			require.NoError(t, msg.Headers.Set(HeaderKeyID, value))
+			require.NoError(t, msg.Headers.Set(int64(-70001), value))
+			require.NoError(t, msg.Headers.SetProtected(int64(-70002), value))
+			require.NoError(t, msg.Headers.Set("bytes", value))
+			require.NoError(t, msg.SetSigner(signer))
+
+			// Set stores the value untouched
+			v, err := msg.Headers.Get(HeaderKeyID)
+			require.NoError(t, err)
+			assert.Equal(t, value, v)
+
+			for _, e := range []*Encoding{StdEncoding, coreDeterministic, relaxed} {
+				data, err := e.Encode(msg)
+				require.NoError(t, err)
+				assert.True(t, bytes.Contains(data, bstr))
+
+				dec, err := e.Decode(data, &Config{GetVerifiers: staticVerifier(t, signer)})
+				require.NoError(t, err)
+				h := dec.(*Sign1Message).Headers
+				for _, label := range []interface{}{HeaderKeyID, int64(-70001), int64(-70002), "bytes"} {
+					v, err := h.Get(label)
+					require.NoError(t, err)
+					assert.IsType(t, []byte{}, v, "%v", label)
+					assert.Equal(t, value, v, "%v", label)
+				}
+
+				relayed, err := e.EncodeRelay(dec.(*Sign1Message))
+				require.NoError(t, err)
+				assert.Equal(t, data, relayed)
+
+				// EdDSA signatures are deterministic, the re-encoded message is identical
+				require.NoError(t, dec.(*Sign1Message).SetSigner(signer))
+				reencoded, err := e.Encode(dec)
+				require.NoError(t, err)
+				assert.Equal(t, data, reencoded)
+			}
+		})
+	}
+}
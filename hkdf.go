@@ -0,0 +1,69 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"errors"
+	"io"
+)
+
+// hkdfReader implements HKDF (RFC 5869) as a reader of the expanded output key material.
+type hkdfReader struct {
+	hash    crypto.Hash
+	prk     []byte
+	info    []byte
+	counter byte
+	prev    []byte
+	buf     []byte
+}
+
+// newHKDFReader creates a HKDF reader for the given secret, salt and info.
+func newHKDFReader(hash crypto.Hash, secret, salt, info []byte) io.Reader {
+	if salt == nil {
+		salt = make([]byte, hash.Size())
+	}
+	extractor := hmac.New(hash.New, salt)
+	_, _ = extractor.Write(secret)
+
+	return &hkdfReader{
+		hash: hash,
+		prk:  extractor.Sum(nil),
+		info: info,
+	}
+}
+
+// Read reads the next bytes of the output key material.
+func (r *hkdfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			if r.counter == 255 {
+				return n, errors.New("hkdf: output length limit reached")
+			}
+			r.counter++
+			expander := hmac.New(r.hash.New, r.prk)
+			_, _ = expander.Write(r.prev)
+			_, _ = expander.Write(r.info)
+			_, _ = expander.Write([]byte{r.counter})
+			r.prev = expander.Sum(nil)
+			r.buf = r.prev
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// hkdf derives a key of the given length from the secret using HKDF.
+func hkdf(hash crypto.Hash, secret, salt, info []byte, length int) ([]byte, error) {
+	key := make([]byte, length)
+	if _, err := io.ReadFull(newHKDFReader(hash, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_nohttp
+// +build !cose_nohttp
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Sign1MessageAADFromHTTPRequest returns the external data binding a message to the HTTP request.
+//
+// The data is the signature base of the components as defined by HTTP Message Signatures
+// (RFC 9421 section 2.5) without the signature parameters line. Components are the derived
+// components @method, @target-uri, @authority, @scheme, @request-target, @path and @query
+// or lowercase header field names. The external data is passed to EncodeWithExternal
+// and DecodeWithExternal.
+func Sign1MessageAADFromHTTPRequest(r *http.Request, components []string) ([]byte, error) {
+	if r == nil {
+		return nil, errors.New("request can not be nil")
+	}
+	return httpSignatureBase(components, r.Header, func(name string) (string, error) {
+		return httpRequestComponent(r, name)
+	})
+}
+
+// AADFromHTTPResponse returns the external data binding a message to the HTTP response,
+// the @status derived component and lowercase header field names are supported as components.
+func AADFromHTTPResponse(resp *http.Response, components []string) ([]byte, error) {
+	if resp == nil {
+		return nil, errors.New("response can not be nil")
+	}
+	return httpSignatureBase(components, resp.Header, func(name string) (string, error) {
+		if name != "@status" {
+			return "", fmt.Errorf("unsupported response component %q", name)
+		}
+		return strconv.Itoa(resp.StatusCode), nil
+	})
+}
+
+// httpSignatureBase returns the component lines of the signature base, derived components
+// start with @ and are returned by the derived function.
+func httpSignatureBase(components []string, header http.Header, derived func(string) (string, error)) ([]byte, error) {
+	if len(components) == 0 {
+		return nil, errors.New("no HTTP components")
+	}
+	seen := make(map[string]bool, len(components))
+	var b strings.Builder
+	for _, name := range components {
+		if name == "" || name != strings.ToLower(name) {
+			return nil, fmt.Errorf("invalid HTTP component %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate HTTP component %q", name)
+		}
+		seen[name] = true
+
+		var value string
+		var err error
+		if strings.HasPrefix(name, "@") {
+			value, err = derived(name)
+		} else {
+			value, err = httpFieldValue(header, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(`"` + name + `": ` + value)
+	}
+	return []byte(b.String()), nil
+}
+
+// httpFieldValue returns the header field values trimmed and combined with a comma.
+func httpFieldValue(header http.Header, name string) (string, error) {
+	values, ok := header[http.CanonicalHeaderKey(name)]
+	if !ok {
+		return "", fmt.Errorf("missing HTTP header %q", name)
+	}
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.TrimSpace(v)
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+// httpRequestComponent returns the value of the derived request component.
+func httpRequestComponent(r *http.Request, name string) (string, error) {
+	scheme := strings.ToLower(r.URL.Scheme)
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	authority := r.Host
+	if authority == "" {
+		authority = r.URL.Host
+	}
+	authority = strings.ToLower(authority)
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	switch name {
+	case "@method":
+		return strings.ToUpper(r.Method), nil
+	case "@target-uri":
+		return scheme + "://" + authority + r.URL.RequestURI(), nil
+	case "@authority":
+		return authority, nil
+	case "@scheme":
+		return scheme, nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		return path, nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	default:
+		return "", fmt.Errorf("unsupported request component %q", name)
+	}
+}
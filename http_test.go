@@ -0,0 +1,93 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_nohttp
+// +build !cose_nohttp
+
+package cose
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var httpComponents = []string{"@method", "@target-uri", "@authority", "@path", "@query", "content-type"}
+
+func newHTTPRequest(method, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Content-Type", "application/cose")
+	return r
+}
+
+func TestSign1MessageAADFromHTTPRequest(t *testing.T) {
+	r := newHTTPRequest(http.MethodPost, "https://Example.com/foo?param=Value&Pet=dog")
+	r.Header.Add("X-List", " a ")
+	r.Header.Add("X-List", "b")
+	aad, err := Sign1MessageAADFromHTTPRequest(r, append(httpComponents, "@scheme", "@request-target", "x-list"))
+	require.NoError(t, err)
+	assert.Equal(t, `"@method": POST
+"@target-uri": https://example.com/foo?param=Value&Pet=dog
+"@authority": example.com
+"@path": /foo
+"@query": ?param=Value&Pet=dog
+"content-type": application/cose
+"@scheme": https
+"@request-target": /foo?param=Value&Pet=dog
+"x-list": a, b`, string(aad))
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	aad, err = Sign1MessageAADFromHTTPRequest(newHTTPRequest(http.MethodPost, "https://example.com/foo"), httpComponents)
+	require.NoError(t, err)
+	data, err := StdEncoding.EncodeWithExternal(msg, aad)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		valid  bool
+	}{
+		{"same request", http.MethodPost, "https://example.com/foo", true},
+		{"different method", http.MethodPut, "https://example.com/foo", false},
+		{"different path", http.MethodPost, "https://example.com/bar", false},
+		{"different query", http.MethodPost, "https://example.com/foo?a=b", false},
+		{"different host", http.MethodPost, "https://example.org/foo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aad, err := Sign1MessageAADFromHTTPRequest(newHTTPRequest(tt.method, tt.target), httpComponents)
+			require.NoError(t, err)
+			_, err = StdEncoding.DecodeWithExternal(data, aad, config)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrVerification)
+			}
+		})
+	}
+
+	for _, components := range [][]string{nil, {"@unknown"}, {"x-missing"}, {"@method", "@method"}, {"Content-Type"}} {
+		_, err = Sign1MessageAADFromHTTPRequest(r, components)
+		assert.Error(t, err, "%v", components)
+	}
+}
+
+func TestAADFromHTTPResponse(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/cose"}}}
+	aad, err := AADFromHTTPResponse(resp, []string{"@status", "content-type"})
+	require.NoError(t, err)
+	assert.Equal(t, "\"@status\": 200\n\"content-type\": application/cose", string(aad))
+
+	_, err = AADFromHTTPResponse(resp, []string{"@method"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build veraison
+// +build veraison
+
+// Differential tests against github.com/veraison/go-cose. The other library is
+// not a dependency of the module, add it before running the tests and do not
+// commit the resulting go.mod changes:
+//
+//	go get github.com/veraison/go-cose
+//	go test -tags veraison -run TestInterop .
+
+package cose_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	veraison "github.com/veraison/go-cose"
+	"github.com/zzdats/go-cose"
+)
+
+// interopAlgorithm maps an algorithm to the other library and generates a key for it.
+type interopAlgorithm struct {
+	alg          cose.Algorithm
+	other        veraison.Algorithm
+	newKey       func() (crypto.Signer, error)
+	reproducible bool
+}
+
+var interopAlgorithms = []interopAlgorithm{
+	{alg: cose.AlgorithmPS256, other: veraison.AlgorithmPS256, newKey: func() (crypto.Signer, error) {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}},
+	{alg: cose.AlgorithmES256, other: veraison.AlgorithmES256, newKey: func() (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}},
+	{alg: cose.AlgorithmES384, other: veraison.AlgorithmES384, newKey: func() (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}},
+	{alg: cose.AlgorithmES512, other: veraison.AlgorithmES512, newKey: func() (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	}},
+	{alg: cose.AlgorithmEdDSA, other: veraison.AlgorithmEdDSA, reproducible: true, newKey: func() (crypto.Signer, error) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}},
+}
+
+// interopHeaders is a header combination set on both sides, labels are the integer labels of the headers.
+type interopHeaders struct {
+	name        string
+	protected   map[interface{}]interface{}
+	unprotected map[interface{}]interface{}
+}
+
+var interopHeaderSets = []interopHeaders{
+	{name: "alg"},
+	{name: "kid", unprotected: map[interface{}]interface{}{int64(4): []byte("key-1")}},
+	{name: "protected kid", protected: map[interface{}]interface{}{int64(4): []byte("key-1")}},
+	{name: "content type", protected: map[interface{}]interface{}{int64(3): int64(60)}},
+	{name: "content type text", protected: map[interface{}]interface{}{int64(3): "application/cbor"}},
+	{
+		name:        "mixed",
+		protected:   map[interface{}]interface{}{int64(3): int64(60)},
+		unprotected: map[interface{}]interface{}{int64(4): []byte("key-1")},
+	},
+}
+
+var interopPayloadSizes = []int{0, 1, 1024, 64 << 10}
+
+func TestInterop(t *testing.T) {
+	for _, a := range interopAlgorithms {
+		key, err := a.newKey()
+		require.NoError(t, err)
+		for _, hs := range interopHeaderSets {
+			for _, size := range interopPayloadSizes {
+				name := fmt.Sprintf("%s/%s/%d", a.alg, hs.name, size)
+				t.Run(name, func(t *testing.T) {
+					payload := bytes.Repeat([]byte{0xa5}, size)
+					runInterop(t, a, key, hs, payload)
+				})
+			}
+		}
+	}
+}
+
+func runInterop(t *testing.T, a interopAlgorithm, key crypto.Signer, hs interopHeaders, payload []byte) {
+	ours, err := encodeOurs(a, key, hs, payload)
+	require.NoError(t, err)
+	theirs, err := encodeTheirs(a, key, hs, payload)
+	require.NoError(t, err)
+
+	if err := verifyTheirs(a, key, ours); err != nil {
+		t.Errorf("message encoded by this package rejected by veraison: %v\n%x", err, ours)
+	}
+	if err := verifyOurs(a, key, theirs); err != nil {
+		t.Errorf("message encoded by veraison rejected by this package: %v\n%x", err, theirs)
+	}
+	if a.reproducible && !bytes.Equal(ours, theirs) {
+		t.Errorf("encodings differ at byte %d\nours:   %x\ntheirs: %x", firstDifference(ours, theirs), ours, theirs)
+	}
+}
+
+// firstDifference returns the offset of the first differing byte.
+func firstDifference(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) < len(b) {
+		return len(a)
+	}
+	return len(b)
+}
+
+func encodeOurs(a interopAlgorithm, key crypto.Signer, hs interopHeaders, payload []byte) ([]byte, error) {
+	signer, err := cose.NewSigner(a.alg, key)
+	if err != nil {
+		return nil, err
+	}
+	msg := cose.NewSign1Message()
+	for k, v := range hs.protected {
+		if err = msg.Headers.SetProtected(k, v); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range hs.unprotected {
+		if err = msg.Headers.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+	msg.SetContent(payload)
+	if err = msg.SetSigner(signer); err != nil {
+		return nil, err
+	}
+	return cose.StdEncoding.Encode(msg)
+}
+
+func verifyOurs(a interopAlgorithm, key crypto.Signer, data []byte) error {
+	verifier, err := cose.NewVerifier(a.alg, key.Public())
+	if err != nil {
+		return err
+	}
+	_, err = cose.StdEncoding.Decode(data, &cose.Config{
+		GetVerifiers: func(*cose.Headers) ([]*cose.Verifier, error) {
+			return []*cose.Verifier{verifier}, nil
+		},
+	})
+	return err
+}
+
+func encodeTheirs(a interopAlgorithm, key crypto.Signer, hs interopHeaders, payload []byte) ([]byte, error) {
+	signer, err := veraison.NewSigner(a.other, key)
+	if err != nil {
+		return nil, err
+	}
+	msg := veraison.NewSign1Message()
+	msg.Headers.Protected[veraison.HeaderLabelAlgorithm] = a.other
+	for k, v := range hs.protected {
+		msg.Headers.Protected[k] = v
+	}
+	for k, v := range hs.unprotected {
+		msg.Headers.Unprotected[k] = v
+	}
+	msg.Payload = payload
+	if err = msg.Sign(rand.Reader, nil, signer); err != nil {
+		return nil, err
+	}
+	return msg.MarshalCBOR()
+}
+
+func verifyTheirs(a interopAlgorithm, key crypto.Signer, data []byte) error {
+	verifier, err := veraison.NewVerifier(a.other, key.Public())
+	if err != nil {
+		return err
+	}
+	var msg veraison.Sign1Message
+	if err = msg.UnmarshalCBOR(data); err != nil {
+		return err
+	}
+	return msg.Verify(nil, verifier)
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORToJSON transcodes CBOR data to JSON.
+//
+// Map keys that are not text strings are converted to strings, byte strings are
+// encoded as base64 strings and tagged values are replaced by their content.
+func CBORToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := StdEncoding.unmarshal(stageContent, data, &v); err != nil {
+		return nil, err
+	}
+	j, err := toJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// JSONToCBOR transcodes JSON data to CBOR.
+//
+// Integral numbers are encoded as CBOR integers, other numbers as floating point values.
+func JSONToCBOR(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	c, err := fromJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return StdEncoding.marshal(c)
+}
+
+func toJSONValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			if _, ok := m[key]; ok {
+				return nil, fmt.Errorf("duplicate JSON key %q", key)
+			}
+			jv, err := toJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = jv
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, v := range x {
+			jv, err := toJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = jv
+		}
+		return a, nil
+	case cbor.Tag:
+		return toJSONValue(x.Content)
+	case big.Int:
+		return json.Number(x.String()), nil
+	case float32:
+		return toJSONValue(float64(x))
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, nil
+		}
+		return x, nil
+	default:
+		return x, nil
+	}
+}
+
+func fromJSONValue(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(x))
+		for k, v := range x {
+			cv, err := fromJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = cv
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, v := range x {
+			cv, err := fromJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = cv
+		}
+		return a, nil
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i, nil
+		}
+		if i, ok := new(big.Int).SetString(x.String(), 10); ok {
+			return i, nil
+		}
+		return x.Float64()
+	default:
+		return x, nil
+	}
+}
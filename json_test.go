@@ -0,0 +1,68 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCBORToJSON_DGC(t *testing.T) {
+	b, err := hex.DecodeString(dgcTestMessage)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, dgcTestConfig(t))
+	require.NoError(t, err)
+
+	data, err := CBORToJSON(dec.GetContent())
+	require.NoError(t, err)
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &claims))
+	assert.Equal(t, "LV", claims["1"])
+	assert.Contains(t, claims, "4")
+	assert.Contains(t, claims, "6")
+
+	hcert, ok := claims["-260"].(map[string]interface{})
+	require.True(t, ok)
+	dgc, ok := hcert["1"].(map[string]interface{})
+	require.True(t, ok)
+	for _, field := range []string{"ver", "nam", "dob", "t"} {
+		assert.Contains(t, dgc, field)
+	}
+	assert.Equal(t, "1993-09-13", dgc["dob"])
+	nam, ok := dgc["nam"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "MARTINS", nam["gnt"])
+}
+
+func TestCBORToJSON_Types(t *testing.T) {
+	// {1: h'0102', "a": [true, null, 1.5], -1: 1234(0)}
+	data, err := CBORToJSON(mustHex(t, "a301420102616183f5f6f93e0020d904d200"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"1":"AQI=","a":[true,null,1.5],"-1":0}`, string(data))
+
+	_, err = CBORToJSON([]byte{0xa1})
+	assert.Error(t, err)
+}
+
+func TestJSONToCBOR(t *testing.T) {
+	data, err := JSONToCBOR([]byte(`{"a":[1,-2,1.5,"text",true,null],"b":{"c":18446744073709551616}}`))
+	require.NoError(t, err)
+
+	j, err := CBORToJSON(data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"a":[1,-2,1.5,"text",true,null],"b":{"c":18446744073709551616}}`, string(j))
+
+	data, err = JSONToCBOR([]byte(`[1]`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x81, 0x01}, data)
+
+	_, err = JSONToCBOR([]byte(`{`))
+	assert.Error(t, err)
+}
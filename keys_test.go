@@ -7,6 +7,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -209,7 +210,13 @@ MC4CAQAwBQYDK2VwBCIEIJU9qw1WgePBMaIB9RXQbzFsxje8zAbW6ztWndsc+qTc
 	},
 }
 
-func getPrivateKey(t *testing.T, name string) crypto.PrivateKey {
+func getPrivateKey(t testing.TB, name string) crypto.PrivateKey {
+	requireRSAKey(t, name)
+	return parsePrivateKey(t, name)
+}
+
+// parsePrivateKey parses the test private key even if its algorithm is not supported.
+func parsePrivateKey(t testing.TB, name string) crypto.PrivateKey {
 	key := testKeys[name]
 	require.NotNil(t, key)
 
@@ -236,7 +243,17 @@ func getPrivateKey(t *testing.T, name string) crypto.PrivateKey {
 	return nil
 }
 
-func getPublicKey(t *testing.T, name string) crypto.PublicKey {
+func getPublicKey(t testing.TB, name string) crypto.PublicKey {
+	return getCertificate(t, name).PublicKey
+}
+
+func getCertificate(t testing.TB, name string) *x509.Certificate {
+	requireRSAKey(t, name)
+	return parseCertificate(t, name)
+}
+
+// parseCertificate parses the test certificate even if its key algorithm is not supported.
+func parseCertificate(t testing.TB, name string) *x509.Certificate {
 	key := testKeys[name]
 	require.NotNil(t, key)
 
@@ -248,5 +265,21 @@ func getPublicKey(t *testing.T, name string) crypto.PublicKey {
 	require.NoError(t, err)
 	require.NotNil(t, cert)
 
-	return cert.PublicKey
+	return cert
+}
+
+// requireRSA skips the test if RSA support is left out with the cose_norsa build tag.
+func requireRSA(t testing.TB) {
+	t.Helper()
+	if !rsaSupported {
+		t.Skip("RSA support is left out with the cose_norsa build tag")
+	}
+}
+
+// requireRSAKey skips the test using the RSA test key if RSA support is left out.
+func requireRSAKey(t testing.TB, name string) {
+	t.Helper()
+	if strings.HasPrefix(name, "rsa") {
+		requireRSA(t)
+	}
 }
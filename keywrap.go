@@ -0,0 +1,116 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// keyWrapIV is the default initial value from RFC 3394 section 2.2.3.1
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// wrapKey wraps the content encryption key with the key encryption key using AES Key Wrap (RFC 3394).
+func wrapKey(wrappingKey, cek []byte) ([]byte, error) {
+	if len(cek) < 16 || len(cek)%8 != 0 {
+		return nil, errors.New("key to wrap must be a multiple of 8 bytes and at least 16 bytes long")
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(cek) / 8
+	a := make([]byte, 8)
+	copy(a, keyWrapIV)
+	r := make([]byte, len(cek))
+	copy(r, cek)
+
+	buf := make([]byte, aes.BlockSize)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf[:8], a)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	return append(a, r...), nil
+}
+
+// unwrapKey unwraps the content encryption key with the key encryption key using AES Key Wrap (RFC 3394).
+func unwrapKey(wrappingKey, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("wrapped key must be a multiple of 8 bytes and at least 24 bytes long")
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, len(wrapped)-8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, aes.BlockSize)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a, keyWrapIV) != 1 {
+		return nil, ErrDecryption
+	}
+	return r, nil
+}
+
+// KeyUnwrapper recovers the content encryption key of AES Key Wrap and direct recipients.
+type KeyUnwrapper struct {
+	alg *algorithm
+	key []byte
+}
+
+// NewKeyUnwrapper creates a new KeyUnwrapper instance for the AES Key Wrap or direct
+// algorithm with the given shared key.
+func NewKeyUnwrapper(alg Algorithm, key []byte) (*KeyUnwrapper, error) {
+	a := getAlg(string(alg))
+	if a == nil || (a.Type != algorithmTypeKeyWrap && a.Type != algorithmTypeDirect) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if len(key) == 0 || (a.Type == algorithmTypeKeyWrap && len(key)*8 != a.KeySize) {
+		return nil, ErrInvalidKeySize
+	}
+	return &KeyUnwrapper{
+		alg: a,
+		key: key,
+	}, nil
+}
+
+// Unwrap returns the content encryption key protected by the recipient ciphertext,
+// direct recipients have empty ciphertext and use the shared key as is.
+func (u *KeyUnwrapper) Unwrap(ciphertext []byte) ([]byte, error) {
+	if u.alg.Type == algorithmTypeDirect {
+		if len(ciphertext) != 0 {
+			return nil, ErrDecryption
+		}
+		return u.key, nil
+	}
+	return unwrapKey(u.key, ciphertext)
+}
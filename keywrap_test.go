@@ -0,0 +1,95 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t testing.TB, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestKeyWrap_RFC3394(t *testing.T) {
+	tests := []struct {
+		name    string
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{
+			name:    "128-bit KEK and key",
+			kek:     "000102030405060708090a0b0c0d0e0f",
+			key:     "00112233445566778899aabbccddeeff",
+			wrapped: "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+		},
+		{
+			name:    "256-bit KEK and key",
+			kek:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			key:     "00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+			wrapped: "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped, err := wrapKey(mustHex(t, tt.kek), mustHex(t, tt.key))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wrapped, hex.EncodeToString(wrapped))
+
+			key, err := unwrapKey(mustHex(t, tt.kek), wrapped)
+			require.NoError(t, err)
+			assert.Equal(t, tt.key, hex.EncodeToString(key))
+		})
+	}
+}
+
+func TestKeyWrap_A256KW(t *testing.T) {
+	kek := make([]byte, 32)
+	_, err := rand.Read(kek)
+	require.NoError(t, err)
+	cek := make([]byte, 32)
+	_, err = rand.Read(cek)
+	require.NoError(t, err)
+
+	wrapped, err := wrapKey(kek, cek)
+	require.NoError(t, err)
+
+	key, err := unwrapKey(kek, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, cek, key)
+}
+
+func TestKeyWrap_WrongKey(t *testing.T) {
+	kek := make([]byte, 32)
+	_, err := rand.Read(kek)
+	require.NoError(t, err)
+	cek := make([]byte, 32)
+	_, err = rand.Read(cek)
+	require.NoError(t, err)
+
+	wrapped, err := wrapKey(kek, cek)
+	require.NoError(t, err)
+
+	kek[0] ^= 0xff
+	key, err := unwrapKey(kek, wrapped)
+	assert.ErrorIs(t, err, ErrDecryption)
+	assert.Nil(t, key)
+}
+
+func TestNewKeyUnwrapper_Invalid(t *testing.T) {
+	_, err := NewKeyUnwrapper(AlgorithmA256KW, randomKey(t, 16))
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+	_, err = NewKeyUnwrapper(AlgorithmDirect, nil)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+	_, err = NewKeyUnwrapper(AlgorithmA256GCM, randomKey(t, 32))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"errors"
+)
+
+// MACer computes and verifies message authentication codes with a symmetric key.
+type MACer struct {
+	alg *algorithm
+	key []byte
+}
+
+// NewMACer creates a new MACer from a symmetric key and MAC algorithm,
+// the key must be at least as long as the algorithm hash output.
+func NewMACer(alg Algorithm, key []byte) (*MACer, error) {
+	a := getAlg(string(alg))
+	if a == nil || !a.Implemented {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if a.Type != algorithmTypeMAC {
+		return nil, errors.New("algorithm is not a MAC algorithm")
+	}
+	if len(key)*8 < a.KeySize {
+		return nil, ErrInvalidKeySize
+	}
+	return &MACer{
+		alg: a,
+		key: key,
+	}, nil
+}
+
+// Tag returns the authentication tag of the data truncated to the algorithm tag size.
+func (m *MACer) Tag(data []byte) []byte {
+	h := hmac.New(m.alg.Hash.New, m.key)
+	_, _ = h.Write(data)
+	return h.Sum(nil)[:m.alg.TagSize]
+}
+
+// Verify verifies the authentication tag of the data in constant time.
+func (m *MACer) Verify(data, tag []byte) error {
+	if !hmac.Equal(m.Tag(data), tag) {
+		return ErrVerification
+	}
+	return nil
+}
+
+// DeriveDeviceKey derives a per-device MAC key of the given size in bytes from the master key
+// and the device key identifier using HKDF-SHA256, the key identifier is used as the HKDF info.
+func DeriveDeviceKey(masterKey, kid []byte, size int) ([]byte, error) {
+	if len(masterKey) == 0 {
+		return nil, ErrInvalidKeySize
+	}
+	if len(kid) == 0 {
+		return nil, errors.New("key identifier can not be empty")
+	}
+	return hkdf(crypto.SHA256, masterKey, nil, kid, size)
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import "errors"
+
+// Mac0Message represents a COSE_Mac0 message, the MAC key is known to the recipient
+// and is not included in the message.
+type Mac0Message struct {
+	Headers *Headers
+	macer   *MACer
+	content []byte
+}
+
+// NewMac0Message creates a new Mac0Message instance.
+func NewMac0Message() *Mac0Message {
+	return &Mac0Message{
+		Headers: NewHeaders(),
+	}
+}
+
+// GetMessageTag returns the COSE_Mac0 message tag.
+func (m *Mac0Message) GetMessageTag() uint64 {
+	return MessageTagMAC0
+}
+
+// GetContent returns the message content.
+func (m *Mac0Message) GetContent() []byte {
+	return m.content
+}
+
+// SetContent sets the message content.
+func (m *Mac0Message) SetContent(content []byte) {
+	m.content = content
+}
+
+// Validate checks that the critical headers of the message are present.
+func (m *Mac0Message) Validate() error {
+	return validateMessage(m.Headers)
+}
+
+// SetMACer sets the MACer used for computing the authentication tag.
+func (m *Mac0Message) SetMACer(macer *MACer) error {
+	if macer == nil {
+		return errors.New("macer can not be nil")
+	}
+	m.macer = macer
+	return nil
+}
+
+// SetClaims sets the message content to the CWT claims.
+func (m *Mac0Message) SetClaims(claims Claims) error {
+	b, err := StdEncoding.marshal(claims)
+	if err != nil {
+		return err
+	}
+	m.content = b
+	return nil
+}
+
+// GetClaims returns the CWT claims of the message content.
+func (m *Mac0Message) GetClaims() (Claims, error) {
+	var claims Claims
+	if err := StdEncoding.unmarshal(stageClaims, m.content, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *Mac0Message) tag(e *Encoding, external []byte) (interface{}, error) {
+	if m.macer == nil {
+		return nil, errors.New("no MACer")
+	}
+	h, err := e.mergeHeaders(m.Headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.SetProtected(HeaderAlgorithm, m.macer.alg.Value); err != nil {
+		return nil, err
+	}
+	ph, err := e.marshalProtected(h.protected)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := mac0Message{
+		Protected:   ph,
+		Unprotected: h.unprotected,
+		Payload:     m.GetContent(),
+	}
+	data, err := msg.GetDigest(e, external)
+	if err != nil {
+		return nil, err
+	}
+	msg.Tag = m.macer.Tag(data)
+	return msg, nil
+}
+
+type mac0Message struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected headerMap
+	Payload     payload
+	Tag         []byte
+}
+
+// GetDigest returns the MAC_structure authenticated by the tag.
+func (m *mac0Message) GetDigest(e *Encoding, external []byte) ([]byte, error) {
+	s := SigStructure{
+		Context:       ContextMAC0,
+		BodyProtected: m.Protected,
+		ExternalAAD:   external,
+		Payload:       m.Payload,
+	}
+	return s.Marshal(e)
+}
+
+// verify verifies the tag with the MACers matching the message algorithm.
+func (m *mac0Message) verify(e *Encoding, msg *Mac0Message, external []byte, config *Config) error {
+	if config == nil || config.GetTagVerifiers == nil {
+		return ErrVerification
+	}
+	alg, err := config.algorithmHeader(msg.Headers)
+	if err != nil {
+		return err
+	}
+	name, _ := alg.(string)
+	a := getAlg(name)
+	if a == nil || a.Type != algorithmTypeMAC {
+		return ErrUnsupportedAlgorithm
+	}
+	macers, err := config.GetTagVerifiers(msg.Headers)
+	if err != nil {
+		return err
+	}
+	data, err := m.GetDigest(e, external)
+	if err != nil {
+		return err
+	}
+	for _, macer := range macers {
+		if macer == nil || macer.alg != a {
+			continue
+		}
+		if macer.Verify(data, m.Tag) == nil {
+			return nil
+		}
+	}
+	return ErrVerification
+}
+
+func newMac0Message(e *Encoding, c *mac0Message) (*Mac0Message, error) {
+	h, err := newHeaders(e, c.Protected, c.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+	return &Mac0Message{
+		Headers: h,
+		content: c.Payload,
+	}, nil
+}
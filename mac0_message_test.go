@@ -0,0 +1,143 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func macersByKeyID(alg Algorithm, keys map[string][]byte) func(*Headers) ([]*MACer, error) {
+	return func(headers *Headers) ([]*MACer, error) {
+		kid, err := headers.Get(HeaderKeyID)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := kid.([]byte)
+		key, ok := keys[string(b)]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", b)
+		}
+		macer, err := NewMACer(alg, key)
+		if err != nil {
+			return nil, err
+		}
+		return []*MACer{macer}, nil
+	}
+}
+
+// TestMac0Message_RFC8392Vector checks the MACed CWT example from RFC 8392 Appendix A.4.
+func TestMac0Message_RFC8392Vector(t *testing.T) {
+	// 256-bit symmetric key from RFC 8392 Appendix A.2.1
+	rfc8392Key := mustHex(t, "403697de87af64611c1d32a05dab0fe1fcb715a86ab435f1ec99192d79569388")
+	data, err := os.ReadFile(filepath.Join("testdata", "vectors", "rfc8392-a4-mac0-cwt.hex"))
+	require.NoError(t, err)
+	expected, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+
+	config := &Config{
+		GetTagVerifiers: macersByKeyID(AlgorithmHMAC256_64, map[string][]byte{"Symmetric256": rfc8392Key}),
+	}
+	dec, err := StdEncoding.Decode(expected, config)
+	require.NoError(t, err)
+	require.IsType(t, &Mac0Message{}, dec)
+	claims, err := dec.(*Mac0Message).GetClaims()
+	require.NoError(t, err)
+	assert.Equal(t, "coap://as.example.com", claims[int64(ClaimIssuer)])
+	assert.Equal(t, "erikw", claims[int64(ClaimSubject)])
+	assert.Equal(t, "coap://light.example.com", claims[int64(ClaimAudience)])
+	assert.EqualValues(t, 1444064944, claims[int64(ClaimExpiration)])
+	assert.EqualValues(t, 1443944944, claims[int64(ClaimNotBefore)])
+	assert.EqualValues(t, 1443944944, claims[int64(ClaimIssuedAt)])
+	assert.Equal(t, []byte{0x0b, 0x71}, claims[int64(ClaimCWTID)])
+
+	msg := NewMac0Message()
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("Symmetric256")))
+	require.NoError(t, msg.SetClaims(Claims{
+		ClaimIssuer:     "coap://as.example.com",
+		ClaimSubject:    "erikw",
+		ClaimAudience:   "coap://light.example.com",
+		ClaimExpiration: 1444064944,
+		ClaimNotBefore:  1443944944,
+		ClaimIssuedAt:   1443944944,
+		ClaimCWTID:      []byte{0x0b, 0x71},
+	}))
+	macer, err := NewMACer(AlgorithmHMAC256_64, rfc8392Key)
+	require.NoError(t, err)
+	require.NoError(t, msg.SetMACer(macer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	// The example is wrapped in the CWT tag
+	assert.Equal(t, hex.EncodeToString(expected[2:]), hex.EncodeToString(b))
+
+	tampered := append([]byte{}, expected...)
+	tampered[len(tampered)-1] ^= 0x01
+	_, err = StdEncoding.Decode(tampered, config)
+	assert.ErrorIs(t, err, ErrVerification)
+	_, err = StdEncoding.Decode(expected, nil)
+	assert.ErrorIs(t, err, ErrVerification)
+	_, err = StdEncoding.Decode(expected, &Config{
+		GetTagVerifiers: macersByKeyID(AlgorithmHMAC256, map[string][]byte{"Symmetric256": rfc8392Key}),
+	})
+	assert.ErrorIs(t, err, ErrVerification)
+}
+
+func TestMac0Message_DeviceKeys(t *testing.T) {
+	master := randomKey(t, 32)
+	deviceKey, err := DeriveDeviceKey(master, []byte("device-1"), 32)
+	require.NoError(t, err)
+	macer, err := NewMACer(AlgorithmHMAC256, deviceKey)
+	require.NoError(t, err)
+
+	msg := NewMac0Message()
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("device-1")))
+	require.NoError(t, msg.SetClaims(Claims{ClaimSubject: "device-1"}))
+	require.NoError(t, msg.SetMACer(macer))
+	external := []byte("external")
+	b, err := StdEncoding.EncodeWithExternal(msg, external)
+	require.NoError(t, err)
+
+	// The verifier derives the device key from the kid
+	config := &Config{
+		GetTagVerifiers: func(headers *Headers) ([]*MACer, error) {
+			kid, err := headers.Get(HeaderKeyID)
+			if err != nil {
+				return nil, err
+			}
+			key, err := DeriveDeviceKey(master, kid.([]byte), 32)
+			if err != nil {
+				return nil, err
+			}
+			macer, err := NewMACer(AlgorithmHMAC256, key)
+			if err != nil {
+				return nil, err
+			}
+			return []*MACer{macer}, nil
+		},
+	}
+	dec, err := StdEncoding.DecodeWithExternal(b, external, config)
+	require.NoError(t, err)
+	claims, err := dec.(*Mac0Message).GetClaims()
+	require.NoError(t, err)
+	assert.Equal(t, "device-1", claims[int64(ClaimSubject)])
+
+	_, err = StdEncoding.DecodeWithExternal(b, []byte("other"), config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	forged := bytes.Replace(b, []byte("device-1"), []byte("device-2"), -1)
+	_, err = StdEncoding.DecodeWithExternal(forged, external, config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	_, err = StdEncoding.Encode(NewMac0Message())
+	assert.Error(t, err)
+}
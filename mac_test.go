@@ -0,0 +1,63 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMACer(t *testing.T) {
+	tests := []struct {
+		alg     Algorithm
+		keySize int
+		tagSize int
+	}{
+		{AlgorithmHMAC256_64, 32, 8},
+		{AlgorithmHMAC256, 32, 32},
+		{AlgorithmHMAC384, 48, 48},
+		{AlgorithmHMAC512, 64, 64},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			_, err := NewMACer(tt.alg, randomKey(t, tt.keySize-1))
+			assert.ErrorIs(t, err, ErrInvalidKeySize)
+
+			macer, err := NewMACer(tt.alg, randomKey(t, tt.keySize))
+			require.NoError(t, err)
+			tag := macer.Tag([]byte("test"))
+			assert.Len(t, tag, tt.tagSize)
+			assert.NoError(t, macer.Verify([]byte("test"), tag))
+			assert.ErrorIs(t, macer.Verify([]byte("other"), tag), ErrVerification)
+			assert.ErrorIs(t, macer.Verify([]byte("test"), tag[1:]), ErrVerification)
+		})
+	}
+
+	_, err := NewMACer(AlgorithmA256GCM, randomKey(t, 32))
+	assert.Error(t, err)
+	_, err = NewMACer(Algorithm("AES-MAC 256/64"), randomKey(t, 32))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestDeriveDeviceKey(t *testing.T) {
+	master := randomKey(t, 32)
+
+	k1, err := DeriveDeviceKey(master, []byte("device-1"), 32)
+	require.NoError(t, err)
+	assert.Len(t, k1, 32)
+	again, err := DeriveDeviceKey(master, []byte("device-1"), 32)
+	require.NoError(t, err)
+	assert.Equal(t, k1, again)
+	k2, err := DeriveDeviceKey(master, []byte("device-2"), 32)
+	require.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+
+	_, err = DeriveDeviceKey(nil, []byte("device-1"), 32)
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+	_, err = DeriveDeviceKey(master, nil, 32)
+	assert.Error(t, err)
+}
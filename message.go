@@ -4,6 +4,15 @@
 
 package cose
 
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
 // Message represents a COSE message.
 type Message interface {
 	// GetMessageTag returns the COSE message tag.
@@ -13,3 +22,201 @@ type Message interface {
 	// SetContent sets the message content.
 	SetContent([]byte)
 }
+
+// MessageValidator is implemented by the messages of this package that check their internal consistency.
+type MessageValidator interface {
+	// Validate checks the internal consistency of the message.
+	Validate() error
+}
+
+// COSE message types used in the `cose-type` media type parameter
+var messageTypeNames = map[string]uint64{
+	"cose-encrypt0": MessageTagEncrypt0,
+	"cose-mac0":     MessageTagMAC0,
+	"cose-sign1":    MessageTagSign1,
+	"cose-encrypt":  MessageTagEncrypt,
+	"cose-mac":      MessageTagMAC,
+	"cose-sign":     MessageTagSign,
+}
+
+// COSE message structure names from RFC 8152 by message tag
+var messageTagNames = map[uint64]string{
+	MessageTagEncrypt0: "COSE_Encrypt0",
+	MessageTagMAC0:     "COSE_Mac0",
+	MessageTagSign1:    "COSE_Sign1",
+	MessageTagEncrypt:  "COSE_Encrypt",
+	MessageTagMAC:      "COSE_Mac",
+	MessageTagSign:     "COSE_Sign",
+}
+
+// untaggedMessageCandidates returns the names of COSE messages matching the shape of the untagged array.
+func untaggedMessageCandidates(e *Encoding, data []byte) []string {
+	var items []cbor.RawMessage
+	if err := e.unmarshal(stageMessageBody, data, &items); err != nil {
+		return nil
+	}
+	switch len(items) {
+	case 3:
+		return []string{messageTagNames[MessageTagEncrypt0]}
+	case 4:
+		last := items[3]
+		switch {
+		case len(last) > 0 && last[0]>>5 == 2:
+			return []string{messageTagNames[MessageTagSign1], messageTagNames[MessageTagMAC0]}
+		case len(last) > 0 && last[0]>>5 == 4:
+			return []string{messageTagNames[MessageTagSign], messageTagNames[MessageTagEncrypt]}
+		}
+	case 5:
+		return []string{messageTagNames[MessageTagMAC]}
+	}
+	return nil
+}
+
+// contentTypeMessageTag returns the COSE message tag claimed by the content type header value.
+func contentTypeMessageTag(contentType interface{}) (uint64, bool) {
+	switch ct := contentType.(type) {
+	case int64:
+		// CoAP content formats for COSE messages match the message tags
+		for _, tag := range messageTypeNames {
+			if uint64(ct) == tag {
+				return tag, ct >= 0
+			}
+		}
+	case uint64:
+		return contentTypeMessageTag(int64(ct))
+	case int:
+		return contentTypeMessageTag(int64(ct))
+	case string:
+		mediaType, params, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/cose" {
+			return 0, false
+		}
+		tag, ok := messageTypeNames[params["cose-type"]]
+		return tag, ok
+	}
+	return 0, false
+}
+
+// validateMessage checks that the critical headers of the message are present.
+func validateMessage(headers *Headers) error {
+	if headers == nil {
+		return nil
+	}
+	return headers.ValidateCritical()
+}
+
+// ValidateContentTypeTag checks that the content type header of the message does not name
+// a different COSE message type, it fails with ErrContentTypeMismatch.
+//
+// The content type describes the payload (RFC 9052 section 3.1), a payload that is itself
+// a different COSE message is valid. The check is only meant for applications that never nest messages.
+func ValidateContentTypeTag(m Message) error {
+	if isNilMessage(m) {
+		return errors.New("message can not be nil")
+	}
+	headers := messageHeaders(m)
+	if headers == nil {
+		return nil
+	}
+	ct, _, err := headers.Lookup(HeaderContentType)
+	if err != nil {
+		return err
+	}
+	if tag, ok := contentTypeMessageTag(ct); ok && tag != m.GetMessageTag() {
+		return ErrContentTypeMismatch{ContentType: ct, Tag: m.GetMessageTag()}
+	}
+	return nil
+}
+
+// externalAAD returns the external data encoded as an empty byte string if not given.
+func externalAAD(external []byte) []byte {
+	if external == nil {
+		return []byte{}
+	}
+	return external
+}
+
+// MessageEqual reports whether the messages have the same type, headers and content.
+func MessageEqual(a, b Message) bool {
+	aNil, bNil := isNilMessage(a), isNilMessage(b)
+	if aNil || bNil {
+		return aNil == bNil
+	}
+	return a.GetMessageTag() == b.GetMessageTag() &&
+		HeadersEqual(messageHeaders(a), messageHeaders(b)) &&
+		bytes.Equal(a.GetContent(), b.GetContent())
+}
+
+// Sign1MessageEqual reports whether the messages have the same headers, content and
+// for decoded messages the same signature.
+func Sign1MessageEqual(a, b *Sign1Message) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !MessageEqual(a, b) {
+		return false
+	}
+	var sigA, sigB []byte
+	if a.raw != nil {
+		sigA = a.raw.Signature
+	}
+	if b.raw != nil {
+		sigB = b.raw.Signature
+	}
+	return bytes.Equal(sigA, sigB)
+}
+
+func isNilMessage(m Message) bool {
+	if m == nil {
+		return true
+	}
+	v := reflect.ValueOf(m)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func messageHeaders(m Message) *Headers {
+	switch msg := m.(type) {
+	case *Sign1Message:
+		return msg.Headers
+	case *SignMessage:
+		return msg.Headers
+	case *EncryptMessage:
+		return msg.Headers
+	case *Encrypt0Message:
+		return msg.Headers
+	case *Mac0Message:
+		return msg.Headers
+	}
+	return nil
+}
+
+// payload is a decoded byte string referring to the decoded data to avoid copying large payloads.
+type payload []byte
+
+func (p *payload) UnmarshalCBOR(data []byte) error {
+	if len(data) == 1 && (data[0] == 0xf6 || data[0] == 0xf7) {
+		*p = nil
+		return nil
+	}
+	if len(data) == 0 || data[0]>>5 != 2 {
+		return errors.New("cbor: payload must be a byte string")
+	}
+	// Indefinite length byte string chunks must be joined
+	if data[0]&0x1f == 31 {
+		var b []byte
+		if err := cbor.Unmarshal(data, &b); err != nil {
+			return err
+		}
+		*p = b
+		return nil
+	}
+	length, n, err := parseHeadArgument(data)
+	if err != nil {
+		return err
+	}
+	if uint64(len(data)-n) != length {
+		return errors.New("cbor: invalid payload byte string length")
+	}
+	*p = data[n:len(data):len(data)]
+	return nil
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateContentTypeTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		contentType interface{}
+		wantErr     bool
+	}{
+		{name: "sign1 without content type", msg: NewSign1Message()},
+		{name: "sign1 unrelated content type", msg: NewSign1Message(), contentType: "application/json"},
+		{name: "sign1 unrelated content format", msg: NewSign1Message(), contentType: 60},
+		{name: "sign1 media type", msg: NewSign1Message(), contentType: `application/cose; cose-type="cose-sign1"`},
+		{name: "sign1 content format", msg: NewSign1Message(), contentType: 18},
+		{name: "sign1 claims sign", msg: NewSign1Message(), contentType: `application/cose; cose-type="cose-sign"`, wantErr: true},
+		{name: "sign1 claims sign format", msg: NewSign1Message(), contentType: int64(98), wantErr: true},
+		{name: "sign media type", msg: NewSignMessage(), contentType: `application/cose; cose-type="cose-sign"`},
+		{name: "sign claims sign1", msg: NewSignMessage(), contentType: `application/cose; cose-type="cose-sign1"`, wantErr: true},
+		{name: "sign claims mac0", msg: NewSignMessage(), contentType: 17, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers *Headers
+			switch m := tt.msg.(type) {
+			case *Sign1Message:
+				headers = m.Headers
+			case *SignMessage:
+				headers = m.Headers
+			}
+			if tt.contentType != nil {
+				require.NoError(t, headers.Set(HeaderContentType, tt.contentType))
+			}
+
+			err := ValidateContentTypeTag(tt.msg)
+			if tt.wantErr {
+				var ctErr ErrContentTypeMismatch
+				require.ErrorAs(t, err, &ctErr)
+				assert.Equal(t, tt.msg.GetMessageTag(), ctErr.Tag)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMessage_Validate(t *testing.T) {
+	var _ MessageValidator = NewSign1Message()
+	var _ MessageValidator = NewSignMessage()
+	var _ MessageValidator = NewEncryptMessage()
+	var _ MessageValidator = NewEncrypt0Message()
+	var _ MessageValidator = NewMac0Message()
+
+	// The content type describes the payload, a nested COSE message is valid
+	msg := NewSign1Message()
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, int64(MessageTagEncrypt0)))
+	assert.NoError(t, msg.Validate())
+
+	require.NoError(t, msg.Headers.SetProtected(HeaderCritical, []interface{}{int64(-70000)}))
+	assert.Equal(t, ErrCriticalHeaderMissing{Label: int64(-70000)}, msg.Validate())
+	require.NoError(t, msg.Headers.SetProtected(int64(-70000), true))
+	assert.NoError(t, msg.Validate())
+}
+
+func TestMessageEqual(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte("kid")))
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	dec1, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	dec2, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+
+	assert.True(t, MessageEqual(dec1, dec2))
+	assert.True(t, Sign1MessageEqual(dec1.(*Sign1Message), dec2.(*Sign1Message)))
+
+	dec2.SetContent([]byte("tesT"))
+	assert.False(t, MessageEqual(dec1, dec2))
+	assert.False(t, Sign1MessageEqual(dec1.(*Sign1Message), dec2.(*Sign1Message)))
+
+	dec2.SetContent([]byte("test"))
+	dec2.(*Sign1Message).raw.Signature[0] ^= 1
+	assert.True(t, MessageEqual(dec1, dec2))
+	assert.False(t, Sign1MessageEqual(dec1.(*Sign1Message), dec2.(*Sign1Message)))
+
+	require.NoError(t, dec2.(*Sign1Message).Headers.Set(HeaderKeyID, []byte("other")))
+	assert.False(t, MessageEqual(dec1, dec2))
+
+	assert.True(t, MessageEqual(nil, nil))
+	assert.True(t, MessageEqual(nil, (*Sign1Message)(nil)))
+	assert.False(t, MessageEqual(dec1, nil))
+	assert.False(t, MessageEqual(nil, dec1))
+	assert.True(t, Sign1MessageEqual(nil, nil))
+	assert.False(t, Sign1MessageEqual(dec1.(*Sign1Message), nil))
+	assert.False(t, MessageEqual(NewSign1Message(), NewSignMessage()))
+}
+
+func TestMessage_ToBytes(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := msg.ToBytes()
+	require.NoError(t, err)
+	dec, err := Sign1MessageFromBytes(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), dec.GetContent())
+
+	smsg := NewSignMessage()
+	smsg.SetContent([]byte("test"))
+	smsg.AddSigner(signer)
+	b, err = smsg.ToBytes()
+	require.NoError(t, err)
+	sdec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), sdec.GetContent())
+
+	_, err = Sign1MessageFromBytes(b, config)
+	assert.Equal(t, ErrUnexpectedMessageTag{Tag: MessageTagSign, Expected: []uint64{MessageTagSign1}}, err)
+
+	_, err = NewSign1Message().ToBytes()
+	assert.ErrorIs(t, err, ErrNoSigner)
+}
+
+func TestSign1Message_SetContentReader(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 5<<20/16)
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	expected := NewSign1Message()
+	expected.SetContent(content)
+	require.NoError(t, expected.SetSigner(signer))
+	want, err := expected.ToBytes()
+	require.NoError(t, err)
+
+	r, w := io.Pipe()
+	go func() {
+		_, err := w.Write(content)
+		_ = w.CloseWithError(err)
+	}()
+	msg := NewSign1Message()
+	msg.SetContentReader(r)
+	require.NoError(t, msg.SetSigner(signer))
+	got, err := msg.ToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = msg.ToBytes()
+	assert.ErrorIs(t, err, ErrPayloadAlreadyConsumed)
+
+	msg.SetContent(content)
+	got, err = msg.ToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestSign1Message_Builder(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	msg := NewSign1Message().
+		WithContent([]byte("test")).
+		WithSigner(signer).
+		WithProtectedHeader(HeaderContentType, "text/plain").
+		WithHeader(HeaderKeyID, []byte("kid"))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	dec, err := StdEncoding.DecodeSign1(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), dec.GetContent())
+	ct, err := dec.Headers.GetProtected(HeaderContentType)
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", ct)
+	kid, err := dec.Headers.Get(HeaderKeyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("kid"), kid)
+}
+
+func TestSign1Message_BuilderError(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	msg := NewSign1Message().
+		WithContent([]byte("test")).
+		WithProtectedHeader(0, "reserved").
+		WithSigner(nil).
+		WithHeader(HeaderKeyID, []byte("kid"))
+	_, err := StdEncoding.Encode(msg)
+	var buildErr ErrMessageBuild
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, "WithProtectedHeader", buildErr.Step)
+	assert.ErrorIs(t, err, ErrReservedHeaderLabel)
+
+	// The first error is kept even if later steps succeed
+	msg.WithSigner(signer)
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrReservedHeaderLabel)
+	_, err = StdEncoding.EstimateEncodedSize(msg)
+	assert.ErrorIs(t, err, ErrReservedHeaderLabel)
+
+	_, err = StdEncoding.Encode(NewSign1Message().WithContent([]byte("test")).WithSigner(nil))
+	require.ErrorAs(t, err, &buildErr)
+	assert.Equal(t, "WithSigner", buildErr.Step)
+	assert.EqualError(t, err, "WithSigner: signer can not be nil")
+}
+
+func TestSign1Message_PreValidate(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	insecure, err := NewSignerInsecure(AlgorithmPS256, getPrivateKey(t, "rsa1024"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		msg  *Sign1Message
+		err  error
+	}{
+		{"Valid", NewSign1Message().WithContent([]byte("test")).WithSigner(signer), nil},
+		{"EmptyContent", NewSign1Message().WithContent([]byte{}).WithSigner(signer), nil},
+		{"BuildError", NewSign1Message().WithContent([]byte("test")).WithSigner(nil), ErrMessageBuild{}},
+		{"NoSigner", NewSign1Message().WithContent([]byte("test")), ErrNoSigner},
+		{"NoContent", NewSign1Message().WithSigner(signer), ErrNoContent},
+		{"UnsupportedAlgorithm", NewSign1Message().WithContent([]byte("test")).WithSigner(&Signer{Headers: NewHeaders()}), ErrUnsupportedAlgorithm},
+		{"MinKeySize", NewSign1Message().WithContent([]byte("test")).WithSigner(insecure), ErrMinKeySize{2048}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.PreValidate()
+			switch tt.err.(type) {
+			case nil:
+				assert.NoError(t, err)
+			case ErrMessageBuild:
+				assert.ErrorAs(t, err, &ErrMessageBuild{})
+			default:
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+
+	msg := NewSign1Message().WithSigner(signer)
+	msg.SetContentReader(bytes.NewReader([]byte("test")))
+	assert.NoError(t, msg.PreValidate())
+}
+
+func TestSign1Message_IsDetached(t *testing.T) {
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	msg := NewSign1Message().WithContent([]byte("test")).WithSigner(signer)
+	assert.False(t, msg.IsDetached())
+
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	decoded, err := StdEncoding.DecodeSign1(data, config)
+	require.NoError(t, err)
+	assert.False(t, decoded.IsDetached())
+
+	data, payload, err := StdEncoding.EncodeSign1Detached(msg, nil)
+	require.NoError(t, err)
+	decoded, err = StdEncoding.DecodeSign1WithPayload(data, payload, nil, config)
+	require.NoError(t, err)
+	assert.True(t, decoded.IsDetached())
+
+	// Detached messages are valid without content
+	decoded.SetContent(nil)
+	require.NoError(t, decoded.SetSigner(signer))
+	assert.NoError(t, decoded.PreValidate())
+}
+
+func TestCloneSign1Message(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("original"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("key-1")))
+	require.NoError(t, msg.SetSigner(signer))
+	want, err := msg.ToBytes()
+	require.NoError(t, err)
+
+	clone := CloneSign1Message(msg)
+	assert.Same(t, signer, clone.GetSigner())
+	clone.SetContent([]byte("modified"))
+	kid, err := clone.Headers.Get(HeaderKeyID)
+	require.NoError(t, err)
+	kid.([]byte)[0] = 'K'
+	require.NoError(t, clone.Headers.Set(HeaderContentType, "text/plain"))
+	cloned, err := clone.ToBytes()
+	require.NoError(t, err)
+	assert.NotEqual(t, want, cloned)
+
+	got, err := msg.ToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Nil(t, CloneSign1Message(nil))
+}
+
+func TestCloneSignMessage(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("original"))
+	msg.AddSigner(signer)
+	want, err := msg.ToBytes()
+	require.NoError(t, err)
+
+	clone := CloneSignMessage(msg)
+	clone.SetContent([]byte("modified"))
+	other, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	clone.AddSigner(other)
+	cloned, err := clone.ToBytes()
+	require.NoError(t, err)
+	assert.NotEqual(t, want, cloned)
+
+	got, err := msg.ToBytes()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Nil(t, CloneSignMessage(nil))
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"time"
+)
+
+// Phase is an encode or decode operation phase reported to the MetricsCollector.
+type Phase string
+
+const (
+	// PhaseParse is parsing the message tag and structure when decoding.
+	PhaseParse Phase = "parse"
+	// PhaseResolveVerifiers is resolving the verifiers for a signature when decoding.
+	PhaseResolveVerifiers Phase = "resolve_verifiers"
+	// PhaseVerify is verifying a signature with the resolved verifiers when decoding.
+	PhaseVerify Phase = "verify"
+	// PhaseSign is signing, encrypting or authenticating the message when encoding.
+	PhaseSign Phase = "sign"
+	// PhaseMarshal is serializing the message when encoding.
+	PhaseMarshal Phase = "marshal"
+)
+
+// MetricsCollector receives the duration and result of encode and decode phases.
+//
+// The algorithm is empty when it is not known for the phase, such as when parsing
+// and the message tag is zero if parsing the message tag failed.
+// Methods may be called concurrently and must not block.
+type MetricsCollector interface {
+	ObserveDecode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error)
+	ObserveEncode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error)
+}
+
+// metricsStart returns the phase start time if metrics are collected.
+func (e *Encoding) metricsStart() time.Time {
+	if e.metrics == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (e *Encoding) observeDecode(alg Algorithm, tag uint64, phase Phase, start time.Time, err error) {
+	if e.metrics != nil {
+		e.metrics.ObserveDecode(alg, tag, phase, time.Since(start), err)
+	}
+}
+
+func (e *Encoding) observeEncode(alg Algorithm, tag uint64, phase Phase, start time.Time, err error) {
+	if e.metrics != nil {
+		e.metrics.ObserveEncode(alg, tag, phase, time.Since(start), err)
+	}
+}
+
+// messageAlgorithm returns the algorithm used to encode the message or empty if the
+// message has multiple signers.
+func messageAlgorithm(m Message) Algorithm {
+	var a *algorithm
+	switch msg := m.(type) {
+	case *Sign1Message:
+		if msg.signer != nil {
+			a = msg.signer.alg
+		}
+	case *Mac0Message:
+		if msg.macer != nil {
+			a = msg.macer.alg
+		}
+	case *Encrypt0Message:
+		a = msg.alg
+	case *EncryptMessage:
+		a = msg.alg
+	}
+	if a == nil {
+		return ""
+	}
+	return Algorithm(a.Name)
+}
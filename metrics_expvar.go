@@ -0,0 +1,52 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_noexpvar
+// +build !cose_noexpvar
+
+package cose
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+)
+
+// ExpvarMetrics is a MetricsCollector publishing operation counts, error counts and
+// total durations in nanoseconds as an expvar map.
+//
+// Keys are formatted as "<decode|encode>.<phase>.<count|errors|ns>".
+type ExpvarMetrics struct {
+	m *expvar.Map
+}
+
+// NewExpvarMetrics creates a new ExpvarMetrics published with the given name.
+// Like expvar.NewMap it panics if the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return &ExpvarMetrics{m: expvar.NewMap(name)}
+}
+
+// Map returns the published expvar map.
+func (c *ExpvarMetrics) Map() *expvar.Map {
+	return c.m
+}
+
+// ObserveDecode records the decode phase.
+func (c *ExpvarMetrics) ObserveDecode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error) {
+	c.observe("decode", phase, d, err)
+}
+
+// ObserveEncode records the encode phase.
+func (c *ExpvarMetrics) ObserveEncode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error) {
+	c.observe("encode", phase, d, err)
+}
+
+func (c *ExpvarMetrics) observe(op string, phase Phase, d time.Duration, err error) {
+	key := fmt.Sprintf("%s.%s.", op, phase)
+	c.m.Add(key+"count", 1)
+	c.m.Add(key+"ns", int64(d))
+	if err != nil {
+		c.m.Add(key+"errors", 1)
+	}
+}
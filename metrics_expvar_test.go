@@ -0,0 +1,28 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_noexpvar
+// +build !cose_noexpvar
+
+package cose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m := NewExpvarMetrics("cose_test_metrics")
+	m.ObserveDecode(AlgorithmES256, MessageTagSign1, PhaseVerify, time.Millisecond, nil)
+	m.ObserveDecode(AlgorithmES256, MessageTagSign1, PhaseVerify, time.Millisecond, ErrVerification)
+	m.ObserveEncode(AlgorithmES256, MessageTagSign1, PhaseSign, time.Microsecond, nil)
+
+	assert.Equal(t, "2", m.Map().Get("decode.verify.count").String())
+	assert.Equal(t, "1", m.Map().Get("decode.verify.errors").String())
+	assert.Equal(t, "2000000", m.Map().Get("decode.verify.ns").String())
+	assert.Equal(t, "1", m.Map().Get("encode.sign.count").String())
+	assert.Nil(t, m.Map().Get("encode.sign.errors"))
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type observation struct {
+	alg   Algorithm
+	tag   uint64
+	phase Phase
+	err   error
+}
+
+type recordingMetrics struct {
+	mu     sync.Mutex
+	decode []observation
+	encode []observation
+}
+
+func (r *recordingMetrics) ObserveDecode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decode = append(r.decode, observation{alg, messageTag, phase, err})
+}
+
+func (r *recordingMetrics) ObserveEncode(alg Algorithm, messageTag uint64, phase Phase, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encode = append(r.encode, observation{alg, messageTag, phase, err})
+}
+
+func phases(obs []observation) []Phase {
+	var p []Phase
+	for _, o := range obs {
+		p = append(p, o.phase)
+	}
+	return p
+}
+
+func TestEncoding_Metrics(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := NewSigner(AlgorithmES256, key)
+	require.NoError(t, err)
+
+	metrics := &recordingMetrics{}
+	e, err := NewEncoding(WithMetrics(metrics))
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("metrics"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := e.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, []observation{
+		{AlgorithmES256, MessageTagSign1, PhaseSign, nil},
+		{AlgorithmES256, MessageTagSign1, PhaseMarshal, nil},
+	}, metrics.encode)
+
+	t.Run("success", func(t *testing.T) {
+		metrics.decode = nil
+		_, err := e.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+		require.NoError(t, err)
+		assert.Equal(t, []observation{
+			{"", MessageTagSign1, PhaseParse, nil},
+			{AlgorithmES256, MessageTagSign1, PhaseResolveVerifiers, nil},
+			{AlgorithmES256, MessageTagSign1, PhaseVerify, nil},
+		}, metrics.decode)
+	})
+
+	t.Run("verification failure", func(t *testing.T) {
+		metrics.decode = nil
+		_, err := e.Decode(b, &Config{GetVerifiers: staticVerifier(t, other)})
+		require.Error(t, err)
+		assert.Equal(t, []Phase{PhaseParse, PhaseResolveVerifiers, PhaseVerify}, phases(metrics.decode))
+		assert.Error(t, metrics.decode[2].err)
+	})
+
+	t.Run("resolver error", func(t *testing.T) {
+		metrics.decode = nil
+		errResolve := errors.New("no keys")
+		_, err := e.Decode(b, &Config{GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return nil, errResolve
+		}})
+		require.Error(t, err)
+		assert.Equal(t, []Phase{PhaseParse, PhaseResolveVerifiers}, phases(metrics.decode))
+		assert.ErrorIs(t, metrics.decode[1].err, errResolve)
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		metrics.decode = nil
+		_, err := e.Decode([]byte{0xff}, nil)
+		require.Error(t, err)
+		require.Equal(t, []Phase{PhaseParse}, phases(metrics.decode))
+		assert.Error(t, metrics.decode[0].err)
+	})
+
+	t.Run("encode error", func(t *testing.T) {
+		metrics.encode = nil
+		_, err := e.Encode(NewSign1Message())
+		assert.ErrorIs(t, err, ErrNoSigner)
+		require.Equal(t, []Phase{PhaseSign}, phases(metrics.encode))
+		assert.Equal(t, Algorithm(""), metrics.encode[0].alg)
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// WithPayloadDigestHeader writes the digest of the COSE_Sign1 payload to the protected header
+// with the given label when encoding, the header value is an array of the COSE hash algorithm
+// value and the digest. The digest allows checking a re-attached payload without verifying
+// the signature, decoding checks it if Config.VerifyPayloadDigestHeader is set.
+//
+// SHA-256, SHA-384 and SHA-512 are supported, the label can not be a common header label.
+func WithPayloadDigestHeader(label interface{}, h crypto.Hash) EncodingOption {
+	return func(e *Encoding) error {
+		if _, ok := payloadHashAlgorithms[h]; !ok {
+			return ErrUnsupportedAlgorithm
+		}
+		l, err := normalizeLabel(label)
+		if err != nil {
+			return err
+		}
+		if isCommonLabel(l) {
+			return fmt.Errorf("payload digest header label %v is a common header", l)
+		}
+		e.payloadDigestLabel = l
+		e.payloadDigestHash = h
+		return nil
+	}
+}
+
+// payloadDigest returns the payload digest header value.
+func payloadDigest(h crypto.Hash, content []byte) []interface{} {
+	d := h.New()
+	d.Write(content)
+	return []interface{}{payloadHashAlgorithms[h], d.Sum(nil)}
+}
+
+// checkPayloadDigest compares the payload digest header with the digest of the payload,
+// the header must use the hash algorithm of the encoding.
+func (e *Encoding) checkPayloadDigest(headers *Headers, content []byte) error {
+	if e.payloadDigestLabel == nil {
+		return errors.New("payload digest header is not configured for the encoding")
+	}
+	if content == nil {
+		return errors.New("payload digest can not be checked without the payload")
+	}
+	v, err := headers.GetProtected(e.payloadDigestLabel)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return fmt.Errorf("%w: payload digest header %v", ErrHeaderNotFound, e.payloadDigestLabel)
+	}
+	items, ok := v.([]interface{})
+	if !ok || len(items) != 2 {
+		return ErrInvalidHeader
+	}
+	digest, ok := items[1].([]byte)
+	if !ok {
+		return ErrInvalidHeader
+	}
+	if alg, ok := items[0].(int64); !ok || alg != payloadHashAlgorithms[e.payloadDigestHash] {
+		return ErrUnsupportedAlgorithm
+	}
+	expected := payloadDigest(e.payloadDigestHash, content)[1].([]byte)
+	if subtle.ConstantTimeCompare(digest, expected) != 1 {
+		return ErrPayloadDigestMismatch
+	}
+	return nil
+}
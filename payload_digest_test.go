@@ -0,0 +1,83 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding_WithPayloadDigestHeader(t *testing.T) {
+	e, err := NewEncoding(WithPayloadDigestHeader(-70010, crypto.SHA256))
+	require.NoError(t, err)
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	config := &Config{GetVerifiers: staticVerifier(t, signer), VerifyPayloadDigestHeader: true}
+
+	content := []byte("payload content")
+	b, err := e.Encode(NewSign1Message().WithContent(content).WithSigner(signer))
+	require.NoError(t, err)
+	dec, err := e.DecodeSign1(b, config)
+	require.NoError(t, err)
+	digest := sha256.Sum256(content)
+	v, err := dec.Headers.GetProtected(-70010)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(-16), digest[:]}, v)
+
+	// Corrupted payloads are rejected before verifying the signature
+	corrupted := bytes.Replace(b, content, []byte("payload CONTENT"), 1)
+	_, err = e.DecodeSign1(corrupted, config)
+	assert.ErrorIs(t, err, ErrPayloadDigestMismatch)
+	_, err = e.DecodeSign1(corrupted, &Config{GetVerifiers: staticVerifier(t, signer)})
+	assert.ErrorIs(t, err, ErrVerification)
+
+	// Messages without the header
+	b, err = StdEncoding.Encode(NewSign1Message().WithContent(content).WithSigner(signer))
+	require.NoError(t, err)
+	_, err = e.DecodeSign1(b, config)
+	assert.ErrorIs(t, err, ErrHeaderNotFound)
+	_, err = StdEncoding.DecodeSign1(b, config)
+	assert.EqualError(t, err, "payload digest header is not configured for the encoding")
+
+	// The header must use the hash algorithm of the encoding
+	e384, err := NewEncoding(WithPayloadDigestHeader(-70010, crypto.SHA384))
+	require.NoError(t, err)
+	b, err = e384.Encode(NewSign1Message().WithContent(content).WithSigner(signer))
+	require.NoError(t, err)
+	_, err = e.DecodeSign1(b, config)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}
+
+func TestEncoding_WithPayloadDigestHeaderDetached(t *testing.T) {
+	e, err := NewEncoding(WithPayloadDigestHeader("x-digest", crypto.SHA512))
+	require.NoError(t, err)
+	signer := MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	config := &Config{GetVerifiers: staticVerifier(t, signer), VerifyPayloadDigestHeader: true}
+
+	msg := NewSign1Message().WithContent([]byte("detached")).WithSigner(signer)
+	b, payload, err := e.EncodeSign1Detached(msg, nil)
+	require.NoError(t, err)
+
+	dec, err := e.DecodeSign1WithPayload(b, payload, nil, config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("detached"), dec.GetContent())
+	_, err = e.DecodeSign1WithPayload(b, []byte("replaced"), nil, config)
+	assert.ErrorIs(t, err, ErrPayloadDigestMismatch)
+	_, err = e.DecodeSign1(b, config)
+	assert.EqualError(t, err, "payload digest can not be checked without the payload")
+}
+
+func TestWithPayloadDigestHeader_Invalid(t *testing.T) {
+	_, err := NewEncoding(WithPayloadDigestHeader(-70010, crypto.MD5))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	_, err = NewEncoding(WithPayloadDigestHeader(HeaderKeyID, crypto.SHA256))
+	assert.Error(t, err)
+	_, err = NewEncoding(WithPayloadDigestHeader(1.5, crypto.SHA256))
+	assert.Error(t, err)
+}
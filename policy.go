@@ -0,0 +1,166 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// AlgorithmPolicy restricts the algorithms and keys used for encoding and decoding messages.
+type AlgorithmPolicy struct {
+	// Algorithms lists the permitted algorithms, all algorithms are permitted if empty
+	Algorithms []Algorithm
+	// MinRSAKeySize is the minimum RSA key size in bits in addition to the algorithm minimum
+	MinRSAKeySize int
+	// Curves lists the permitted elliptic curves of ECDSA keys, all curves are permitted if empty
+	Curves []elliptic.Curve
+	// DenySymmetric rejects encryption and MAC messages
+	DenySymmetric bool
+	// RequireHashPSSSaltLength rejects RSASSA-PSS signers and verifiers with salt lengths
+	// other than the hash length
+	RequireHashPSSSaltLength bool
+	// RequireSystemRandom rejects deterministic signing, all randomness is from crypto/rand
+	RequireSystemRandom bool
+}
+
+// DefaultPolicy returns the policy permitting all algorithms and keys.
+func DefaultPolicy() AlgorithmPolicy {
+	return AlgorithmPolicy{}
+}
+
+// FIPSLikePolicy returns the policy permitting only ES256 and ES384 with P-256 and P-384 keys
+// and RSASSA-PSS with keys of at least 2048 bits and salt length equal to the hash length.
+//
+// The policy follows FIPS 186 algorithm choices but does not make the library FIPS validated.
+func FIPSLikePolicy() AlgorithmPolicy {
+	return AlgorithmPolicy{
+		Algorithms: []Algorithm{
+			AlgorithmES256,
+			AlgorithmES384,
+			AlgorithmPS256,
+			AlgorithmPS384,
+			AlgorithmPS512,
+		},
+		MinRSAKeySize:            2048,
+		Curves:                   []elliptic.Curve{elliptic.P256(), elliptic.P384()},
+		DenySymmetric:            true,
+		RequireHashPSSSaltLength: true,
+		RequireSystemRandom:      true,
+	}
+}
+
+// WithAlgorithmPolicy restricts the algorithms and keys of encoded and decoded messages,
+// violations fail with ErrPolicyViolation.
+func WithAlgorithmPolicy(policy AlgorithmPolicy) EncodingOption {
+	return func(e *Encoding) error {
+		if policy.MinRSAKeySize < 0 {
+			return errors.New("minimum RSA key size can not be negative")
+		}
+		e.policy = &policy
+		return nil
+	}
+}
+
+// validate checks the encoding options against the policy.
+func (p *AlgorithmPolicy) validate(e *Encoding) error {
+	if p == nil || !p.RequireSystemRandom {
+		return nil
+	}
+	if e.deterministicSeed != nil {
+		return ErrPolicyViolation{"deterministic signing is not permitted"}
+	}
+	if e.rand != rand.Reader {
+		return ErrPolicyViolation{"random source other than crypto/rand is not permitted"}
+	}
+	return nil
+}
+
+// checkAlgorithm checks that the algorithm is permitted, unknown algorithms are only checked
+// against the permitted algorithms.
+func (p *AlgorithmPolicy) checkAlgorithm(alg Algorithm) error {
+	if p == nil || alg == "" {
+		return nil
+	}
+	if len(p.Algorithms) > 0 && !containsAlgorithm(p.Algorithms, alg) {
+		return ErrPolicyViolation{fmt.Sprintf("algorithm %s is not permitted", alg)}
+	}
+	if a := getAlg(string(alg)); a != nil && p.DenySymmetric && !a.IsSigningAlgorithm() {
+		return ErrPolicyViolation{fmt.Sprintf("symmetric algorithm %s is not permitted", alg)}
+	}
+	return nil
+}
+
+// checkSymmetric checks that encryption and MAC messages are permitted.
+func (p *AlgorithmPolicy) checkSymmetric() error {
+	if p != nil && p.DenySymmetric {
+		return ErrPolicyViolation{"symmetric algorithms are not permitted"}
+	}
+	return nil
+}
+
+// checkKey checks the RSA key size and elliptic curve of a private or public key.
+func (p *AlgorithmPolicy) checkKey(key interface{}) error {
+	if p == nil {
+		return nil
+	}
+	var curve elliptic.Curve
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		curve = k.Curve
+	case *ecdsa.PublicKey:
+		curve = k.Curve
+	}
+	if curve != nil && len(p.Curves) > 0 && !containsCurve(p.Curves, curve) {
+		return ErrPolicyViolation{fmt.Sprintf("elliptic curve %s is not permitted", curve.Params().Name)}
+	}
+	if n := rsaModulus(key); n != nil && n.BitLen() < p.MinRSAKeySize {
+		return ErrPolicyViolation{fmt.Sprintf("RSA key size %d is smaller than %d", n.BitLen(), p.MinRSAKeySize)}
+	}
+	return nil
+}
+
+// checkSigner checks the signer algorithm, key and options.
+func (p *AlgorithmPolicy) checkSigner(s *Signer) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.checkAlgorithm(Algorithm(s.alg.Name)); err != nil {
+		return err
+	}
+	if p.RequireSystemRandom && s.deterministic {
+		return ErrPolicyViolation{"deterministic signing is not permitted"}
+	}
+	if p.RequireHashPSSSaltLength && s.pssSaltLength != pssSaltLengthEqualsHash {
+		return ErrPolicyViolation{"PSS salt length other than the hash length is not permitted"}
+	}
+	return p.checkKey(s.key())
+}
+
+// checkVerifier checks the verifier algorithm, key and options.
+func (p *AlgorithmPolicy) checkVerifier(v *Verifier) error {
+	if p == nil {
+		return nil
+	}
+	if err := p.checkAlgorithm(Algorithm(v.alg.Name)); err != nil {
+		return err
+	}
+	if p.RequireHashPSSSaltLength && v.lenientPSS {
+		return ErrPolicyViolation{"PSS salt length other than the hash length is not permitted"}
+	}
+	return p.checkKey(v.publicKey)
+}
+
+func containsCurve(curves []elliptic.Curve, curve elliptic.Curve) bool {
+	for _, c := range curves {
+		if c.Params().Name == curve.Params().Name {
+			return true
+		}
+	}
+	return false
+}
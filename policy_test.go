@@ -0,0 +1,146 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertPolicyViolation(t *testing.T, err error) {
+	t.Helper()
+	var violation ErrPolicyViolation
+	assert.True(t, errors.As(err, &violation), "expected policy violation, got %v", err)
+}
+
+func TestAlgorithmPolicy_FIPSLike(t *testing.T) {
+	fips, err := NewEncoding(WithAlgorithmPolicy(FIPSLikePolicy()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		alg     Algorithm
+		key     string
+		opts    []SignerOption
+		allowed bool
+	}{
+		{name: "ES256", alg: AlgorithmES256, key: "ecdsa256", allowed: true},
+		{name: "ES384", alg: AlgorithmES384, key: "ecdsa384", allowed: true},
+		{name: "PS256", alg: AlgorithmPS256, key: "rsa2048", allowed: true},
+		{name: "EdDSA", alg: AlgorithmEdDSA, key: "ed25519"},
+		{name: "ES512", alg: AlgorithmES512, key: "ecdsa521"},
+		{name: "PS256 auto salt", alg: AlgorithmPS256, key: "rsa2048", opts: []SignerOption{WithPSSSaltLength(rsa.PSSSaltLengthAuto)}},
+		{name: "PS256 1024", alg: AlgorithmPS256, key: "rsa1024", opts: []SignerOption{WithMinRSAKeySize(1024)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key), tt.opts...)
+			require.NoError(t, err)
+			msg := NewSign1Message()
+			msg.SetContent([]byte("test"))
+			require.NoError(t, msg.SetSigner(signer))
+			config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+			_, err = fips.Encode(msg)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assertPolicyViolation(t, err)
+			}
+
+			data, err := StdEncoding.Encode(msg)
+			require.NoError(t, err)
+			_, err = fips.Decode(data, config)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assertPolicyViolation(t, err)
+			}
+			_, err = StdEncoding.Decode(data, config)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAlgorithmPolicy_Symmetric(t *testing.T) {
+	key := mustHex(t, "403697de87af64611c1d32a05dab0fe1fcb715a86ab435f1ec99192d79569388")
+	macer, err := NewMACer(AlgorithmHMAC256, key)
+	require.NoError(t, err)
+	msg := NewMac0Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetMACer(macer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	config := &Config{GetTagVerifiers: func(*Headers) ([]*MACer, error) {
+		return []*MACer{macer}, nil
+	}}
+
+	deny, err := NewEncoding(WithAlgorithmPolicy(AlgorithmPolicy{DenySymmetric: true}))
+	require.NoError(t, err)
+	_, err = deny.Encode(msg)
+	assertPolicyViolation(t, err)
+	_, err = deny.Decode(data, config)
+	assertPolicyViolation(t, err)
+
+	allow, err := NewEncoding(WithAlgorithmPolicy(DefaultPolicy()))
+	require.NoError(t, err)
+	_, err = allow.Encode(msg)
+	assert.NoError(t, err)
+	_, err = allow.Decode(data, config)
+	assert.NoError(t, err)
+}
+
+func TestAlgorithmPolicy_DenySymmetricUnimplementedSignature(t *testing.T) {
+	p := &AlgorithmPolicy{DenySymmetric: true}
+	for _, alg := range []Algorithm{"RS256", "ES256K", "RS1", "HSS-LMS"} {
+		assert.NoError(t, p.checkAlgorithm(alg), alg)
+	}
+	assertPolicyViolation(t, p.checkAlgorithm(AlgorithmA128GCM))
+	assertPolicyViolation(t, p.checkAlgorithm(AlgorithmHMAC256))
+}
+
+func TestAlgorithmPolicy_Verifiers(t *testing.T) {
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	lenient, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa2048"), WithLenientPSSSaltLength())
+	require.NoError(t, err)
+	enc, err := NewEncoding(WithAlgorithmPolicy(FIPSLikePolicy()))
+	require.NoError(t, err)
+	_, err = enc.Decode(data, &Config{GetVerifiers: func(*Headers) ([]*Verifier, error) {
+		return []*Verifier{lenient}, nil
+	}})
+	assertPolicyViolation(t, err)
+}
+
+func TestAlgorithmPolicy_Options(t *testing.T) {
+	_, err := NewEncoding(WithAlgorithmPolicy(FIPSLikePolicy()), WithDeterministicSigning([]byte("seed")))
+	assertPolicyViolation(t, err)
+	_, err = NewEncoding(WithAlgorithmPolicy(FIPSLikePolicy()), WithRandomSource(bytes.NewReader(nil)))
+	assertPolicyViolation(t, err)
+	_, err = NewEncoding(WithAlgorithmPolicy(AlgorithmPolicy{MinRSAKeySize: -1}))
+	assert.Error(t, err)
+
+	signer, err := NewRFC6979Signer(AlgorithmES256, getPrivateKey(t, "ecdsa256").(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	fips, err := NewEncoding(WithAlgorithmPolicy(FIPSLikePolicy()))
+	require.NoError(t, err)
+	_, err = fips.Encode(msg)
+	assertPolicyViolation(t, err)
+}
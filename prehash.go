@@ -0,0 +1,66 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import "crypto"
+
+// COSE algorithm identifiers of payload hash algorithms
+var payloadHashAlgorithms = map[crypto.Hash]int64{
+	crypto.SHA256: -16,
+	crypto.SHA384: -43,
+	crypto.SHA512: -44,
+}
+
+// UsePreHash makes the message signature cover the hash of the payload instead of the payload,
+// the hash algorithm is stored in the payload hash alg protected header.
+//
+// The message payload is not changed, the pre-hash mode is only used for the Sig_structure.
+// Use crypto.Hash zero value to disable pre-hashing.
+func (m *Sign1Message) UsePreHash(hash crypto.Hash) error {
+	if _, ok := payloadHashAlgorithms[hash]; !ok && hash != 0 {
+		return ErrUnsupportedAlgorithm
+	}
+	m.preHash = hash
+	return nil
+}
+
+// payloadHash returns the pre-hash algorithm from the protected headers or zero if not pre-hashed.
+func payloadHash(headers *Headers) (crypto.Hash, error) {
+	v, err := headers.GetProtected(HeaderPayloadHashAlgorithm)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	var id int64
+	switch n := v.(type) {
+	case int:
+		id = int64(n)
+	case int64:
+		id = n
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+	for hash, value := range payloadHashAlgorithms {
+		if value == id {
+			return hash, nil
+		}
+	}
+	return 0, ErrUnsupportedAlgorithm
+}
+
+// signedDigest returns the Sig_structure with the payload replaced by its hash if hash is not zero.
+func (m *sign1Message) signedDigest(e *Encoding, hash crypto.Hash, external []byte) ([]byte, error) {
+	if hash == 0 {
+		return m.GetDigest(e, external)
+	}
+	h := hash.New()
+	h.Write(m.Payload)
+	s := SigStructure{
+		Context:       ContextSignature1,
+		BodyProtected: m.Protected,
+		ExternalAAD:   external,
+		Payload:       h.Sum(nil),
+	}
+	return s.Marshal(e)
+}
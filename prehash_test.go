@@ -0,0 +1,81 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign1Message_UsePreHash(t *testing.T) {
+	encoding, err := NewEncoding(WithDeterministicSigning([]byte("seed")))
+	require.NoError(t, err)
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	content := make([]byte, 2<<20)
+
+	encode := func(hash crypto.Hash) (*sign1Message, []byte) {
+		msg := NewSign1Message()
+		msg.SetContent(content)
+		require.NoError(t, msg.SetSigner(signer))
+		require.NoError(t, msg.UsePreHash(hash))
+		b, err := encoding.Encode(msg)
+		require.NoError(t, err)
+		dec, err := encoding.Decode(b, config)
+		require.NoError(t, err)
+		assert.Equal(t, content, dec.GetContent())
+		return dec.(*Sign1Message).raw, b
+	}
+
+	plain, _ := encode(0)
+	for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		t.Run(hash.String(), func(t *testing.T) {
+			prehashed, b := encode(hash)
+			assert.NotEqual(t, plain.Signature, prehashed.Signature)
+
+			headers, err := ParseProtectedHeaders(encoding, prehashed.Protected)
+			require.NoError(t, err)
+			alg, err := headers.GetProtected(HeaderPayloadHashAlgorithm)
+			require.NoError(t, err)
+			assert.Equal(t, payloadHashAlgorithms[hash], alg)
+
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			digest, err := prehashed.GetDigest(encoding, nil)
+			require.NoError(t, err)
+			assert.ErrorIs(t, verifier.Verify(digest, prehashed.Signature), ErrVerification)
+			digest, err = plain.signedDigest(encoding, hash, nil)
+			require.NoError(t, err)
+			assert.ErrorIs(t, verifier.Verify(digest, plain.Signature), ErrVerification)
+
+			dec, err := encoding.Decode(b, nil)
+			require.Error(t, err)
+			assert.NoError(t, dec.(*Sign1Message).VerifySignatureOnly(verifier, nil))
+		})
+	}
+}
+
+func TestSign1Message_UsePreHashInvalid(t *testing.T) {
+	msg := NewSign1Message()
+	assert.ErrorIs(t, msg.UsePreHash(crypto.MD5), ErrUnsupportedAlgorithm)
+
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	require.NoError(t, msg.SetSigner(signer))
+	require.NoError(t, msg.Headers.SetProtected(HeaderPayloadHashAlgorithm, int64(-16)))
+	_, err = StdEncoding.Encode(msg)
+	assert.Error(t, err)
+
+	require.NoError(t, msg.Headers.SetProtected(HeaderPayloadHashAlgorithm, int64(-1)))
+	require.NoError(t, msg.UsePreHash(crypto.SHA256))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"math/big"
+)
+
+// signRFC6979 signs the hashed digest with deterministic nonce generation as described in RFC 6979.
+func signRFC6979(key *ecdsa.PrivateKey, hash crypto.Hash, hashed []byte) (r, s *big.Int, err error) {
+	curve := key.Curve
+	q := curve.Params().N
+	qlen := q.BitLen()
+	rlen := (qlen + 7) / 8
+
+	e := bits2int(hashed, qlen)
+	x := i2ospPad(key.D, rlen)
+	h1 := i2ospPad(new(big.Int).Mod(e, q), rlen)
+
+	mac := func(k []byte, data ...[]byte) []byte {
+		h := hmac.New(hash.New, k)
+		for _, d := range data {
+			_, _ = h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+
+	v := make([]byte, hash.Size())
+	k := make([]byte, hash.Size())
+	for i := range v {
+		v[i] = 0x01
+	}
+	k = mac(k, v, []byte{0x00}, x, h1)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, x, h1)
+	v = mac(k, v)
+
+	for {
+		t := make([]byte, 0, rlen)
+		for len(t) < rlen {
+			v = mac(k, v)
+			t = append(t, v...)
+		}
+
+		nonce := bits2int(t, qlen)
+		if nonce.Sign() > 0 && nonce.Cmp(q) < 0 {
+			rx, _ := curve.ScalarBaseMult(i2ospPad(nonce, rlen))
+			r = new(big.Int).Mod(rx, q)
+			if r.Sign() != 0 {
+				kInv := new(big.Int).ModInverse(nonce, q)
+				s = new(big.Int).Mul(r, key.D)
+				s.Add(s, e)
+				s.Mul(s, kInv)
+				s.Mod(s, q)
+				if s.Sign() != 0 {
+					return r, s, nil
+				}
+			}
+		}
+
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
+
+// bits2int converts a bit string to an integer of at most qlen bits.
+func bits2int(b []byte, qlen int) *big.Int {
+	x := new(big.Int).SetBytes(b)
+	if blen := len(b) * 8; blen > qlen {
+		x.Rsh(x, uint(blen-qlen))
+	}
+	return x
+}
+
+// i2ospPad converts a nonnegative integer to a big-endian octet string of the given length.
+func i2ospPad(x *big.Int, n int) []byte {
+	b := x.Bytes()
+	if len(b) >= n {
+		return b[len(b)-n:]
+	}
+	out := make([]byte, n)
+	copy(out[n-len(b):], b)
+	return out
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_norsa
+// +build !cose_norsa
+
+package cose
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"io"
+	"math/big"
+)
+
+// RSA support can be left out with the cose_norsa build tag for constrained builds
+const rsaSupported = true
+
+// rsaModulus returns the modulus of RSA private and public keys or nil for other keys.
+func rsaModulus(key interface{}) *big.Int {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k.N
+	case *rsa.PublicKey:
+		return k.N
+	default:
+		return nil
+	}
+}
+
+// rsaPublicExponent returns the public exponent of RSA private and public keys or 0 for other keys.
+func rsaPublicExponent(key interface{}) int {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k.E
+	case *rsa.PublicKey:
+		return k.E
+	default:
+		return 0
+	}
+}
+
+// newRSAPublicKey returns the RSA public key with the modulus and exponent.
+func newRSAPublicKey(n *big.Int, e int) crypto.PublicKey {
+	return &rsa.PublicKey{N: n, E: e}
+}
+
+// signRSA signs the hashed digest with RSASSA-PSS, the key is an *rsa.PrivateKey
+// or a crypto.Signer of an RSA key.
+func signRSA(rand io.Reader, key crypto.PrivateKey, hash crypto.Hash, digest []byte, saltLength int) ([]byte, error) {
+	opts := &rsa.PSSOptions{
+		SaltLength: saltLength,
+		Hash:       hash,
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPSS(rand, k, hash, digest, opts)
+	case crypto.Signer:
+		return k.Sign(rand, digest, opts)
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// verifyRSA verifies the RSASSA-PSS signature of the hashed digest, any salt length is accepted if lenient is set.
+func verifyRSA(key crypto.PublicKey, hash crypto.Hash, digest, sig []byte, lenient bool) error {
+	k, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return ErrUnsupportedKeyType
+	}
+	saltLength := rsa.PSSSaltLengthEqualsHash
+	if lenient {
+		saltLength = rsa.PSSSaltLengthAuto
+	}
+	err := rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{
+		SaltLength: saltLength,
+		Hash:       hash,
+	})
+	if err == rsa.ErrVerification {
+		return ErrVerification
+	}
+	return err
+}
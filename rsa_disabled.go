@@ -0,0 +1,37 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build cose_norsa
+// +build cose_norsa
+
+package cose
+
+import (
+	"crypto"
+	"io"
+	"math/big"
+)
+
+// RSA support is left out with the cose_norsa build tag, RSA keys are not recognized
+const rsaSupported = false
+
+func rsaModulus(key interface{}) *big.Int {
+	return nil
+}
+
+func rsaPublicExponent(key interface{}) int {
+	return 0
+}
+
+func newRSAPublicKey(n *big.Int, e int) crypto.PublicKey {
+	return nil
+}
+
+func signRSA(rand io.Reader, key crypto.PrivateKey, hash crypto.Hash, digest []byte, saltLength int) ([]byte, error) {
+	return nil, ErrUnsupportedKeyType
+}
+
+func verifyRSA(key crypto.PublicKey, hash crypto.Hash, digest, sig []byte, lenient bool) error {
+	return ErrUnsupportedKeyType
+}
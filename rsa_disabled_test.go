@@ -0,0 +1,37 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build cose_norsa
+// +build cose_norsa
+
+package cose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoRSA(t *testing.T) {
+	_, err := NewSigner(AlgorithmPS256, parsePrivateKey(t, "rsa2048"))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	_, err = NewVerifier(AlgorithmES256, parseCertificate(t, "rsa2048").PublicKey)
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+	_, err = NewRSAVerifierFromModulus(AlgorithmPS256, []byte{1}, 65537)
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+	for _, info := range SupportedAlgorithms() {
+		assert.NotContains(t, []string{"PS256", "PS384", "PS512"}, info.Name)
+	}
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = StdEncoding.Decode(data, &Config{GetVerifiers: staticVerifier(t, signer)})
+	assert.NoError(t, err)
+}
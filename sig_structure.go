@@ -0,0 +1,71 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Context strings of the structures signed or authenticated by COSE messages.
+const (
+	// ContextSignature is the context of COSE_Sign signatures
+	ContextSignature = "Signature"
+	// ContextSignature1 is the context of COSE_Sign1 signatures
+	ContextSignature1 = "Signature1"
+	// ContextCounterSignature is the context of full countersignatures
+	ContextCounterSignature = "CounterSignature"
+	// ContextCounterSignature0 is the context of abbreviated countersignatures
+	ContextCounterSignature0 = "CounterSignature0"
+	// ContextMAC is the context of COSE_Mac tags
+	ContextMAC = "MAC"
+	// ContextMAC0 is the context of COSE_Mac0 tags
+	ContextMAC0 = "MAC0"
+)
+
+// SigStructure is the Sig_structure (RFC 9052 section 4.4) or MAC_structure (RFC 9052 section 6.3)
+// of a message, its encoding is the ToBeSigned or ToBeMaced data.
+//
+// SignProtected is encoded only for the Signature and CounterSignature contexts,
+// the structure has five elements for those contexts and four for the others.
+type SigStructure struct {
+	Context       string
+	BodyProtected []byte
+	SignProtected []byte
+	ExternalAAD   []byte
+	Payload       []byte
+}
+
+// hasSignProtected reports whether the structure of the context contains the sign_protected element.
+func hasSignProtected(context string) (bool, error) {
+	switch context {
+	case ContextSignature, ContextCounterSignature:
+		return true, nil
+	case ContextSignature1, ContextCounterSignature0, ContextMAC, ContextMAC0:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown signature structure context %q", context)
+	}
+}
+
+// Marshal returns the encoded structure, a nil external data is encoded as an empty byte string.
+func (s *SigStructure) Marshal(e *Encoding) ([]byte, error) {
+	signProtected, err := hasSignProtected(s.Context)
+	if err != nil {
+		return nil, err
+	}
+	if !signProtected && s.SignProtected != nil {
+		return nil, errors.New("sign_protected is not used in " + s.Context + " structure")
+	}
+	if e == nil {
+		e = StdEncoding
+	}
+	items := make([]interface{}, 0, 5)
+	items = append(items, s.Context, s.BodyProtected)
+	if signProtected {
+		items = append(items, s.SignProtected)
+	}
+	return e.marshal(append(items, externalAAD(s.ExternalAAD), s.Payload))
+}
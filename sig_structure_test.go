@@ -0,0 +1,115 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigStructure_Marshal(t *testing.T) {
+	protected := mustHex(t, "a10127")
+	tests := []struct {
+		name     string
+		s        SigStructure
+		expected string
+	}{
+		{
+			name:     "Signature1",
+			s:        SigStructure{Context: ContextSignature1, BodyProtected: protected, Payload: []byte("test")},
+			expected: "846a5369676e61747572653143a101274044" + "74657374",
+		},
+		{
+			name:     "Signature",
+			s:        SigStructure{Context: ContextSignature, BodyProtected: []byte{}, SignProtected: protected, Payload: []byte("test")},
+			expected: "85695369676e61747572654043a101274044" + "74657374",
+		},
+		{
+			name:     "CounterSignature",
+			s:        SigStructure{Context: ContextCounterSignature, BodyProtected: protected, SignProtected: []byte{}, ExternalAAD: []byte{1}, Payload: []byte{}},
+			expected: "8570436f756e7465725369676e617475726543a101274041" + "0140",
+		},
+		{
+			name:     "MAC0",
+			s:        SigStructure{Context: ContextMAC0, BodyProtected: protected, ExternalAAD: []byte{1}, Payload: []byte("test")},
+			expected: "84644d41433043a10127410144" + "74657374",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.s.Marshal(StdEncoding)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, hex.EncodeToString(b))
+		})
+	}
+
+	_, err := (&SigStructure{Context: "Unknown"}).Marshal(StdEncoding)
+	assert.Error(t, err)
+	_, err = (&SigStructure{Context: ContextSignature1, SignProtected: protected}).Marshal(StdEncoding)
+	assert.Error(t, err)
+}
+
+func TestSigStructure_Sign1(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.EncodeWithExternal(msg, []byte("aad"))
+	require.NoError(t, err)
+
+	var raw sign1Message
+	require.NoError(t, cbor.Unmarshal(data, &raw))
+	s := SigStructure{
+		Context:       ContextSignature1,
+		BodyProtected: raw.Protected,
+		ExternalAAD:   []byte("aad"),
+		Payload:       raw.Payload,
+	}
+	tbs, err := s.Marshal(StdEncoding)
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify(tbs, raw.Signature))
+
+	digest, err := raw.GetDigest(StdEncoding, []byte("aad"))
+	require.NoError(t, err)
+	assert.Equal(t, tbs, digest)
+}
+
+func TestSigStructure_Sign(t *testing.T) {
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+
+	msg := NewSignMessage()
+	msg.SetContent([]byte("test"))
+	msg.AddSigner(signer)
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	var raw signMessage
+	require.NoError(t, cbor.Unmarshal(data, &raw))
+	require.Len(t, raw.Signatures, 1)
+	s := SigStructure{
+		Context:       ContextSignature,
+		BodyProtected: raw.Protected,
+		SignProtected: raw.Signatures[0].Protected,
+		Payload:       raw.Payload,
+	}
+	tbs, err := s.Marshal(StdEncoding)
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify(tbs, raw.Signatures[0].Signature))
+
+	digest, err := msg.SigStructureFor(StdEncoding, raw.Signatures[0].Protected, nil)
+	require.NoError(t, err)
+	assert.Equal(t, tbs, digest)
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Sign1JSONMessage is the JSON representation of a signed COSE_Sign1 message for embedding
+// in JSON documents. Byte strings are base64url encoded without padding.
+//
+// The protected headers are kept as the exact signed bytes. Unprotected header labels are
+// JSON object keys with integer labels in decimal, byte string values of the kid, IV,
+// Partial IV and counter signature0 headers are decoded back while other byte string
+// values are returned as base64url text.
+type Sign1JSONMessage struct {
+	Protected   string                 `json:"protected,omitempty"`
+	Unprotected map[string]interface{} `json:"unprotected,omitempty"`
+	Payload     string                 `json:"payload"`
+	Signature   string                 `json:"signature"`
+}
+
+// JSONSign1FromMessage returns the JSON representation of the message. Decoded messages keep
+// the received signature, otherwise the message is signed using StdEncoding.
+func JSONSign1FromMessage(msg *Sign1Message) (*Sign1JSONMessage, error) {
+	if msg == nil {
+		return nil, errors.New("message can not be nil")
+	}
+	raw := msg.raw
+	if raw == nil || msg.signer != nil {
+		if msg.signer == nil {
+			return nil, ErrNoSigner
+		}
+		sm, err := msg.sign(StdEncoding, nil)
+		if err != nil {
+			return nil, err
+		}
+		m := sm.(sign1Message)
+		raw = &m
+	}
+
+	unprotected := raw.Unprotected
+	if msg.raw != nil && msg.signer == nil {
+		unprotected = msg.Headers.unprotected
+	}
+	h := make(map[string]interface{}, len(unprotected))
+	for k, v := range unprotected {
+		label, err := jsonHeaderLabel(k)
+		if err != nil {
+			return nil, err
+		}
+		h[label] = jsonHeaderValue(resolveHeaderValue(v))
+	}
+
+	return &Sign1JSONMessage{
+		Protected:   base64.RawURLEncoding.EncodeToString(raw.Protected),
+		Unprotected: h,
+		Payload:     base64.RawURLEncoding.EncodeToString(raw.Payload),
+		Signature:   base64.RawURLEncoding.EncodeToString(raw.Signature),
+	}, nil
+}
+
+// ToSign1Message returns the message as a decoded Sign1Message, the signature is not verified.
+// Use VerifySignatureOnly to verify the signature of the returned message.
+func (j *Sign1JSONMessage) ToSign1Message() (*Sign1Message, error) {
+	var raw sign1Message
+	var err error
+	if raw.Protected, err = base64.RawURLEncoding.DecodeString(j.Protected); err != nil {
+		return nil, fmt.Errorf("invalid protected headers: %w", err)
+	}
+	var content []byte
+	if content, err = base64.RawURLEncoding.DecodeString(j.Payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	raw.Payload = content
+	if raw.Signature, err = base64.RawURLEncoding.DecodeString(j.Signature); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	raw.Unprotected = make(map[interface{}]interface{}, len(j.Unprotected))
+	for k, v := range j.Unprotected {
+		var label interface{} = k
+		if i, err := strconv.ParseInt(k, 10, 64); err == nil {
+			label = i
+		}
+		value := cborHeaderValue(v)
+		if s, ok := value.(string); ok && isByteStringHeader(label) {
+			if value, err = base64.RawURLEncoding.DecodeString(s); err != nil {
+				return nil, fmt.Errorf("invalid header %v: %w", label, err)
+			}
+		}
+		raw.Unprotected[label] = value
+	}
+
+	msg, err := newSign1Message(StdEncoding, &raw)
+	if err != nil {
+		return nil, err
+	}
+	msg.setDecoded(StdEncoding, &raw, nil, nil)
+	return msg, nil
+}
+
+func jsonHeaderLabel(label interface{}) (string, error) {
+	switch l := label.(type) {
+	case int64:
+		return strconv.FormatInt(l, 10), nil
+	case string:
+		return l, nil
+	}
+	return "", fmt.Errorf("unsupported header label type %T", label)
+}
+
+// jsonHeaderValue converts the header value to JSON compatible types.
+func jsonHeaderValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		return base64.RawURLEncoding.EncodeToString(value)
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = jsonHeaderValue(item)
+		}
+		return items
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = jsonHeaderValue(item)
+		}
+		return m
+	}
+	return v
+}
+
+// cborHeaderValue converts the JSON header value to CBOR header types, integral numbers are integers.
+func cborHeaderValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+			return int64(value)
+		}
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = cborHeaderValue(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(value))
+		for k, item := range value {
+			m[k] = cborHeaderValue(item)
+		}
+		return m
+	}
+	return v
+}
+
+func isByteStringHeader(label interface{}) bool {
+	switch label {
+	case getCommonHeader(HeaderKeyID), getCommonHeader(HeaderIV),
+		getCommonHeader(HeaderPartialIV), getCommonHeader(HeaderCounterSignature0):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign1JSONMessage(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("json payload"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, []byte("kid")))
+	require.NoError(t, msg.Headers.Set("custom", int64(42)))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	decoded, err := StdEncoding.DecodeSign1(b, config)
+	require.NoError(t, err)
+
+	j, err := JSONSign1FromMessage(decoded)
+	require.NoError(t, err)
+	data, err := json.Marshal(j)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"4":"a2lk"`)
+
+	var parsed Sign1JSONMessage
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	converted, err := parsed.ToSign1Message()
+	require.NoError(t, err)
+
+	assert.True(t, Sign1MessageEqual(decoded, converted))
+	assert.NoError(t, converted.VerifySignatureOnly(verifier, nil))
+	kid, err := converted.Headers.Get(HeaderKeyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("kid"), kid)
+	custom, err := converted.Headers.Get("custom")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), custom)
+
+	// Converted message can be signed and decoded again
+	require.NoError(t, converted.SetSigner(signer))
+	b, err = StdEncoding.Encode(converted)
+	require.NoError(t, err)
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.True(t, MessageEqual(decoded, dec))
+
+	// Tampered signature is detected after conversion
+	parsed.Payload = "dGFtcGVyZWQ"
+	tampered, err := parsed.ToSign1Message()
+	require.NoError(t, err)
+	assert.ErrorIs(t, tampered.VerifySignatureOnly(verifier, nil), ErrVerification)
+}
+
+func TestSign1JSONMessage_Errors(t *testing.T) {
+	_, err := JSONSign1FromMessage(NewSign1Message())
+	assert.ErrorIs(t, err, ErrNoSigner)
+
+	_, err = (&Sign1JSONMessage{Signature: "!"}).ToSign1Message()
+	assert.EqualError(t, err, "invalid signature: illegal base64 data at input byte 0")
+}
@@ -4,11 +4,32 @@
 
 package cose
 
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"io"
+)
+
 // Sign1Message represents a COSE_Sign1 message.
 type Sign1Message struct {
-	Headers *Headers
-	signer  *Signer
-	content []byte
+	Headers        *Headers
+	signer         *Signer
+	counterSigner0 *Signer
+	preHash        crypto.Hash
+	content        []byte
+	contentReader  io.Reader
+	consumed       bool
+	external       []byte
+	buildErr       error
+
+	// decoded message state
+	raw         *sign1Message
+	rawExternal []byte
+	detached    bool
+	encoding    *Encoding
+	config      *Config
 }
 
 // NewSign1Message creates a new Sign1Message instance.
@@ -18,6 +39,34 @@ func NewSign1Message() *Sign1Message {
 	}
 }
 
+// CloneSign1Message returns a deep copy of the message that can be modified and encoded
+// independently of the original. Signers are shared as they are not modified by encoding,
+// a content reader is not cloned and the clone has no content if one is set.
+func CloneSign1Message(m *Sign1Message) *Sign1Message {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	if m.Headers != nil {
+		c.Headers = m.Headers.Copy()
+	}
+	c.content, _ = copyHeaderValue(m.content).([]byte)
+	c.contentReader = nil
+	c.consumed = false
+	c.external, _ = copyHeaderValue(m.external).([]byte)
+	return &c
+}
+
+// Sign1MessageFromBytes decodes the COSE_Sign1 message using StdEncoding.
+func Sign1MessageFromBytes(data []byte, config *Config) (*Sign1Message, error) {
+	return StdEncoding.DecodeSign1(data, config)
+}
+
+// ToBytes encodes the message using StdEncoding.
+func (m *Sign1Message) ToBytes() ([]byte, error) {
+	return StdEncoding.Encode(m)
+}
+
 // GetMessageTag returns the COSE_Sign1 message tag.
 func (m *Sign1Message) GetMessageTag() uint64 {
 	return MessageTagSign1
@@ -31,55 +80,330 @@ func (m *Sign1Message) GetContent() []byte {
 // SetContent sets the message content.
 func (m *Sign1Message) SetContent(content []byte) {
 	m.content = content
+	m.contentReader = nil
+}
+
+// SetContentReader sets the reader of the message content, the content is read once
+// when the message is encoded and further encodes fail with ErrPayloadAlreadyConsumed.
+//
+// The encoded payload is a single CBOR byte string so the content is still held in
+// memory while the message is encoded, but not by the message itself.
+func (m *Sign1Message) SetContentReader(r io.Reader) {
+	m.content = nil
+	m.contentReader = r
+	m.consumed = false
+}
+
+// readContent returns the message content, reading it from the content reader if set.
+func (m *Sign1Message) readContent() ([]byte, error) {
+	if m.contentReader == nil {
+		return m.GetContent(), nil
+	}
+	if m.consumed {
+		return nil, ErrPayloadAlreadyConsumed
+	}
+	m.consumed = true
+	content, err := io.ReadAll(m.contentReader)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content, nil
+}
+
+// Validate checks that the critical headers of the message are present.
+func (m *Sign1Message) Validate() error {
+	return validateMessage(m.Headers)
+}
+
+// GetExternalAAD returns the external data used when encoding the message.
+func (m *Sign1Message) GetExternalAAD() []byte {
+	return m.external
+}
+
+// SetExternalAAD sets the external data used when encoding the message,
+// external data given explicitly to EncodeWithExternal takes priority.
+func (m *Sign1Message) SetExternalAAD(external []byte) {
+	m.external = external
+}
+
+// ReverifyWithExternal verifies the signature of the decoded message with the given external data.
+func (m *Sign1Message) ReverifyWithExternal(external []byte) error {
+	if m.raw == nil {
+		return ErrMessageNotDecoded
+	}
+	return m.raw.verify(context.Background(), m.encoding, m.Headers, external, m.config)
+}
+
+// VerifySignatureOnly verifies the signature of the decoded message with the given verifier
+// and external data. The Sig_structure is built from the received protected headers and
+// payload, the message content is not inspected.
+func (m *Sign1Message) VerifySignatureOnly(verifier *Verifier, external []byte) error {
+	if m.raw == nil {
+		return ErrMessageNotDecoded
+	}
+	if verifier == nil {
+		return errors.New("verifier can not be nil")
+	}
+	hash, err := payloadHash(m.Headers)
+	if err != nil {
+		return err
+	}
+	digest, err := m.raw.signedDigest(m.encoding, hash, external)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(digest, m.raw.Signature)
+}
+
+// IsDetached reports whether the message was decoded from a message with a detached payload.
+func (m *Sign1Message) IsDetached() bool {
+	return m.detached
+}
+
+// PreValidate checks that the message can be signed without encoding it and returns the
+// first failing check: the builder error as ErrMessageBuild, ErrNoSigner, ErrNoContent unless
+// the message is detached, ErrUnsupportedAlgorithm and ErrMinKeySize.
+//
+// Options of the encoding such as algorithm policies and WithMinKeySize are checked
+// when the message is encoded.
+func (m *Sign1Message) PreValidate() error {
+	if m.buildErr != nil {
+		return m.buildErr
+	}
+	if m.signer == nil {
+		return ErrNoSigner
+	}
+	if m.content == nil && m.contentReader == nil && !m.IsDetached() {
+		return ErrNoContent
+	}
+	if m.signer.alg == nil || getAlg(m.signer.alg.Name) == nil {
+		return ErrUnsupportedAlgorithm
+	}
+	if size := m.signer.keySize(); size > 0 && size < m.signer.minKeySize {
+		return ErrMinKeySize{m.signer.minKeySize}
+	}
+	return nil
+}
+
+func (m *Sign1Message) setDecoded(e *Encoding, raw *sign1Message, external []byte, config *Config) {
+	m.raw = raw
+	m.rawExternal = external
+	m.encoding = e
+	m.config = config
+}
+
+// WithContent sets the message content and returns the message for chaining.
+func (m *Sign1Message) WithContent(content []byte) *Sign1Message {
+	m.SetContent(content)
+	return m
+}
+
+// WithSigner sets the signer and returns the message for chaining.
+//
+// The builder methods do not return errors, the first error is kept and
+// returned as ErrMessageBuild when the message is encoded.
+func (m *Sign1Message) WithSigner(signer *Signer) *Sign1Message {
+	if signer == nil {
+		m.builderFailed("WithSigner", errors.New("signer can not be nil"))
+		return m
+	}
+	m.builderFailed("WithSigner", m.SetSigner(signer))
+	return m
+}
+
+// WithProtectedHeader sets the protected header and returns the message for chaining.
+func (m *Sign1Message) WithProtectedHeader(key, value interface{}) *Sign1Message {
+	m.builderFailed("WithProtectedHeader", m.Headers.SetProtected(key, value))
+	return m
+}
+
+// WithHeader sets the unprotected header and returns the message for chaining.
+func (m *Sign1Message) WithHeader(key, value interface{}) *Sign1Message {
+	m.builderFailed("WithHeader", m.Headers.Set(key, value))
+	return m
+}
+
+// builderFailed keeps the first error of the builder methods.
+func (m *Sign1Message) builderFailed(step string, err error) {
+	if err != nil && m.buildErr == nil {
+		m.buildErr = ErrMessageBuild{Step: step, Err: err}
+	}
+}
+
+// GetSigner returns the signer or nil if no signer is set, decoded messages have no signer.
+func (m *Sign1Message) GetSigner() *Signer {
+	return m.signer
 }
 
-// SetSigner sets the signer.
-func (m *Sign1Message) SetSigner(signer *Signer) {
+// SetSigner sets the signer, a nil signer removes the signer.
+// Encoding a message without a signer fails with ErrNoSigner.
+func (m *Sign1Message) SetSigner(signer *Signer) error {
 	m.signer = signer
+	return nil
 }
 
 func (m *Sign1Message) sign(e *Encoding, external []byte) (interface{}, error) {
-	sheaders, err := m.signer.GetHeaders()
+	if len(external) == 0 && m.external != nil {
+		external = m.external
+	}
+	msg, err := m.unsigned(e)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := msg.signedDigest(e, m.preHash, external)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Signature, err = e.signDigest(m.signer, digest); err != nil {
+		return nil, err
+	}
+	if m.counterSigner0 != nil {
+		if err = msg.counterSign0(e, m.counterSigner0, external); err != nil {
+			return nil, err
+		}
+	}
+	return *msg, nil
+}
+
+// unsigned returns the message structure without the signature.
+func (m *Sign1Message) unsigned(e *Encoding) (*sign1Message, error) {
+	if m.buildErr != nil {
+		return nil, m.buildErr
+	}
+	if m.signer == nil {
+		return nil, ErrNoSigner
+	}
+	if err := e.checkAlgorithms(signerAlgorithms(m.signer)); err != nil {
+		return nil, err
+	}
+	sheaders, err := m.signer.getHeaders(e.strictHeaders)
+	if err != nil {
+		return nil, err
+	}
+	h, err := e.mergeHeaders(m.Headers, sheaders)
 	if err != nil {
 		return nil, err
 	}
-	h := MergeHeaders(m.Headers, sheaders)
+	if m.preHash != 0 {
+		if err = h.SetProtected(HeaderPayloadHashAlgorithm, payloadHashAlgorithms[m.preHash]); err != nil {
+			return nil, err
+		}
+	} else if v, _ := h.GetProtected(HeaderPayloadHashAlgorithm); v != nil {
+		return nil, errors.New("payload hash alg header is set without UsePreHash")
+	}
 
-	ph, err := e.marshal(h.protected)
+	content, err := m.readContent()
+	if err != nil {
+		return nil, err
+	}
+	if e.payloadDigestLabel != nil {
+		if err = h.SetProtected(e.payloadDigestLabel, payloadDigest(e.payloadDigestHash, content)); err != nil {
+			return nil, err
+		}
+	}
+	var ph []byte
+	if e.payloadDigestLabel != nil {
+		ph, err = e.marshalProtected(h.protected)
+	} else {
+		ph, err = m.signer.cachedProtected(e, m.Headers, m.preHash, func() ([]byte, error) {
+			return e.marshalProtected(h.protected)
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	msg := sign1Message{
+	return &sign1Message{
 		Protected:   ph,
 		Unprotected: h.unprotected,
-		Payload:     m.GetContent(),
+		Payload:     content,
+	}, nil
+}
+
+// relay returns the decoded message structure with the original protected header bytes,
+// payload and signature and the current unprotected headers.
+func (m *Sign1Message) relay(e *Encoding) (*sign1Message, error) {
+	if m.raw == nil {
+		return nil, ErrMessageNotDecoded
 	}
-	digest, err := msg.GetDigest(e, external)
+	h, err := newHeaders(e, m.raw.Protected, nil)
 	if err != nil {
 		return nil, err
 	}
-	if msg.Signature, err = m.signer.Sign(e.rand, digest); err != nil {
+	if !headerMapsEqual(h.protected, m.Headers.protected) || m.contentReader != nil ||
+		!bytes.Equal(m.content, m.raw.Payload) {
+		return nil, ErrSignedContentModified
+	}
+	return &sign1Message{
+		Protected:   m.raw.Protected,
+		Unprotected: m.Headers.unprotected,
+		Payload:     m.raw.Payload,
+		Signature:   m.raw.Signature,
+	}, nil
+}
+
+// estimate returns the message structure with zero signatures of the signer signature size.
+func (m *Sign1Message) estimate(e *Encoding) (interface{}, error) {
+	if m.contentReader != nil {
+		return nil, errors.New("size of content read from a reader can not be estimated")
+	}
+	msg, err := m.unsigned(e)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Signature, err = m.signer.placeholderSignature(); err != nil {
 		return nil, err
 	}
-	return msg, nil
+	if m.counterSigner0 != nil {
+		sig, err := m.counterSigner0.placeholderSignature()
+		if err != nil {
+			return nil, err
+		}
+		msg.Unprotected[getCommonHeader(HeaderCounterSignature0)] = sig
+	}
+	return *msg, nil
 }
 
 type sign1Message struct {
 	_           struct{} `cbor:",toarray"`
 	Protected   []byte
-	Unprotected map[interface{}]interface{}
-	Payload     []byte
+	Unprotected headerMap
+	Payload     payload
 	Signature   []byte
 }
 
 func (m *sign1Message) GetDigest(e *Encoding, external []byte) ([]byte, error) {
-	return e.marshal([]interface{}{
-		"Signature1",
-		m.Protected,
-		external,
-		m.Payload,
-	})
+	s := SigStructure{
+		Context:       ContextSignature1,
+		BodyProtected: m.Protected,
+		ExternalAAD:   external,
+		Payload:       m.Payload,
+	}
+	return s.Marshal(e)
+}
+
+// verify verifies the signature, the Sig_structure is always built from the received
+// protected header bytes as re-encoding decoded headers may not reproduce them.
+func (m *sign1Message) verify(ctx context.Context, e *Encoding, headers *Headers, external []byte, config *Config) error {
+	if err := e.checkAlgorithmHeader(headers); err != nil {
+		return err
+	}
+	hash, err := payloadHash(headers)
+	if err != nil {
+		return err
+	}
+	digest, err := m.signedDigest(e, hash, external)
+	if err != nil {
+		return err
+	}
+	if err = e.verifySignature(ctx, MessageTagSign1, config, headers, digest, m.Signature); err != nil {
+		return err
+	}
+	config.signatureVerified(0, headers)
+	return nil
 }
 
 func newSign1Message(e *Encoding, c *sign1Message) (*Sign1Message, error) {
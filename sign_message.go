@@ -4,11 +4,23 @@
 
 package cose
 
+import (
+	"context"
+	"errors"
+)
+
 // SignMessage represents a COSE_Sign message.
 type SignMessage struct {
-	Headers *Headers
-	signers []*Signer
-	content []byte
+	Headers    *Headers
+	signers    []*Signer
+	signatures []*signMessageSignature
+	content    []byte
+	external   []byte
+
+	// decoded message state
+	raw      *signMessage
+	encoding *Encoding
+	config   *Config
 }
 
 // NewSignMessage creates a new SignMessage instance.
@@ -19,6 +31,36 @@ func NewSignMessage() *SignMessage {
 	}
 }
 
+// CloneSignMessage returns a deep copy of the message that can be modified and encoded
+// independently of the original, signers are shared as they are not modified by encoding.
+func CloneSignMessage(m *SignMessage) *SignMessage {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	if m.Headers != nil {
+		c.Headers = m.Headers.Copy()
+	}
+	c.signers = append(make([]*Signer, 0, len(m.signers)), m.signers...)
+	c.signatures = make([]*signMessageSignature, len(m.signatures))
+	for i, sig := range m.signatures {
+		unprotected, _ := copyHeaderValue(map[interface{}]interface{}(sig.Unprotected)).(map[interface{}]interface{})
+		c.signatures[i] = &signMessageSignature{
+			Protected:   append([]byte{}, sig.Protected...),
+			Unprotected: unprotected,
+			Signature:   append([]byte{}, sig.Signature...),
+		}
+	}
+	c.content, _ = copyHeaderValue(m.content).([]byte)
+	c.external, _ = copyHeaderValue(m.external).([]byte)
+	return &c
+}
+
+// ToBytes encodes the message using StdEncoding.
+func (m *SignMessage) ToBytes() ([]byte, error) {
+	return StdEncoding.Encode(m)
+}
+
 // GetMessageTag returns the COSE_Sign message tag.
 func (m *SignMessage) GetMessageTag() uint64 {
 	return MessageTagSign
@@ -34,6 +76,36 @@ func (m *SignMessage) SetContent(content []byte) {
 	m.content = content
 }
 
+// Validate checks that the critical headers of the message are present.
+func (m *SignMessage) Validate() error {
+	return validateMessage(m.Headers)
+}
+
+// GetExternalAAD returns the external data used when encoding the message.
+func (m *SignMessage) GetExternalAAD() []byte {
+	return m.external
+}
+
+// SetExternalAAD sets the external data used when encoding the message,
+// external data given explicitly to EncodeWithExternal takes priority.
+func (m *SignMessage) SetExternalAAD(external []byte) {
+	m.external = external
+}
+
+// ReverifyWithExternal verifies the signatures of the decoded message with the given external data.
+func (m *SignMessage) ReverifyWithExternal(external []byte) error {
+	if m.raw == nil {
+		return ErrMessageNotDecoded
+	}
+	return m.raw.verify(context.Background(), m.encoding, m.Headers, external, m.config)
+}
+
+func (m *SignMessage) setDecoded(e *Encoding, raw *signMessage, config *Config) {
+	m.raw = raw
+	m.encoding = e
+	m.config = config
+}
+
 // AddSigner adds a signer for the message.
 func (m *SignMessage) AddSigner(signer *Signer) {
 	if signer == nil {
@@ -42,66 +114,226 @@ func (m *SignMessage) AddSigner(signer *Signer) {
 	m.signers = append(m.signers, signer)
 }
 
-func (m *SignMessage) sign(e *Encoding, external []byte) (interface{}, error) {
-	ph, err := e.marshal(m.Headers.protected)
+// AddSignature adds a signature computed elsewhere over the Sig_structure returned by SigStructureFor.
+//
+// The signature is encoded verbatim before the signatures of the message signers.
+func (m *SignMessage) AddSignature(protected []byte, unprotected map[interface{}]interface{}, signature []byte) error {
+	if len(signature) == 0 {
+		return errors.New("signature can not be empty")
+	}
+	if _, err := ParseProtectedHeaders(StdEncoding, protected); err != nil {
+		return err
+	}
+	if unprotected == nil {
+		unprotected = make(map[interface{}]interface{})
+	}
+	m.signatures = append(m.signatures, &signMessageSignature{
+		Protected:   protected,
+		Unprotected: unprotected,
+		Signature:   signature,
+	})
+	return nil
+}
+
+// SigStructureFor returns the Sig_structure to be signed by a signer with the given
+// encoded protected headers and external data.
+func (m *SignMessage) SigStructureFor(e *Encoding, signerProtected, external []byte) ([]byte, error) {
+	ph, err := e.marshalProtected(m.Headers.protected)
 	if err != nil {
 		return nil, err
 	}
-
 	msg := signMessage{
-		Protected:   ph,
-		Unprotected: m.Headers.unprotected,
-		Payload:     m.GetContent(),
-		Signatures:  make([]*signMessageSignature, len(m.signers)),
+		Protected: ph,
+		Payload:   m.GetContent(),
 	}
-	for i, signer := range m.signers {
-		sheaders, err := signer.GetHeaders()
+	return msg.GetDigest(e, signerProtected, external)
+}
+
+// signatureAlgorithms returns the algorithms of added signatures and signers.
+func (m *SignMessage) signatureAlgorithms() ([]Algorithm, error) {
+	algs := make([]Algorithm, 0, len(m.signatures)+len(m.signers))
+	for _, sig := range m.signatures {
+		h, err := ParseProtectedHeaders(StdEncoding, sig.Protected)
+		if err != nil {
+			return nil, err
+		}
+		alg, err := headerAlgorithm(h)
 		if err != nil {
 			return nil, err
 		}
-		ph, err := e.marshal(sheaders.protected)
+		algs = append(algs, alg)
+	}
+	return append(algs, signerAlgorithms(m.signers...)...), nil
+}
+
+func (m *SignMessage) sign(e *Encoding, external []byte) (interface{}, error) {
+	if len(external) == 0 && m.external != nil {
+		external = m.external
+	}
+	msg, err := m.unsigned(e)
+	if err != nil {
+		return nil, err
+	}
+	for _, signer := range m.signers {
+		sig, err := e.signerSignature(signer)
 		if err != nil {
 			return nil, err
 		}
-		digest, err := msg.GetDigest(e, ph, external)
+		digest, err := msg.GetDigest(e, sig.Protected, external)
 		if err != nil {
 			return nil, err
 		}
-		msg.Signatures[i] = &signMessageSignature{
-			Protected:   ph,
-			Unprotected: sheaders.unprotected,
+		if sig.Signature, err = e.signDigest(signer, digest); err != nil {
+			return nil, err
 		}
-		msg.Signatures[i].Signature, err = signer.Sign(e.rand, digest)
+		msg.Signatures = append(msg.Signatures, sig)
+	}
+	return *msg, nil
+}
+
+// unsigned returns the message structure with only the signatures added with AddSignature.
+func (m *SignMessage) unsigned(e *Encoding) (*signMessage, error) {
+	if len(m.signers) == 0 && len(m.signatures) == 0 {
+		return nil, ErrNoSigner
+	}
+	algs, err := m.signatureAlgorithms()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkAlgorithms(algs); err != nil {
+		return nil, err
+	}
+	ph, err := e.marshalProtected(m.Headers.protected)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]*signMessageSignature, 0, len(m.signatures)+len(m.signers))
+	return &signMessage{
+		Protected:   ph,
+		Unprotected: m.Headers.unprotected,
+		Payload:     m.GetContent(),
+		Signatures:  append(signatures, m.signatures...),
+	}, nil
+}
+
+// estimate returns the message structure with zero signatures of the signer signature sizes.
+func (m *SignMessage) estimate(e *Encoding) (interface{}, error) {
+	msg, err := m.unsigned(e)
+	if err != nil {
+		return nil, err
+	}
+	for _, signer := range m.signers {
+		sig, err := e.signerSignature(signer)
 		if err != nil {
 			return nil, err
 		}
+		if sig.Signature, err = signer.placeholderSignature(); err != nil {
+			return nil, err
+		}
+		msg.Signatures = append(msg.Signatures, sig)
+	}
+	return *msg, nil
+}
+
+// signerSignature returns the signature structure of the signer without the signature.
+func (e *Encoding) signerSignature(signer *Signer) (*signMessageSignature, error) {
+	sheaders, err := signer.getHeaders(e.strictHeaders)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := e.marshalProtected(sheaders.protected)
+	if err != nil {
+		return nil, err
 	}
-	return msg, nil
+	return &signMessageSignature{
+		Protected:   ph,
+		Unprotected: sheaders.unprotected,
+	}, nil
 }
 
 type signMessageSignature struct {
 	_           struct{} `cbor:",toarray"`
 	Protected   []byte
-	Unprotected map[interface{}]interface{}
+	Unprotected headerMap
 	Signature   []byte
 }
 
 type signMessage struct {
 	_           struct{} `cbor:",toarray"`
 	Protected   []byte
-	Unprotected map[interface{}]interface{}
-	Payload     []byte
+	Unprotected headerMap
+	Payload     payload
 	Signatures  []*signMessageSignature
 }
 
+// protectedHeaders returns the encoded protected headers of the message and its signatures.
+func (m *signMessage) protectedHeaders() [][]byte {
+	protected := [][]byte{m.Protected}
+	for _, sig := range m.Signatures {
+		if sig != nil {
+			protected = append(protected, sig.Protected)
+		}
+	}
+	return protected
+}
+
 func (m *signMessage) GetDigest(e *Encoding, signerProtected []byte, external []byte) ([]byte, error) {
-	return e.marshal([]interface{}{
-		"Signature",
-		m.Protected,
-		signerProtected,
-		external,
-		m.Payload,
-	})
+	s := SigStructure{
+		Context:       ContextSignature,
+		BodyProtected: m.Protected,
+		SignProtected: signerProtected,
+		ExternalAAD:   external,
+		Payload:       m.Payload,
+	}
+	return s.Marshal(e)
+}
+
+// verify verifies the signatures, the Sig_structure is always built from the received
+// protected header bytes as re-encoding decoded headers may not reproduce them.
+func (m *signMessage) verify(ctx context.Context, e *Encoding, headers *Headers, external []byte, config *Config) error {
+	if len(m.Signatures) == 0 {
+		return ErrVerification
+	}
+	requireAll := config.requireAllSignatures()
+	var errs []SignatureError
+	for i, sig := range m.Signatures {
+		sheaders, err := m.verifySignature(ctx, e, headers, sig, external, config)
+		if err == nil {
+			config.signatureVerified(i, MergeHeaders(headers, sheaders))
+			if !requireAll {
+				return nil
+			}
+			continue
+		}
+		errs = append(errs, SignatureError{Index: i, Err: err, SignerHeaders: sheaders})
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &MultiVerificationError{Errors: errs}
+}
+
+// verifySignature verifies a single signature returning the decoded signature headers.
+func (m *signMessage) verifySignature(ctx context.Context, e *Encoding, headers *Headers, sig *signMessageSignature, external []byte, config *Config) (*Headers, error) {
+	if sig == nil {
+		return nil, ErrVerification
+	}
+	digest, err := m.GetDigest(e, sig.Protected, external)
+	if err != nil {
+		return nil, err
+	}
+
+	sheaders, err := newHeaders(e, sig.Protected, sig.Unprotected)
+	if err != nil {
+		return nil, err
+	}
+
+	h := MergeHeaders(headers, sheaders)
+	if err = e.checkAlgorithmHeader(h); err != nil {
+		return sheaders, err
+	}
+	return sheaders, e.verifySignature(ctx, MessageTagSign, config, h, digest, sig.Signature)
 }
 
 func newSignMessage(e *Encoding, c *signMessage) (*SignMessage, error) {
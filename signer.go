@@ -9,12 +9,14 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
-	"crypto/rsa"
 	"crypto/subtle"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/big"
+	"sync/atomic"
 
 	// Required hashing algorithms
 	_ "crypto/sha256"
@@ -24,48 +26,185 @@ import (
 type Signer struct {
 	Headers    *Headers
 	privateKey crypto.PrivateKey
-	alg        *algorithm
+	// publicKey is the public key of private keys implementing only crypto.Signer
+	publicKey     crypto.PublicKey
+	alg           *algorithm
+	omitAlgorithm bool
+	pssSaltLength int
+	minKeySize    int
+	deterministic bool
+	// protected holds the *protectedCache of the last signed message
+	protected atomic.Value
+}
+
+// SignerOption represents an option for creating a signer.
+type SignerOption func(*Signer) error
+
+// WithoutAlgorithmHeader disables adding the alg protected header to the signature headers,
+// for profiles where the algorithm is fixed by the application.
+func WithoutAlgorithmHeader() SignerOption {
+	return func(s *Signer) error {
+		s.omitAlgorithm = true
+		return nil
+	}
+}
+
+// pssSaltLengthEqualsHash matches rsa.PSSSaltLengthEqualsHash
+const pssSaltLengthEqualsHash = -1
+
+// WithPSSSaltLength sets the RSASSA-PSS salt length, rsa.PSSSaltLengthEqualsHash by default,
+// rsa.PSSSaltLengthAuto for the maximum salt length or an explicit length in bytes.
+//
+// COSE requires the salt length to equal the hash length, other salt lengths are only
+// for relying parties expecting them and are verified by verifiers with WithLenientPSSSaltLength.
+func WithPSSSaltLength(n int) SignerOption {
+	return func(s *Signer) error {
+		modulus := rsaModulus(s.key())
+		if modulus == nil || s.alg.Type != algorithmTypeKeyRSA {
+			return errors.New("PSS salt length requires an RSA signer")
+		}
+		if n < pssSaltLengthEqualsHash {
+			return fmt.Errorf("invalid PSS salt length %d", n)
+		}
+		// Encoded message length of the key less the hash and two bytes of padding
+		if max := (modulus.BitLen()-1+7)/8 - s.alg.Hash.Size() - 2; n > max {
+			return fmt.Errorf("PSS salt length %d exceeds maximum %d for the key and hash", n, max)
+		}
+		s.pssSaltLength = n
+		return nil
+	}
+}
+
+// WithMinRSAKeySize overrides the minimum RSA key size in bits of the algorithm for the signer.
+func WithMinRSAKeySize(bits int) SignerOption {
+	return func(s *Signer) error {
+		if s.alg.Type != algorithmTypeKeyRSA {
+			return errors.New("minimum RSA key size requires an RSA signer")
+		}
+		if bits <= 0 {
+			return fmt.Errorf("invalid minimum RSA key size %d", bits)
+		}
+		s.minKeySize = bits
+		return nil
+	}
+}
+
+// DisableMinKeySize disables the minimum key size of the algorithm for the signer.
+//
+// Keys smaller than the algorithm minimum are INSECURE, see NewSignerInsecure.
+func DisableMinKeySize() SignerOption {
+	return func(s *Signer) error {
+		s.minKeySize = 0
+		return nil
+	}
 }
 
 // NewSigner creates a new signer with a private key and algorithm.
-func NewSigner(alg Algorithm, key crypto.PrivateKey) (*Signer, error) {
+func NewSigner(alg Algorithm, key crypto.PrivateKey, opts ...SignerOption) (*Signer, error) {
+	return newSigner(alg, key, true, opts)
+}
+
+// MustNewSigner is like NewSigner but panics if the signer can not be created,
+// it simplifies initialization of signers with keys and options known to be valid.
+func MustNewSigner(alg Algorithm, key crypto.PrivateKey, opts ...SignerOption) *Signer {
+	s, err := NewSigner(alg, key, opts...)
+	if err != nil {
+		panic("cose: NewSigner: " + err.Error())
+	}
+	return s
+}
+
+// NewSignerInsecure creates a new signer with a private key and algorithm
+// without enforcing the minimum key size of the algorithm.
+//
+// Keys smaller than the algorithm minimum are INSECURE and should only be used in test
+// environments or with legacy devices that can not be upgraded. Encoding messages with
+// such signers also requires an encoding created with the WithMinKeySize option, the
+// WithMinRSAKeySize and DisableMinKeySize options lower the minimum for the signer instead.
+func NewSignerInsecure(alg Algorithm, key crypto.PrivateKey, opts ...SignerOption) (*Signer, error) {
+	return newSigner(alg, key, false, opts)
+}
+
+// NewRFC6979Signer creates a new ECDSA signer generating signature nonces as described in RFC 6979,
+// signatures are reproducible for the same key and message regardless of the random source.
+func NewRFC6979Signer(alg Algorithm, key *ecdsa.PrivateKey, opts ...SignerOption) (*Signer, error) {
+	if key == nil {
+		return nil, errors.New("key can not be nil")
+	}
+	s, err := newSigner(alg, key, true, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.deterministic = true
+	return s, nil
+}
+
+func newSigner(alg Algorithm, key crypto.PrivateKey, checkKeySize bool, opts []SignerOption) (*Signer, error) {
 	if key == nil {
 		return nil, errors.New("key can not be nil")
 	}
 
 	a := getAlg(string(alg))
-	if a == nil || a.Type == algorithmTypeUnsupported {
+	if a == nil {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if !a.IsSigningAlgorithm() {
+		return nil, ErrAlgorithmNotForSigning
+	}
+	if !a.Implemented {
 		return nil, ErrUnsupportedAlgorithm
 	}
 
-	switch k := key.(type) {
-	case *rsa.PrivateKey:
-		if a.Type != algorithmTypeKeyRSA {
-			return nil, ErrAlgorithmNotMatchKey
+	// Keys of hardware tokens and key stores are identified by their public key
+	var public crypto.PublicKey
+	if signer, ok := key.(crypto.Signer); ok && !isSoftwareKey(key) {
+		public = signer.Public()
+		if public == nil {
+			return nil, ErrUnsupportedKeyType
 		}
-		if a.MinKeySize > 0 && a.MinKeySize > k.Size()*8 {
-			return nil, ErrMinKeySize{a.MinKeySize}
-		}
-	case *ecdsa.PrivateKey:
+	}
+
+	s := &Signer{
+		Headers:       NewHeaders(),
+		privateKey:    key,
+		publicKey:     public,
+		alg:           a,
+		pssSaltLength: pssSaltLengthEqualsHash,
+		minKeySize:    a.MinKeySize,
+	}
+	switch curve := keyCurve(s.key()); {
+	case curve != nil:
 		if a.Type != algorithmTypeKeyECDSA {
 			return nil, ErrAlgorithmNotMatchKey
 		}
-		if a.KeyEllipticCurve.Params().BitSize != k.Curve.Params().BitSize {
+		if a.KeyEllipticCurve.Params().BitSize != curve.Params().BitSize {
 			return nil, ErrInvalidEllipticCurve
 		}
-	case ed25519.PrivateKey:
+	case isEd25519Key(s.key()):
 		if a.Type != algorithmTypeKeyED25519 {
 			return nil, ErrAlgorithmNotMatchKey
 		}
 	default:
-		return nil, ErrUnsupportedKeyType
+		if rsaModulus(s.key()) == nil {
+			return nil, ErrUnsupportedKeyType
+		}
+		if a.Type != algorithmTypeKeyRSA {
+			return nil, ErrAlgorithmNotMatchKey
+		}
 	}
 
-	return &Signer{
-		Headers:    NewHeaders(),
-		privateKey: key,
-		alg:        a,
-	}, nil
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if size := s.keySize(); size > 0 && size < s.minKeySize {
+		if checkKeySize {
+			return nil, ErrMinKeySize{s.minKeySize}
+		}
+		log.Printf("cose: WARNING: insecure %d bit key used for %s signer, minimum key size is %d", size, a.Name, s.minKeySize)
+	}
+	return s, nil
 }
 
 // GetHash returns the hash algorithm of the signer.
@@ -78,31 +217,168 @@ func (s *Signer) GetPrivateKey() crypto.PrivateKey {
 	return s.privateKey
 }
 
+// key returns the private key or the public key of private keys implementing only crypto.Signer,
+// the returned key identifies the key type and size.
+func (s *Signer) key() interface{} {
+	if s.publicKey != nil {
+		return s.publicKey
+	}
+	return s.privateKey
+}
+
+// isSoftwareKey reports whether the private key is an ECDSA, Ed25519 or RSA key of the standard library.
+func isSoftwareKey(key crypto.PrivateKey) bool {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	}
+	return rsaModulus(key) != nil
+}
+
+// keyCurve returns the curve of ECDSA private and public keys or nil for other keys.
+func keyCurve(key interface{}) elliptic.Curve {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k.Curve
+	case *ecdsa.PublicKey:
+		return k.Curve
+	}
+	return nil
+}
+
+// isEd25519Key reports whether the key is an Ed25519 private or public key.
+func isEd25519Key(key interface{}) bool {
+	switch key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return true
+	}
+	return false
+}
+
+// GetPrivateKeyAlgorithm returns the type of the private key, "RSA", "ECDSA" or "EdDSA",
+// and its size in bits, the RSA modulus or curve size, independent of the signer algorithm.
+func (s *Signer) GetPrivateKeyAlgorithm() (keyType string, bitSize int, err error) {
+	if curve := keyCurve(s.key()); curve != nil {
+		return "ECDSA", curve.Params().BitSize, nil
+	}
+	if isEd25519Key(s.key()) {
+		return "EdDSA", 8 * ed25519.PublicKeySize, nil
+	}
+	if n := rsaModulus(s.key()); n != nil {
+		return "RSA", n.BitLen(), nil
+	}
+	return "", 0, ErrUnsupportedKeyType
+}
+
 // GetHeader returns the headers for message signature.
 func (s *Signer) GetHeaders() (*Headers, error) {
+	return s.getHeaders(false)
+}
+
+// getHeaders returns the headers for message signature, failing on conflicting values if strict is set.
+func (s *Signer) getHeaders(strict bool) (*Headers, error) {
 	h := NewHeaders()
-	if err := h.SetProtected(HeaderAlgorithm, s.alg.Value); err != nil {
-		return nil, err
+	if !s.omitAlgorithm {
+		if err := h.SetProtected(HeaderAlgorithm, s.alg.Value); err != nil {
+			return nil, err
+		}
 	}
 
+	if strict {
+		return MergeHeadersStrict(s.Headers, h)
+	}
 	return MergeHeaders(s.Headers, h), nil
 }
 
+// protectedKey identifies the protected headers of a message signed by the signer,
+// headers are identified by their pointer and generation.
+type protectedKey struct {
+	encoding      *Encoding
+	headers       *Headers
+	headersGen    uint64
+	signerHeaders *Headers
+	signerGen     uint64
+	preHash       crypto.Hash
+}
+
+// protectedCache is the encoded protected headers of a message signed by the signer.
+type protectedCache struct {
+	key     protectedKey
+	encoded []byte
+}
+
+// cachedProtected returns the encoded protected headers of the message headers merged with
+// the signer headers, encode is called only if the headers changed since the last message.
+// Messages sharing the same Headers instance reuse the encoding.
+func (s *Signer) cachedProtected(e *Encoding, headers *Headers, preHash crypto.Hash, encode func() ([]byte, error)) ([]byte, error) {
+	key := protectedKey{
+		encoding:      e,
+		headers:       headers,
+		headersGen:    headers.generation(),
+		signerHeaders: s.Headers,
+		signerGen:     s.Headers.generation(),
+		preHash:       preHash,
+	}
+	if c, ok := s.protected.Load().(*protectedCache); ok && c.key == key {
+		return c.encoded, nil
+	}
+	encoded, err := encode()
+	if err != nil {
+		return nil, err
+	}
+	s.protected.Store(&protectedCache{key: key, encoded: encoded})
+	return encoded, nil
+}
+
+// keySize returns the RSA key size in bits or 0 for other key types.
+func (s *Signer) keySize() int {
+	if n := rsaModulus(s.key()); n != nil {
+		return (n.BitLen() + 7) / 8 * 8
+	}
+	return 0
+}
+
+// placeholderSignature returns a zero signature of the length of the signatures created by the signer.
+func (s *Signer) placeholderSignature() ([]byte, error) {
+	if curve := keyCurve(s.key()); curve != nil {
+		return make([]byte, 2*curveByteSize(curve)), nil
+	}
+	if isEd25519Key(s.key()) {
+		return make([]byte, ed25519.SignatureSize), nil
+	}
+	if size := s.keySize(); size > 0 {
+		return make([]byte, size/8), nil
+	}
+	return nil, ErrUnsupportedKeyType
+}
+
 // ToVerifier returns the public key verifier for the signer.
 func (s *Signer) ToVerifier() (*Verifier, error) {
-	switch k := s.GetPrivateKey().(type) {
-	case *rsa.PrivateKey:
-		return NewVerifier(Algorithm(s.alg.Name), k.Public())
-	case *ecdsa.PrivateKey:
-		return NewVerifier(Algorithm(s.alg.Name), k.Public())
-	case ed25519.PrivateKey:
-		return NewVerifier(Algorithm(s.alg.Name), k.Public())
+	k, ok := s.GetPrivateKey().(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
 	}
-	return nil, ErrUnsupportedKeyType
+	var opts []VerifierOption
+	if s.pssSaltLength != pssSaltLengthEqualsHash {
+		opts = append(opts, WithLenientPSSSaltLength())
+	}
+	// Verifier allows the key size allowed for the signer by WithMinRSAKeySize or DisableMinKeySize
+	if s.minKeySize < s.alg.MinKeySize {
+		opts = append(opts, func(v *Verifier) error {
+			v.minKeySize = s.minKeySize
+			return nil
+		})
+	}
+	return NewVerifier(Algorithm(s.alg.Name), k.Public(), opts...)
 }
 
 // Sign signs the message with the private key using the algorithm.
 func (s *Signer) Sign(rand io.Reader, digest []byte) ([]byte, error) {
+	return s.sign(rand, digest, s.deterministic)
+}
+
+// sign signs the message, ECDSA signatures use RFC 6979 nonce generation if deterministic is set.
+func (s *Signer) sign(rand io.Reader, digest []byte, deterministic bool) ([]byte, error) {
 	hash := s.GetHash()
 	// calculate the hash of the message, if the algorithm requires it
 	if hash > 0 {
@@ -116,13 +392,14 @@ func (s *Signer) Sign(rand io.Reader, digest []byte) ([]byte, error) {
 	}
 
 	switch key := s.GetPrivateKey().(type) {
-	case *rsa.PrivateKey:
-		return rsa.SignPSS(rand, key, hash, digest, &rsa.PSSOptions{
-			SaltLength: rsa.PSSSaltLengthEqualsHash,
-			Hash:       hash,
-		})
 	case *ecdsa.PrivateKey:
-		r, s, err := ecdsa.Sign(rand, key, digest)
+		var r, s *big.Int
+		var err error
+		if deterministic {
+			r, s, err = signRFC6979(key, hash, digest)
+		} else {
+			r, s, err = ecdsa.Sign(rand, key, digest)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -132,19 +409,48 @@ func (s *Signer) Sign(rand io.Reader, digest []byte) ([]byte, error) {
 			return nil, fmt.Errorf("s %d and r %d does not approximately match key D %d", sBits, rBits, dBits)
 		}
 
-		n := curveByteSize(key.Curve)
-		sig := make([]byte, 0, n*2)
-		sig = append(sig, i2osp(r, n)...)
-		sig = append(sig, i2osp(s, n)...)
-
-		return sig, nil
+		return ecdsaSignature(r, s, curveByteSize(key.Curve))
 	case ed25519.PrivateKey:
+		if hash > 0 {
+			return signEd25519ph(key, digest)
+		}
 		return key.Sign(rand, digest, crypto.Hash(0))
+	case crypto.Signer:
+		if s.publicKey == nil {
+			return signRSA(rand, key, hash, digest, s.pssSaltLength)
+		}
+		return s.signOpaque(rand, key, hash, digest, deterministic)
 	default:
 		return nil, ErrUnsupportedKeyType
 	}
 }
 
+// signOpaque signs the hashed digest with a private key implementing only crypto.Signer,
+// such as keys of PKCS #11 tokens, ECDSA signatures are converted from ASN.1 to the COSE format.
+func (s *Signer) signOpaque(rand io.Reader, key crypto.Signer, hash crypto.Hash, digest []byte, deterministic bool) ([]byte, error) {
+	if curve := keyCurve(s.publicKey); curve != nil {
+		if deterministic {
+			return nil, errors.New("deterministic ECDSA signatures require an *ecdsa.PrivateKey")
+		}
+		der, err := key.Sign(rand, digest, hash)
+		if err != nil {
+			return nil, err
+		}
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) > 0 {
+			return nil, errors.New("invalid ASN.1 ECDSA signature")
+		}
+		return ecdsaSignature(sig.R, sig.S, curveByteSize(curve))
+	}
+	if isEd25519Key(s.publicKey) {
+		if hash > 0 {
+			return nil, ErrUnsupportedKeyType
+		}
+		return key.Sign(rand, digest, crypto.Hash(0))
+	}
+	return signRSA(rand, key, hash, digest, s.pssSaltLength)
+}
+
 // curveByteSize returns the curve key size in bytes with padding
 func curveByteSize(curve elliptic.Curve) int {
 	bitSize := curve.Params().BitSize
@@ -158,22 +464,39 @@ func curveByteSize(curve elliptic.Curve) int {
 
 // i2osp "Integer-to-Octet-String" converts a nonnegative integer to
 // an octet string of a specified length
-func i2osp(b *big.Int, n int) []byte {
-	var (
-		octetString     = b.Bytes()
-		octetStringSize = len(octetString)
-		result          = make([]byte, n)
-	)
-	if !(b.Sign() == 0 || b.Sign() == 1) {
-		panic("I2OSP error: integer must be zero or positive")
-	}
-	if n == 0 || octetStringSize > n {
-		panic("I2OSP error: integer too large")
-	}
-
-	subtle.ConstantTimeCopy(1, result[:n-octetStringSize], result[:n-octetStringSize])
-	subtle.ConstantTimeCopy(1, result[n-octetStringSize:], octetString)
-	return result
+func i2osp(b *big.Int, n int) ([]byte, error) {
+	if b.Sign() < 0 {
+		return nil, errors.New("I2OSP error: integer must be zero or positive")
+	}
+	octetString := b.Bytes()
+	if n == 0 || len(octetString) > n {
+		return nil, errors.New("I2OSP error: integer too large")
+	}
+
+	result := make([]byte, n)
+	subtle.ConstantTimeCopy(1, result[n-len(octetString):], octetString)
+	return result, nil
+}
+
+// ecdsaSignature encodes the ECDSA signature r and s values as a fixed size COSE signature.
+func ecdsaSignature(r, s *big.Int, n int) ([]byte, error) {
+	rb, err := i2osp(r, n)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := i2osp(s, n)
+	if err != nil {
+		return nil, err
+	}
+	return append(rb, sb...), nil
+}
+
+// ecdsaSignatureValues decodes the r and s values of the fixed size COSE ECDSA signature.
+func ecdsaSignatureValues(sig []byte, n int) (*big.Int, *big.Int, error) {
+	if n == 0 || len(sig) != 2*n {
+		return nil, nil, ErrVerification
+	}
+	return new(big.Int).SetBytes(sig[:n]), new(big.Int).SetBytes(sig[n:]), nil
 }
 
 // approxEquals returns a bool of whether x and y are equal within delta 1
@@ -5,7 +5,15 @@
 package cose
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,6 +35,7 @@ func TestSigner_MinRSAKeyLength(t *testing.T) {
 }
 
 func TestSigner_PS512InvalidKey(t *testing.T) {
+	requireRSA(t)
 	signer, err := NewSigner(AlgorithmPS512, getPrivateKey(t, "ecdsa256"))
 	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
 	assert.Nil(t, signer)
@@ -93,6 +102,7 @@ func TestSigner_SignES512(t *testing.T) {
 }
 
 func TestSigner_EdDSAInvalidKey(t *testing.T) {
+	requireRSA(t)
 	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "ed25519"))
 	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
 	assert.Nil(t, signer)
@@ -148,3 +158,331 @@ func TestSigner_GetHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_NewSignerInsecure(t *testing.T) {
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa1024"))
+	assert.ErrorIs(t, err, ErrMinKeySize{2048})
+	assert.Nil(t, signer)
+
+	signer, err = NewSignerInsecure(AlgorithmPS256, getPrivateKey(t, "rsa1024"))
+	require.NoError(t, err)
+	require.NotNil(t, signer)
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	_, err = StdEncoding.Encode(msg)
+	assert.ErrorIs(t, err, ErrMinKeySize{2048})
+
+	enc, err := NewEncoding(WithMinKeySize(1024))
+	require.NoError(t, err)
+	b, err := enc.Encode(msg)
+	require.NoError(t, err)
+	assert.NotEmpty(t, b)
+
+	enc, err = NewEncoding(WithMinKeySize(4096))
+	require.NoError(t, err)
+	signer, err = NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"))
+	require.NoError(t, err)
+	require.NoError(t, msg.SetSigner(signer))
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrMinKeySize{4096})
+}
+
+func TestSigner_NewSignerNotSigningAlgorithm(t *testing.T) {
+	key := getPrivateKey(t, "ecdsa256")
+	for _, alg := range []Algorithm{AlgorithmA128GCM, Algorithm("HMAC 256/256"), AlgorithmA128KW} {
+		_, err := NewSigner(alg, key)
+		assert.ErrorIs(t, err, ErrAlgorithmNotForSigning, alg)
+	}
+
+	_, err := NewSigner(Algorithm("unknown"), key)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	_, err = NewVerifier(AlgorithmA128GCM, getPublicKey(t, "ecdsa256"))
+	assert.ErrorIs(t, err, ErrAlgorithmNotForSigning)
+
+	// Signature algorithms not implemented by the library are unsupported
+	for _, alg := range []Algorithm{"RS256", "ES256K"} {
+		_, err = NewSigner(alg, key)
+		assert.ErrorIs(t, err, ErrUnsupportedAlgorithm, alg)
+		_, err = NewVerifier(alg, getPublicKey(t, "ecdsa256"))
+		assert.ErrorIs(t, err, ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+func TestSigner_I2OSP(t *testing.T) {
+	b, err := i2osp(big.NewInt(0x0102), 4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 1, 2}, b)
+
+	_, err = i2osp(big.NewInt(-1), 4)
+	assert.Error(t, err)
+	_, err = i2osp(big.NewInt(0x010203), 2)
+	assert.Error(t, err)
+	_, err = i2osp(big.NewInt(0), 0)
+	assert.Error(t, err)
+
+	_, err = ecdsaSignature(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 256), 32)
+	assert.Error(t, err)
+}
+
+func TestSigner_WithPSSSaltLength(t *testing.T) {
+	data := []byte("test")
+	key := getPrivateKey(t, "rsa2048").(*rsa.PrivateKey)
+	strict, err := NewVerifier(AlgorithmPS256, key.Public())
+	require.NoError(t, err)
+	lenient, err := NewVerifier(AlgorithmPS256, key.Public(), WithLenientPSSSaltLength())
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name       string
+		saltLength int
+	}{
+		{name: "EqualsHash", saltLength: rsa.PSSSaltLengthEqualsHash},
+		{name: "Auto", saltLength: rsa.PSSSaltLengthAuto},
+		{name: "Explicit", saltLength: 20},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(AlgorithmPS256, key, WithPSSSaltLength(tt.saltLength))
+			require.NoError(t, err)
+			sig, err := signer.Sign(rand.Reader, data)
+			require.NoError(t, err)
+
+			assert.NoError(t, lenient.Verify(data, sig))
+			if tt.saltLength == rsa.PSSSaltLengthEqualsHash {
+				assert.NoError(t, strict.Verify(data, sig))
+			} else {
+				assert.ErrorIs(t, strict.Verify(data, sig), ErrVerification)
+			}
+
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			assert.NoError(t, verifier.Verify(data, sig))
+
+			digest := sha256.Sum256(data)
+			assert.NoError(t, rsa.VerifyPSS(&key.PublicKey, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{
+				SaltLength: tt.saltLength,
+			}))
+		})
+	}
+
+	_, err = NewSigner(AlgorithmPS256, key, WithPSSSaltLength(-2))
+	assert.Error(t, err)
+	_, err = NewSigner(AlgorithmPS256, key, WithPSSSaltLength(256-32-1))
+	assert.Error(t, err)
+	_, err = NewSigner(AlgorithmPS256, key, WithPSSSaltLength(256-32-2))
+	assert.NoError(t, err)
+	_, err = NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"), WithPSSSaltLength(32))
+	assert.Error(t, err)
+	_, err = NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"), WithLenientPSSSaltLength())
+	assert.Error(t, err)
+}
+
+func TestSigner_GetPrivateKeyAlgorithm(t *testing.T) {
+	tests := []struct {
+		key      string
+		alg      Algorithm
+		keyType  string
+		bitSize  int
+		insecure bool
+	}{
+		{key: "rsa2048", alg: AlgorithmPS256, keyType: "RSA", bitSize: 2048},
+		{key: "rsa1024", alg: AlgorithmPS256, keyType: "RSA", bitSize: 1024, insecure: true},
+		{key: "ecdsa256", alg: AlgorithmES256, keyType: "ECDSA", bitSize: 256},
+		{key: "ecdsa384", alg: AlgorithmES384, keyType: "ECDSA", bitSize: 384},
+		{key: "ecdsa521", alg: AlgorithmES512, keyType: "ECDSA", bitSize: 521},
+		{key: "ed25519", alg: AlgorithmEdDSA, keyType: "EdDSA", bitSize: 256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var signer *Signer
+			var err error
+			if tt.insecure {
+				signer, err = NewSignerInsecure(tt.alg, getPrivateKey(t, tt.key))
+			} else {
+				signer, err = NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			}
+			require.NoError(t, err)
+
+			keyType, bitSize, err := signer.GetPrivateKeyAlgorithm()
+			require.NoError(t, err)
+			assert.Equal(t, tt.keyType, keyType)
+			assert.Equal(t, tt.bitSize, bitSize)
+
+			verifier := &Verifier{publicKey: getPublicKey(t, tt.key), alg: getAlg(string(tt.alg))}
+			keyType, bitSize, err = verifier.GetPublicKeyInfo()
+			require.NoError(t, err)
+			assert.Equal(t, tt.keyType, keyType)
+			assert.Equal(t, tt.bitSize, bitSize)
+		})
+	}
+
+	_, _, err := (&Signer{}).GetPrivateKeyAlgorithm()
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+	_, _, err = (&Verifier{}).GetPublicKeyInfo()
+	assert.ErrorIs(t, err, ErrUnsupportedKeyType)
+}
+
+func TestSigner_WithMinRSAKeySize(t *testing.T) {
+	key := getPrivateKey(t, "rsa1024")
+
+	signer, err := NewSigner(AlgorithmPS256, key, WithMinRSAKeySize(1024))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	_, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	signer, err = NewSigner(AlgorithmPS256, key, DisableMinKeySize())
+	require.NoError(t, err)
+	require.NoError(t, msg.SetSigner(signer))
+	_, err = StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	_, err = NewSigner(AlgorithmPS256, key, WithMinRSAKeySize(1536))
+	assert.ErrorIs(t, err, ErrMinKeySize{1536})
+	_, err = NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"), WithMinRSAKeySize(3072))
+	assert.ErrorIs(t, err, ErrMinKeySize{3072})
+	_, err = NewSigner(AlgorithmPS256, key, WithMinRSAKeySize(0))
+	assert.Error(t, err)
+	_, err = NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"), WithMinRSAKeySize(1024))
+	assert.Error(t, err)
+
+	// Encoding minimum key size takes priority over the signer
+	enc, err := NewEncoding(WithMinKeySize(2048))
+	require.NoError(t, err)
+	_, err = enc.Encode(msg)
+	assert.ErrorIs(t, err, ErrMinKeySize{2048})
+}
+
+func TestSigner_NewRFC6979Signer(t *testing.T) {
+	// Key and signature of message "sample" with SHA-256 from RFC 6979 appendix A.2.5
+	d, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d.Bytes())
+
+	signer, err := NewRFC6979Signer(AlgorithmES256, key)
+	require.NoError(t, err)
+	signature, err := signer.Sign(rand.Reader, []byte("sample"))
+	require.NoError(t, err)
+	assert.Equal(t, "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716"+
+		"f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8", fmt.Sprintf("%x", signature))
+
+	verifier, err := signer.ToVerifier()
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("sample"), signature))
+
+	_, err = NewRFC6979Signer(AlgorithmEdDSA, key)
+	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
+	_, err = NewRFC6979Signer(AlgorithmES256, nil)
+	assert.Error(t, err)
+}
+
+func TestSigner_MustNewSigner(t *testing.T) {
+	assert.NotNil(t, MustNewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256")))
+	assert.PanicsWithValue(t, "cose: NewSigner: "+ErrAlgorithmNotMatchKey.Error(), func() {
+		MustNewSigner(AlgorithmES256, getPrivateKey(t, "rsa2048"))
+	})
+}
+
+func TestSigner_CachedProtected(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: staticVerifier(t, signer)}
+	msg := NewSign1Message().WithContent([]byte("content")).WithSigner(signer)
+
+	decode := func(label interface{}) interface{} {
+		data, err := StdEncoding.Encode(msg)
+		require.NoError(t, err)
+		decoded, err := StdEncoding.DecodeSign1(data, config)
+		require.NoError(t, err)
+		value, err := decoded.Headers.GetProtected(label)
+		require.NoError(t, err)
+		return value
+	}
+
+	assert.Nil(t, decode(HeaderKeyID))
+	cached := signer.protected.Load().(*protectedCache)
+	assert.Nil(t, decode(HeaderKeyID))
+	assert.Same(t, cached, signer.protected.Load().(*protectedCache))
+
+	// Modifying the signer or message headers invalidates the cached encoding
+	require.NoError(t, signer.Headers.SetProtected(HeaderKeyID, []byte("signer")))
+	assert.Equal(t, []byte("signer"), decode(HeaderKeyID))
+	require.NoError(t, msg.Headers.SetProtected(HeaderContentType, "text/plain"))
+	assert.Equal(t, "text/plain", decode(HeaderContentType))
+	msg.Headers.Delete(HeaderContentType)
+	assert.Nil(t, decode(HeaderContentType))
+	signer.Headers = NewHeaders()
+	assert.Nil(t, decode(HeaderKeyID))
+
+	// Pre-hashed messages use a different protected encoding
+	require.NoError(t, msg.UsePreHash(crypto.SHA256))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	_, err = StdEncoding.DecodeSign1(data, config)
+	assert.NoError(t, err)
+}
+
+// opaqueSigner implements only crypto.Signer like keys of PKCS #11 tokens.
+type opaqueSigner struct {
+	key crypto.Signer
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSigner_CryptoSigner(t *testing.T) {
+	for _, tt := range []struct {
+		alg Algorithm
+		key string
+	}{
+		{AlgorithmES256, "ecdsa256"},
+		{AlgorithmES512, "ecdsa521"},
+		{AlgorithmEdDSA, "ed25519"},
+		{AlgorithmPS256, "rsa2048"},
+	} {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			key := opaqueSigner{getPrivateKey(t, tt.key).(crypto.Signer)}
+			signer, err := NewSigner(tt.alg, key)
+			require.NoError(t, err)
+			assert.Equal(t, key, signer.GetPrivateKey())
+
+			msg := NewSign1Message().WithContent([]byte("content")).WithSigner(signer)
+			size, err := StdEncoding.EstimateEncodedSize(msg)
+			require.NoError(t, err)
+			data, err := StdEncoding.Encode(msg)
+			require.NoError(t, err)
+			assert.Len(t, data, size)
+
+			// The signature verifies with the verifier of the wrapped key
+			wrapped, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			_, err = StdEncoding.Decode(data, &Config{GetVerifiers: staticVerifier(t, wrapped)})
+			assert.NoError(t, err)
+		})
+	}
+
+	key := opaqueSigner{getPrivateKey(t, "ecdsa256").(crypto.Signer)}
+	_, err := NewSigner(AlgorithmEdDSA, key)
+	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
+	_, err = NewSigner(AlgorithmES384, key)
+	assert.ErrorIs(t, err, ErrInvalidEllipticCurve)
+
+	// RFC 6979 nonces can not be generated without the private key
+	signer, err := NewSigner(AlgorithmES256, key)
+	require.NoError(t, err)
+	enc, err := NewEncoding(WithDeterministicSigning([]byte("seed")))
+	require.NoError(t, err)
+	_, err = enc.Encode(NewSign1Message().WithContent([]byte("content")).WithSigner(signer))
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "update deterministic test vectors in testdata")
+
+var vectorAlgorithms = []struct {
+	name string
+	alg  Algorithm
+	key  string
+}{
+	{name: "PS256", alg: AlgorithmPS256, key: "rsa2048"},
+	{name: "PS384", alg: AlgorithmPS384, key: "rsa2048"},
+	{name: "PS512", alg: AlgorithmPS512, key: "rsa2048"},
+	{name: "ES256", alg: AlgorithmES256, key: "ecdsa256"},
+	{name: "ES384", alg: AlgorithmES384, key: "ecdsa384"},
+	{name: "ES512", alg: AlgorithmES512, key: "ecdsa521"},
+	{name: "EdDSA", alg: AlgorithmEdDSA, key: "ed25519"},
+}
+
+func TestEncoding_DeterministicVectors(t *testing.T) {
+	enc, err := NewEncoding(WithDeterministicSigning([]byte("go-cose test vectors")))
+	require.NoError(t, err)
+
+	for _, tt := range vectorAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			require.NoError(t, signer.Headers.Set(HeaderKeyID, []byte(tt.key)))
+
+			msg := NewSign1Message()
+			msg.SetContent([]byte("This is the content."))
+			require.NoError(t, msg.SetSigner(signer))
+
+			b, err := enc.Encode(msg)
+			require.NoError(t, err)
+			again, err := enc.Encode(msg)
+			require.NoError(t, err)
+			require.Equal(t, b, again, "encoding must be reproducible")
+
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			_, err = StdEncoding.Decode(b, &Config{
+				GetVerifiers: func(*Headers) ([]*Verifier, error) {
+					return []*Verifier{verifier}, nil
+				},
+			})
+			require.NoError(t, err)
+
+			path := filepath.Join("testdata", "vectors", "sign1-"+strings.ToLower(tt.name)+".hex")
+			if *updateVectors {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				require.NoError(t, os.WriteFile(path, []byte(hex.EncodeToString(b)+"\n"), 0o600))
+				return
+			}
+
+			expected, err := os.ReadFile(path)
+			require.NoError(t, err, "run go test with -update-vectors to generate test vectors")
+			assert.Equal(t, strings.TrimSpace(string(expected)), hex.EncodeToString(b))
+		})
+	}
+}
+
+func TestEncoding_DeterministicSigningEmptySeed(t *testing.T) {
+	enc, err := NewEncoding(WithDeterministicSigning(nil))
+	assert.Error(t, err)
+	assert.Nil(t, enc)
+}
@@ -8,36 +8,70 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
-	"crypto/rsa"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math/big"
+	"sync/atomic"
 )
 
 // Verifier is a public key container for verifying COSE signatures.
 type Verifier struct {
-	publicKey crypto.PublicKey
-	alg       *algorithm
+	publicKey  crypto.PublicKey
+	alg        *algorithm
+	lenientPSS bool
+	minKeySize int
+	// fingerprint holds the key fingerprint computed on first use
+	fingerprint atomic.Value
+}
+
+// VerifierOption represents an option for creating a verifier.
+type VerifierOption func(*Verifier) error
+
+// WithLenientPSSSaltLength accepts RSASSA-PSS signatures with any salt length
+// instead of requiring the salt length to equal the hash length.
+func WithLenientPSSSaltLength() VerifierOption {
+	return func(v *Verifier) error {
+		if v.alg.Type != algorithmTypeKeyRSA {
+			return errors.New("PSS salt length requires an RSA verifier")
+		}
+		v.lenientPSS = true
+		return nil
+	}
+}
+
+// WithVerifierMinRSAKeySize overrides the minimum RSA key size in bits of the algorithm for the verifier.
+func WithVerifierMinRSAKeySize(bits int) VerifierOption {
+	return func(v *Verifier) error {
+		if v.alg.Type != algorithmTypeKeyRSA {
+			return errors.New("minimum RSA key size requires an RSA verifier")
+		}
+		if bits <= 0 {
+			return fmt.Errorf("invalid minimum RSA key size %d", bits)
+		}
+		v.minKeySize = bits
+		return nil
+	}
 }
 
 // NewVerifier creates a new verifier from a public key and algorithm.
-func NewVerifier(alg Algorithm, key crypto.PublicKey) (*Verifier, error) {
+func NewVerifier(alg Algorithm, key crypto.PublicKey, opts ...VerifierOption) (*Verifier, error) {
 	if key == nil {
 		return nil, errors.New("key can not be nil")
 	}
 
 	a := getAlg(string(alg))
-	if a == nil || a.Type == algorithmTypeUnsupported {
+	if a == nil {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if !a.IsSigningAlgorithm() {
+		return nil, ErrAlgorithmNotForSigning
+	}
+	if !a.Implemented {
 		return nil, ErrUnsupportedAlgorithm
 	}
 
 	switch k := key.(type) {
-	case *rsa.PublicKey:
-		if a.Type != algorithmTypeKeyRSA {
-			return nil, ErrAlgorithmNotMatchKey
-		}
-		if a.MinKeySize > 0 && a.MinKeySize > k.Size()*8 {
-			return nil, ErrMinKeySize{a.MinKeySize}
-		}
 	case *ecdsa.PublicKey:
 		if a.Type != algorithmTypeKeyECDSA {
 			return nil, ErrAlgorithmNotMatchKey
@@ -50,13 +84,77 @@ func NewVerifier(alg Algorithm, key crypto.PublicKey) (*Verifier, error) {
 			return nil, ErrAlgorithmNotMatchKey
 		}
 	default:
-		return nil, ErrUnsupportedKeyType
+		if rsaModulus(key) == nil {
+			return nil, ErrUnsupportedKeyType
+		}
+		if a.Type != algorithmTypeKeyRSA {
+			return nil, ErrAlgorithmNotMatchKey
+		}
 	}
 
-	return &Verifier{
-		publicKey: key,
-		alg:       a,
-	}, nil
+	v := &Verifier{
+		publicKey:  key,
+		alg:        a,
+		minKeySize: a.MinKeySize,
+	}
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			return nil, err
+		}
+	}
+	if n := rsaModulus(key); n != nil && (n.BitLen()+7)/8*8 < v.minKeySize {
+		return nil, ErrMinKeySize{v.minKeySize}
+	}
+	return v, nil
+}
+
+// MustNewVerifier is like NewVerifier but panics if the verifier can not be created,
+// it simplifies initialization of verifiers with keys known to be valid.
+func MustNewVerifier(alg Algorithm, key crypto.PublicKey, opts ...VerifierOption) *Verifier {
+	v, err := NewVerifier(alg, key, opts...)
+	if err != nil {
+		panic("cose: NewVerifier: " + err.Error())
+	}
+	return v
+}
+
+// NewECDSAVerifier creates a new verifier from raw elliptic curve public key coordinates.
+// The curve is selected by the algorithm.
+func NewECDSAVerifier(alg Algorithm, x, y []byte) (*Verifier, error) {
+	a := getAlg(string(alg))
+	if a == nil || !a.Implemented {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if a.Type != algorithmTypeKeyECDSA {
+		return nil, ErrAlgorithmNotMatchKey
+	}
+
+	size := curveByteSize(a.KeyEllipticCurve)
+	if len(x) != size || len(y) != size {
+		return nil, ErrInvalidCurvePoint
+	}
+	key := &ecdsa.PublicKey{
+		Curve: a.KeyEllipticCurve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	// Point at infinity is not on the curve
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, ErrInvalidCurvePoint
+	}
+
+	return NewVerifier(alg, key)
+}
+
+// NewRSAVerifierFromModulus creates a new verifier from a raw RSA public key modulus and exponent.
+func NewRSAVerifierFromModulus(alg Algorithm, n []byte, e int) (*Verifier, error) {
+	if !rsaSupported {
+		return nil, ErrUnsupportedKeyType
+	}
+	if len(n) == 0 || e < 3 || e%2 == 0 {
+		return nil, errors.New("invalid RSA public key")
+	}
+	return NewVerifier(alg, newRSAPublicKey(new(big.Int).SetBytes(n), e))
 }
 
 // GetHash returns the hash algorithm used by the verifier.
@@ -69,8 +167,103 @@ func (v *Verifier) GetPublicKey() crypto.PublicKey {
 	return v.publicKey
 }
 
+// PublicKey returns the public key used by the verifier.
+func (v *Verifier) PublicKey() crypto.PublicKey {
+	return v.publicKey
+}
+
+// KeyFingerprint returns the SHA-256 hash of the public key for logging and deduplication,
+// verifiers of the same key have the same fingerprint regardless of the algorithm.
+func (v *Verifier) KeyFingerprint() []byte {
+	return append([]byte(nil), v.keyFingerprint()...)
+}
+
+// keyFingerprint returns the cached key fingerprint.
+func (v *Verifier) keyFingerprint() []byte {
+	if f, ok := v.fingerprint.Load().([]byte); ok {
+		return f
+	}
+	var key interface{}
+	switch k := v.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		size := curveByteSize(k.Curve)
+		key = []interface{}{"EC2", k.Curve.Params().Name, i2ospPad(k.X, size), i2ospPad(k.Y, size)}
+	case ed25519.PublicKey:
+		key = []interface{}{"OKP", []byte(k)}
+	default:
+		if n := rsaModulus(k); n != nil {
+			key = []interface{}{"RSA", n.Bytes(), rsaPublicExponent(k)}
+		}
+	}
+	data, _ := StdEncoding.marshal(key)
+	f := sha256.Sum256(data)
+	v.fingerprint.Store(f[:])
+	return f[:]
+}
+
+// uniqueVerifiers returns the verifiers without later verifiers of the same algorithm and key.
+func uniqueVerifiers(verifiers []*Verifier) []*Verifier {
+	if len(verifiers) < 2 {
+		return verifiers
+	}
+	type verifierKey struct {
+		alg         string
+		lenientPSS  bool
+		fingerprint string
+	}
+	seen := make(map[verifierKey]bool, len(verifiers))
+	unique := make([]*Verifier, 0, len(verifiers))
+	for _, v := range verifiers {
+		if v == nil {
+			unique = append(unique, v)
+			continue
+		}
+		k := verifierKey{v.alg.Name, v.lenientPSS, string(v.keyFingerprint())}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// keyBits returns the public key size in bits.
+func (v *Verifier) keyBits() int {
+	switch key := v.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 8 * len(key)
+	}
+	if n := rsaModulus(v.publicKey); n != nil {
+		return n.BitLen()
+	}
+	return 0
+}
+
+// GetPublicKeyInfo returns the type of the public key, "RSA", "ECDSA" or "EdDSA",
+// and its size in bits, the RSA modulus or curve size.
+func (v *Verifier) GetPublicKeyInfo() (keyType string, bitSize int, err error) {
+	switch v.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return "ECDSA", v.keyBits(), nil
+	case ed25519.PublicKey:
+		return "EdDSA", v.keyBits(), nil
+	}
+	if rsaModulus(v.publicKey) != nil {
+		return "RSA", v.keyBits(), nil
+	}
+	return "", 0, ErrUnsupportedKeyType
+}
+
 // Verify verifies a COSE signature.
 func (v *Verifier) Verify(digest, sig []byte) error {
+	// Reject signatures of impossible size before hashing and any big number math
+	if size := v.alg.SignatureSize(v.keyBits()); size > 0 && len(sig) != size {
+		return ErrVerification
+	}
+
 	hash := v.GetHash()
 	// calculate the hash of the message, if the algorithm requires it
 	if hash > 0 {
@@ -82,38 +275,50 @@ func (v *Verifier) Verify(digest, sig []byte) error {
 		_, _ = h.Write(digest)
 		digest = h.Sum(nil)
 	}
+	return v.verifyHashed(hash, digest, sig)
+}
 
+// VerifyWithHash verifies a COSE signature of the externally computed hash of the Sig_structure,
+// the hash function must match the algorithm hash function.
+func (v *Verifier) VerifyWithHash(hash crypto.Hash, hashedDigest, sig []byte) error {
+	if hash == 0 || hash != v.GetHash() {
+		return ErrUnsupportedAlgorithm
+	}
+	if !hash.Available() {
+		return ErrUnavailableHashAlgorithm
+	}
+	if len(hashedDigest) != hash.Size() {
+		return ErrInvalidHashSize
+	}
+	if size := v.alg.SignatureSize(v.keyBits()); size > 0 && len(sig) != size {
+		return ErrVerification
+	}
+	return v.verifyHashed(hash, hashedDigest, sig)
+}
+
+// verifyHashed verifies the signature of the digest hashed with the algorithm hash function.
+func (v *Verifier) verifyHashed(hash crypto.Hash, digest, sig []byte) error {
 	switch key := v.GetPublicKey().(type) {
-	case *rsa.PublicKey:
-		err := rsa.VerifyPSS(key, hash, digest, sig, &rsa.PSSOptions{
-			SaltLength: rsa.PSSSaltLengthEqualsHash,
-			Hash:       hash,
-		})
-		if err == rsa.ErrVerification {
-			return ErrVerification
-		} else {
-			return err
-		}
 	case *ecdsa.PublicKey:
-		keySize := curveByteSize(v.alg.KeyEllipticCurve)
-		if len(sig) != keySize*2 {
-			return ErrVerification
+		r, s, err := ecdsaSignatureValues(sig, curveByteSize(v.alg.KeyEllipticCurve))
+		if err != nil {
+			return err
 		}
 
-		r := big.NewInt(0).SetBytes(sig[:keySize])
-		s := big.NewInt(0).SetBytes(sig[keySize:])
-
 		if !ecdsa.Verify(key, digest, r, s) {
 			return ErrVerification
 		} else {
 			return nil
 		}
 	case ed25519.PublicKey:
+		if hash > 0 {
+			return verifyEd25519ph(key, digest, sig)
+		}
 		if !ed25519.Verify(key, digest, sig) {
 			return ErrVerification
 		} else {
 			return nil
 		}
 	}
-	return ErrUnsupportedKeyType
+	return verifyRSA(v.publicKey, hash, digest, sig, v.lenientPSS)
 }
@@ -0,0 +1,52 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"crypto"
+	"sync"
+)
+
+// VerifierPool is a pool of verifiers for the same algorithm and public key
+// safe for concurrent use.
+type VerifierPool struct {
+	verifier *Verifier
+	pool     sync.Pool
+}
+
+// NewVerifierPool creates a new verifier pool from a public key and algorithm.
+func NewVerifierPool(alg Algorithm, key crypto.PublicKey) (*VerifierPool, error) {
+	v, err := NewVerifier(alg, key)
+	if err != nil {
+		return nil, err
+	}
+	p := &VerifierPool{verifier: v}
+	p.pool.New = func() interface{} {
+		c := *v
+		return &c
+	}
+	return p, nil
+}
+
+// Acquire returns a verifier from the pool, it should be returned with Release after use.
+func (p *VerifierPool) Acquire() *Verifier {
+	return p.pool.Get().(*Verifier)
+}
+
+// Release returns the verifier acquired from the pool.
+func (p *VerifierPool) Release(v *Verifier) {
+	if v == nil || v.alg != p.verifier.alg {
+		return
+	}
+	p.pool.Put(v)
+}
+
+// GetVerifiers returns the pool verifier regardless of headers, it can be used as Config.GetVerifiers.
+//
+// Verifiers are read-only after construction, so the same verifier is shared by all callers
+// instead of being acquired from the pool as it would never be released by the decoder.
+func (p *VerifierPool) GetVerifiers(*Headers) ([]*Verifier, error) {
+	return []*Verifier{p.verifier}, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVerifierPool(t *testing.T) {
+	_, err := NewVerifierPool(AlgorithmES256, nil)
+	assert.Error(t, err)
+	_, err = NewVerifierPool(AlgorithmEdDSA, getPublicKey(t, "ecdsa256"))
+	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
+}
+
+func TestVerifierPool_Concurrent(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	pool, err := NewVerifierPool(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	config := &Config{GetVerifiers: pool.GetVerifiers}
+
+	const goroutines = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := StdEncoding.Decode(b, config)
+			errs <- err
+
+			v := pool.Acquire()
+			defer pool.Release(v)
+			dec, err := StdEncoding.Decode(b, nil)
+			if dec == nil {
+				errs <- err
+				return
+			}
+			errs <- dec.(*Sign1Message).VerifySignatureOnly(v, nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -5,7 +5,11 @@
 package cose
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,6 +73,7 @@ func TestVerifier_EdDSAInvalidSignature(t *testing.T) {
 }
 
 func TestVerifier_PS512InvalidKey(t *testing.T) {
+	requireRSA(t)
 	verifier, err := NewVerifier(AlgorithmPS512, getPublicKey(t, "ecdsa256"))
 	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
 	assert.Nil(t, verifier)
@@ -81,6 +86,7 @@ func TestVerifier_ES256InvalidKey(t *testing.T) {
 }
 
 func TestVerifier_EdDSAInvalidKey(t *testing.T) {
+	requireRSA(t)
 	verifier, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "ed25519"))
 	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
 	assert.Nil(t, verifier)
@@ -97,3 +103,236 @@ func TestVerifier_InvalidEllipticCurve(t *testing.T) {
 	assert.ErrorIs(t, err, ErrInvalidEllipticCurve)
 	assert.Nil(t, verifier)
 }
+
+func TestVerifier_NewECDSAVerifier(t *testing.T) {
+	key := getPublicKey(t, "ecdsa256").(*ecdsa.PublicKey)
+	x, err := i2osp(key.X, 32)
+	require.NoError(t, err)
+	y, err := i2osp(key.Y, 32)
+	require.NoError(t, err)
+
+	verifier, err := NewECDSAVerifier(AlgorithmES256, x, y)
+	require.NoError(t, err)
+	assert.Equal(t, key, verifier.PublicKey())
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	signature, err := signer.Sign(rand.Reader, []byte("test"))
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("test"), signature))
+
+	_, err = NewECDSAVerifier(AlgorithmES384, x, y)
+	assert.ErrorIs(t, err, ErrInvalidCurvePoint)
+
+	_, err = NewECDSAVerifier(AlgorithmES256, x[1:], y)
+	assert.ErrorIs(t, err, ErrInvalidCurvePoint)
+
+	_, err = NewECDSAVerifier(AlgorithmES256, make([]byte, 32), make([]byte, 32))
+	assert.ErrorIs(t, err, ErrInvalidCurvePoint)
+
+	offCurve := append([]byte{}, y...)
+	offCurve[31] ^= 1
+	_, err = NewECDSAVerifier(AlgorithmES256, x, offCurve)
+	assert.ErrorIs(t, err, ErrInvalidCurvePoint)
+
+	_, err = NewECDSAVerifier(AlgorithmEdDSA, x, y)
+	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
+}
+
+func TestVerifier_NewRSAVerifierFromModulus(t *testing.T) {
+	key := getPublicKey(t, "rsa2048").(*rsa.PublicKey)
+
+	verifier, err := NewRSAVerifierFromModulus(AlgorithmPS256, key.N.Bytes(), key.E)
+	require.NoError(t, err)
+	assert.Equal(t, key, verifier.PublicKey())
+
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa2048"))
+	require.NoError(t, err)
+	signature, err := signer.Sign(rand.Reader, []byte("test"))
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("test"), signature))
+
+	_, err = NewRSAVerifierFromModulus(AlgorithmPS256, nil, key.E)
+	assert.Error(t, err)
+
+	_, err = NewRSAVerifierFromModulus(AlgorithmPS256, key.N.Bytes()[:128], key.E)
+	assert.ErrorIs(t, err, ErrMinKeySize{2048})
+}
+
+func TestVerifier_InvalidSignatureSize(t *testing.T) {
+	for _, tt := range vectorAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			signature, err := signer.Sign(rand.Reader, []byte("test"))
+			require.NoError(t, err)
+			require.NoError(t, verifier.Verify([]byte("test"), signature))
+
+			assert.ErrorIs(t, verifier.Verify([]byte("test"), signature[1:]), ErrVerification)
+			assert.ErrorIs(t, verifier.Verify([]byte("test"), append(signature, 0)), ErrVerification)
+			assert.ErrorIs(t, verifier.Verify([]byte("test"), make([]byte, 4096)), ErrVerification)
+		})
+	}
+}
+
+func TestVerifier_ECDSAHostileSignatureValues(t *testing.T) {
+	for _, tt := range vectorAlgorithms {
+		if tt.alg != AlgorithmES256 && tt.alg != AlgorithmES384 && tt.alg != AlgorithmES512 {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewVerifier(tt.alg, getPublicKey(t, tt.key))
+			require.NoError(t, err)
+			n := curveByteSize(verifier.alg.KeyEllipticCurve)
+			order := verifier.alg.KeyEllipticCurve.Params().N
+
+			max := bytes.Repeat([]byte{0xff}, 2*n)
+			orderBytes, err := i2osp(order, n)
+			require.NoError(t, err)
+			for _, sig := range [][]byte{
+				make([]byte, 2*n),
+				max,
+				append(append([]byte{}, orderBytes...), orderBytes...),
+				append(make([]byte, n), max[:n]...),
+			} {
+				assert.NotPanics(t, func() {
+					assert.ErrorIs(t, verifier.Verify([]byte("test"), sig), ErrVerification)
+				})
+			}
+		})
+	}
+}
+
+func TestVerifier_VerifyWithHash(t *testing.T) {
+	digest := []byte("Sig_structure")
+	for _, tt := range vectorAlgorithms {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, getPrivateKey(t, tt.key))
+			require.NoError(t, err)
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			sig, err := signer.Sign(rand.Reader, digest)
+			require.NoError(t, err)
+
+			hash := verifier.GetHash()
+			if hash == 0 {
+				assert.ErrorIs(t, verifier.VerifyWithHash(crypto.SHA512, digest, sig), ErrUnsupportedAlgorithm)
+				return
+			}
+			h := hash.New()
+			h.Write(digest)
+			hashed := h.Sum(nil)
+
+			assert.NoError(t, verifier.VerifyWithHash(hash, hashed, sig))
+			hashed[0] ^= 1
+			assert.ErrorIs(t, verifier.VerifyWithHash(hash, hashed, sig), ErrVerification)
+			assert.ErrorIs(t, verifier.VerifyWithHash(hash, hashed[1:], sig), ErrInvalidHashSize)
+			assert.ErrorIs(t, verifier.VerifyWithHash(crypto.SHA1, hashed, sig), ErrUnsupportedAlgorithm)
+		})
+	}
+}
+
+func TestVerifier_WithMinRSAKeySize(t *testing.T) {
+	verifier, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa1024"), WithVerifierMinRSAKeySize(1024))
+	require.NoError(t, err)
+	signer, err := NewSigner(AlgorithmPS256, getPrivateKey(t, "rsa1024"), WithMinRSAKeySize(1024))
+	require.NoError(t, err)
+	sig, err := signer.Sign(rand.Reader, []byte("test"))
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("test"), sig))
+
+	_, err = NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa2048"), WithVerifierMinRSAKeySize(4096))
+	assert.ErrorIs(t, err, ErrMinKeySize{4096})
+	_, err = NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"), WithVerifierMinRSAKeySize(1024))
+	assert.Error(t, err)
+}
+
+func TestVerifier_MustNewVerifier(t *testing.T) {
+	assert.NotNil(t, MustNewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256")))
+	assert.PanicsWithValue(t, "cose: NewVerifier: "+ErrAlgorithmNotMatchKey.Error(), func() {
+		MustNewVerifier(AlgorithmES256, getPublicKey(t, "rsa2048"))
+	})
+}
+
+func TestVerifier_KeyFingerprint(t *testing.T) {
+	for name, alg := range map[string]Algorithm{"ecdsa256": AlgorithmES256, "ed25519": AlgorithmEdDSA, "rsa2048": AlgorithmPS256} {
+		t.Run(name, func(t *testing.T) {
+			signer, err := NewSigner(alg, getPrivateKey(t, name))
+			require.NoError(t, err)
+			verifier, err := signer.ToVerifier()
+			require.NoError(t, err)
+			other, err := NewVerifier(alg, getPublicKey(t, name))
+			require.NoError(t, err)
+
+			fingerprint := verifier.KeyFingerprint()
+			assert.Len(t, fingerprint, 32)
+			assert.Equal(t, fingerprint, verifier.KeyFingerprint())
+			assert.Equal(t, fingerprint, other.KeyFingerprint())
+
+			fingerprint[0]++
+			assert.NotEqual(t, fingerprint, verifier.KeyFingerprint())
+		})
+	}
+
+	a, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	b, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256-2"))
+	require.NoError(t, err)
+	assert.NotEqual(t, a.KeyFingerprint(), b.KeyFingerprint())
+
+	ps256, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa2048"))
+	require.NoError(t, err)
+	ps384, err := NewVerifier(AlgorithmPS384, getPublicKey(t, "rsa2048"))
+	require.NoError(t, err)
+	assert.Equal(t, ps256.KeyFingerprint(), ps384.KeyFingerprint())
+}
+
+func TestVerifier_UniqueVerifiers(t *testing.T) {
+	a, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	a2, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	b, err := NewVerifier(AlgorithmES256, getPublicKey(t, "ecdsa256-2"))
+	require.NoError(t, err)
+	assert.Equal(t, []*Verifier{a, b}, uniqueVerifiers([]*Verifier{a, a2, b, a}))
+
+	ps256, err := NewVerifier(AlgorithmPS256, getPublicKey(t, "rsa2048"))
+	require.NoError(t, err)
+	ps384, err := NewVerifier(AlgorithmPS384, getPublicKey(t, "rsa2048"))
+	require.NoError(t, err)
+	assert.Equal(t, []*Verifier{ps256, ps384}, uniqueVerifiers([]*Verifier{ps256, ps384}))
+}
+
+func TestVerifier_DuplicateVerifiersVerifiedOnce(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+	data, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	verifiers := make([]*Verifier, 0, 4)
+	for _, name := range []string{"ecdsa256-2", "ecdsa256-2", "ecdsa256-2", "ecdsa256"} {
+		v, err := NewVerifier(AlgorithmES256, getPublicKey(t, name))
+		require.NoError(t, err)
+		verifiers = append(verifiers, v)
+	}
+	assert.Len(t, uniqueVerifiers(verifiers), 2)
+
+	verified := 0
+	dec, err := StdEncoding.Decode(data, &Config{
+		GetVerifiers: func(*Headers) ([]*Verifier, error) {
+			return verifiers, nil
+		},
+		Verified: func(v *Verifier) {
+			verified++
+			assert.Same(t, verifiers[3], v)
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), dec.GetContent())
+	assert.Equal(t, 1, verified)
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_nox509
+// +build !cose_nox509
+
+package cose
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// X.509 support can be left out with the cose_nox509 build tag for constrained builds
+type x509Certificate = x509.Certificate
+
+// NewVerifierFromX509Certificate creates a new verifier from the X.509 certificate public key and algorithm.
+func NewVerifierFromX509Certificate(alg Algorithm, cert *x509.Certificate) (*Verifier, error) {
+	if cert == nil {
+		return nil, errors.New("certificate can not be nil")
+	}
+	return NewVerifier(alg, cert.PublicKey)
+}
+
+// SetX5T sets the x5t protected header to the thumbprint of the certificate
+// computed with the hash algorithm such as AlgorithmSHA256.
+func (h *Headers) SetX5T(cert *x509.Certificate, hashAlg Algorithm) error {
+	if cert == nil {
+		return errors.New("certificate can not be nil")
+	}
+	return h.setCertHash(cert.Raw, hashAlg)
+}
+
+// headerLabelX5Chain is the label of the x5chain header (RFC 9360 section 2).
+const headerLabelX5Chain int64 = 33
+
+// issuerAuthEncoding permits only the algorithms of ISO/IEC 18013-5 issuer authentication.
+var issuerAuthEncoding, issuerAuthEncodingErr = NewEncoding(WithAlgorithmPolicy(AlgorithmPolicy{
+	Algorithms: []Algorithm{AlgorithmES256, AlgorithmEdDSA},
+}))
+
+func init() {
+	if issuerAuthEncodingErr != nil {
+		panic(issuerAuthEncodingErr)
+	}
+}
+
+// VerifyIssuerAuth verifies the issuer authentication COSE_Sign1 message of an ISO/IEC 18013-5
+// mobile driving licence and returns the MobileSecurityObject bytes unwrapped from tag 24 and
+// the document signer certificate.
+//
+// The document signer certificate is the first certificate of the x5chain header and must chain
+// to one of the roots at the given time, the current time is used if at is zero. Only the ES256
+// and EdDSA algorithms are permitted.
+func VerifyIssuerAuth(data []byte, roots *x509.CertPool, at time.Time) (payload []byte, signerCert *x509.Certificate, err error) {
+	if roots == nil {
+		return nil, nil, errors.New("roots can not be nil")
+	}
+	config := &Config{
+		GetVerifiers: func(h *Headers) ([]*Verifier, error) {
+			cert, err := verifyX5Chain(h, roots, at)
+			if err != nil {
+				return nil, err
+			}
+			alg, err := h.GetAlgorithm()
+			if err != nil {
+				return nil, err
+			}
+			v, err := NewVerifierFromX509Certificate(alg, cert)
+			if err != nil {
+				return nil, err
+			}
+			signerCert = cert
+			return []*Verifier{v}, nil
+		},
+	}
+	msg, err := issuerAuthEncoding.DecodeSign1(data, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	content := msg.GetContent()
+	if !isEncodedCBOR(content) {
+		return nil, nil, errors.New("issuer auth payload is not wrapped in tag 24")
+	}
+	if payload, err = unwrapEncodedCBOR(issuerAuthEncoding, content); err != nil {
+		return nil, nil, err
+	}
+	return payload, signerCert, nil
+}
+
+// verifyX5Chain verifies the certificates of the x5chain header and returns the leaf certificate.
+func verifyX5Chain(h *Headers, roots *x509.CertPool, at time.Time) (*x509.Certificate, error) {
+	value, ok, err := h.Lookup(headerLabelX5Chain)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMissingX5Chain
+	}
+	// A single certificate is a byte string, a chain is an array of byte strings
+	var ders []interface{}
+	switch v := value.(type) {
+	case []byte:
+		ders = []interface{}{v}
+	case []interface{}:
+		ders = v
+	}
+	if len(ders) == 0 {
+		return nil, fmt.Errorf("%w: x5chain header is not a certificate or an array of certificates", ErrInvalidHeader)
+	}
+
+	certs := make([]*x509.Certificate, len(ders))
+	for i, v := range ders {
+		der, ok := v.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%w: x5chain certificate %d is not a byte string", ErrInvalidHeader, i)
+		}
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, fmt.Errorf("x5chain certificate %d: %w", i, err)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("x5chain: %w", err)
+	}
+	return certs[0], nil
+}
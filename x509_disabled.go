@@ -0,0 +1,18 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build cose_nox509
+// +build cose_nox509
+
+package cose
+
+import (
+	"crypto"
+)
+
+// x509Certificate holds the public key of certificates returned by Config.FetchCertificate
+// when X.509 support is left out with the cose_nox509 build tag
+type x509Certificate = struct {
+	PublicKey crypto.PublicKey
+}
@@ -0,0 +1,285 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !cose_nox509
+// +build !cose_nox509
+
+package cose
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding_DecodeFetchCertificate(t *testing.T) {
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.SetProtected(HeaderX5U, "https://example.com/cert.der"))
+
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.SetSigner(signer))
+
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	var fetched string
+	config := &Config{
+		FetchCertificate: func(ctx context.Context, uri string) (*x509.Certificate, error) {
+			fetched = uri
+			return getCertificate(t, "ecdsa256"), nil
+		},
+	}
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/cert.der", fetched)
+	assert.Equal(t, msg.GetContent(), dec.GetContent())
+
+	config.FetchCertificate = func(ctx context.Context, uri string) (*x509.Certificate, error) {
+		return getCertificate(t, "ecdsa256-2"), nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	fetchErr := errors.New("fetch failed")
+	config.FetchCertificate = func(ctx context.Context, uri string) (*x509.Certificate, error) {
+		return nil, fetchErr
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, fetchErr)
+
+	config.FetchCertificate = func(ctx context.Context, uri string) (*x509.Certificate, error) {
+		return nil, nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.Error(t, err)
+}
+
+func TestEncoding_DecodeFetchCertificateUnprotectedX5U(t *testing.T) {
+	// The attacker signs with their own key and adds an unprotected x5u of their certificate
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256-2"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.Headers.Set(HeaderX5U, "https://attacker.example.com/cert.der"))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	fetched := false
+	config := &Config{
+		FetchCertificate: func(ctx context.Context, uri string) (*x509.Certificate, error) {
+			fetched = true
+			return getCertificate(t, "ecdsa256-2"), nil
+		},
+	}
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrVerification)
+	assert.False(t, fetched)
+
+	config.AllowUnprotectedX5U = true
+	_, err = StdEncoding.Decode(b, config)
+	assert.NoError(t, err)
+	assert.True(t, fetched)
+}
+
+func TestNewVerifierFromX509Certificate_Ed25519(t *testing.T) {
+	cert := getCertificate(t, "ed25519")
+	require.IsType(t, ed25519.PublicKey{}, cert.PublicKey)
+
+	// DGC style message with the kid of the certificate fingerprint in unprotected headers
+	fingerprint := sha256.Sum256(cert.Raw)
+	signer, err := NewSigner(AlgorithmEdDSA, getPrivateKey(t, "ed25519"))
+	require.NoError(t, err)
+	msg := NewSign1Message()
+	msg.SetContent([]byte("test"))
+	require.NoError(t, msg.Headers.Set(HeaderKeyID, fingerprint[:8]))
+	require.NoError(t, msg.SetSigner(signer))
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+
+	config := &Config{
+		GetVerifiers: func(headers *Headers) ([]*Verifier, error) {
+			kid, _, err := headers.Unprotected().Lookup(HeaderKeyID)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(kid.([]byte), fingerprint[:8]) {
+				return nil, nil
+			}
+			alg, err := headers.GetProtected(HeaderAlgorithm)
+			if err != nil {
+				return nil, err
+			}
+			v, err := NewVerifier(Algorithm(alg.(string)), cert.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			return []*Verifier{v}, nil
+		},
+	}
+	dec, err := StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test"), dec.GetContent())
+
+	v, err := NewVerifierFromX509Certificate(AlgorithmEdDSA, cert)
+	require.NoError(t, err)
+	config.GetVerifiers = func(*Headers) ([]*Verifier, error) {
+		return []*Verifier{v}, nil
+	}
+	_, err = StdEncoding.Decode(b, config)
+	require.NoError(t, err)
+
+	b[len(b)-1] ^= 0xff
+	_, err = StdEncoding.Decode(b, config)
+	assert.ErrorIs(t, err, ErrVerification)
+
+	_, err = NewVerifierFromX509Certificate(AlgorithmES256, cert)
+	assert.ErrorIs(t, err, ErrAlgorithmNotMatchKey)
+}
+
+func TestHeaders_SetX5T(t *testing.T) {
+	cert := getCertificate(t, "ecdsa256")
+	thumbprint := sha256.Sum256(cert.Raw)
+
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	require.NoError(t, signer.Headers.SetX5T(cert, AlgorithmSHA256))
+	certHash, err := signer.Headers.GetX5T()
+	require.NoError(t, err)
+	assert.Equal(t, &CertHash{Algorithm: AlgorithmSHA256, Hash: thumbprint[:]}, certHash)
+
+	// The header is encoded as the [hash-alg, hash-value] tuple
+	msg := NewSign1Message().WithContent([]byte("test")).WithSigner(signer)
+	b, err := StdEncoding.Encode(msg)
+	require.NoError(t, err)
+	dec, err := StdEncoding.DecodeSign1(b, &Config{GetVerifiers: staticVerifier(t, signer)})
+	require.NoError(t, err)
+	v, err := dec.Headers.GetProtected(int64(34))
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(-16), thumbprint[:]}, v)
+	certHash, err = dec.Headers.GetX5T()
+	require.NoError(t, err)
+	assert.Equal(t, &CertHash{Algorithm: AlgorithmSHA256, Hash: thumbprint[:]}, certHash)
+
+	assert.Error(t, NewHeaders().SetX5T(nil, AlgorithmSHA256))
+	assert.ErrorIs(t, NewHeaders().SetX5T(cert, AlgorithmES256), ErrUnsupportedAlgorithm)
+}
+
+// The issuer auth vectors in testdata/mdl are signed by document signer certificates issued
+// by the IACA certificate generated with openssl, valid from 2026-10-16 for a year. The
+// ES256 message has a single certificate x5chain and the EdDSA message a certificate array.
+var issuerAuthTime = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func readIssuerAuthVector(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "mdl", name))
+	require.NoError(t, err)
+	return mustHex(t, strings.TrimSpace(string(data)))
+}
+
+func issuerAuthRoots(t *testing.T) *x509.CertPool {
+	data, err := os.ReadFile(filepath.Join("testdata", "mdl", "iaca.pem"))
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(data))
+	return roots
+}
+
+func TestVerifyIssuerAuth(t *testing.T) {
+	roots := issuerAuthRoots(t)
+	for _, tt := range []struct {
+		file   string
+		signer string
+	}{
+		{"issuer-auth-es256.hex", "go-cose test document signer"},
+		{"issuer-auth-eddsa.hex", "go-cose test Ed25519 document signer"},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			data := readIssuerAuthVector(t, tt.file)
+			payload, cert, err := VerifyIssuerAuth(data, roots, issuerAuthTime)
+			require.NoError(t, err)
+			assert.Equal(t, tt.signer, cert.Subject.CommonName)
+
+			var mso map[string]interface{}
+			require.NoError(t, StdEncoding.UnmarshalCBOR(payload, &mso))
+			assert.Equal(t, "org.iso.18013.5.1.mDL", mso["docType"])
+
+			// Certificates are checked at the given time
+			var invalid x509.CertificateInvalidError
+			_, _, err = VerifyIssuerAuth(data, roots, issuerAuthTime.AddDate(1, 0, 0))
+			assert.True(t, errors.As(err, &invalid))
+			_, _, err = VerifyIssuerAuth(data, roots, issuerAuthTime.AddDate(-1, 0, 0))
+			assert.True(t, errors.As(err, &invalid))
+
+			var unknown x509.UnknownAuthorityError
+			_, _, err = VerifyIssuerAuth(data, x509.NewCertPool(), issuerAuthTime)
+			assert.True(t, errors.As(err, &unknown))
+
+			tampered := append([]byte{}, data...)
+			tampered[len(tampered)-1] ^= 1
+			_, _, err = VerifyIssuerAuth(tampered, roots, issuerAuthTime)
+			assert.ErrorIs(t, err, ErrVerification)
+		})
+	}
+
+	_, _, err := VerifyIssuerAuth(readIssuerAuthVector(t, "issuer-auth-es256.hex"), nil, issuerAuthTime)
+	assert.Error(t, err)
+}
+
+func TestVerifyIssuerAuth_Profile(t *testing.T) {
+	cert := getCertificate(t, "ecdsa256")
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	signer, err := NewSigner(AlgorithmES256, getPrivateKey(t, "ecdsa256"))
+	require.NoError(t, err)
+	encode := func(msg *Sign1Message) []byte {
+		b, err := StdEncoding.Encode(msg.WithSigner(signer))
+		require.NoError(t, err)
+		return b
+	}
+
+	msg := NewSign1Message().WithHeader(int64(33), cert.Raw)
+	require.NoError(t, msg.SetCBORContent("mso", true))
+	payload, _, err := VerifyIssuerAuth(encode(msg), roots, issuerAuthTime)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x63, 'm', 's', 'o'}, payload)
+
+	msg = NewSign1Message()
+	require.NoError(t, msg.SetCBORContent("mso", true))
+	_, _, err = VerifyIssuerAuth(encode(msg), roots, issuerAuthTime)
+	assert.ErrorIs(t, err, ErrMissingX5Chain)
+
+	msg = NewSign1Message().WithHeader(int64(33), []interface{}{"certificate"})
+	require.NoError(t, msg.SetCBORContent("mso", true))
+	_, _, err = VerifyIssuerAuth(encode(msg), roots, issuerAuthTime)
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+
+	// The MSO must be wrapped in tag 24
+	msg = NewSign1Message().WithHeader(int64(33), cert.Raw)
+	require.NoError(t, msg.SetCBORContent("mso", false))
+	_, _, err = VerifyIssuerAuth(encode(msg), roots, issuerAuthTime)
+	assert.Error(t, err)
+
+	// Algorithms other than ES256 and EdDSA are not permitted
+	es384 := getCertificate(t, "ecdsa384")
+	roots.AddCert(es384)
+	signer, err = NewSigner(AlgorithmES384, getPrivateKey(t, "ecdsa384"))
+	require.NoError(t, err)
+	msg = NewSign1Message().WithHeader(int64(33), es384.Raw)
+	require.NoError(t, msg.SetCBORContent("mso", true))
+	_, _, err = VerifyIssuerAuth(encode(msg), roots, issuerAuthTime)
+	assert.ErrorAs(t, err, &ErrPolicyViolation{})
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"fmt"
+)
+
+// headerLabelX5T is the label of the x5t header (RFC 9360 section 2).
+const headerLabelX5T int64 = 34
+
+// CertHash is the COSE_CertHash value of the x5t header, the hash of a DER encoded X.509 certificate.
+type CertHash struct {
+	Algorithm Algorithm
+	Hash      []byte
+}
+
+// setCertHash sets the x5t protected header to the hash of the DER encoded certificate.
+func (h *Headers) setCertHash(der []byte, hashAlg Algorithm) error {
+	hash, ok := hashAlg.Hash()
+	a := getAlg(string(hashAlg))
+	if !ok || a.Type != algorithmTypeUnsupported {
+		return fmt.Errorf("%w: %s is not a hash algorithm", ErrUnsupportedAlgorithm, hashAlg)
+	}
+	if !hash.Available() {
+		return ErrUnavailableHashAlgorithm
+	}
+	d := hash.New()
+	_, _ = d.Write(der)
+	return h.SetProtected(headerLabelX5T, []interface{}{a.Value, d.Sum(nil)})
+}
+
+// GetX5T returns the certificate hash of the x5t header or nil if the header is not present.
+func (h *Headers) GetX5T() (*CertHash, error) {
+	v, ok, err := h.Lookup(headerLabelX5T)
+	if err != nil || !ok {
+		return nil, err
+	}
+	tuple, ok := v.([]interface{})
+	if !ok || len(tuple) != 2 {
+		return nil, fmt.Errorf("%w: x5t header value is not a COSE_CertHash", ErrInvalidHeader)
+	}
+	hash, ok := tuple[1].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: x5t hash value is not a byte string", ErrInvalidHeader)
+	}
+	var alg Algorithm
+	switch hashAlg := tuple[0].(type) {
+	case int64:
+		a := getAlgByValue(hashAlg)
+		if a == nil {
+			return nil, fmt.Errorf("%w: x5t hash algorithm %d", ErrUnsupportedAlgorithm, hashAlg)
+		}
+		alg = Algorithm(a.Name)
+	case string:
+		alg = Algorithm(hashAlg)
+	default:
+		return nil, fmt.Errorf("%w: x5t hash algorithm %T", ErrInvalidHeader, tuple[0])
+	}
+	return &CertHash{Algorithm: alg, Hash: hash}, nil
+}
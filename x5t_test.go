@@ -0,0 +1,53 @@
+// Copyright 2021 SIA ZZ Dats. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders_GetX5T(t *testing.T) {
+	h := NewHeaders()
+	certHash, err := h.GetX5T()
+	assert.NoError(t, err)
+	assert.Nil(t, certHash)
+
+	require.NoError(t, h.setCertHash([]byte("certificate"), AlgorithmSHA384))
+	certHash, err = h.GetX5T()
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmSHA384, certHash.Algorithm)
+	assert.Len(t, certHash.Hash, 48)
+
+	// Hash algorithms can be given by name
+	h.Delete(int64(34))
+	require.NoError(t, h.Set(int64(34), []interface{}{"SHA-256/64", []byte{1, 2, 3, 4, 5, 6, 7, 8}}))
+	certHash, err = h.GetX5T()
+	require.NoError(t, err)
+	assert.Equal(t, &CertHash{Algorithm: "SHA-256/64", Hash: []byte{1, 2, 3, 4, 5, 6, 7, 8}}, certHash)
+
+	for _, tt := range []struct {
+		value interface{}
+		err   error
+	}{
+		{[]byte("hash"), ErrInvalidHeader},
+		{[]interface{}{int64(-16)}, ErrInvalidHeader},
+		{[]interface{}{int64(-16), "hash"}, ErrInvalidHeader},
+		{[]interface{}{[]byte{1}, []byte("hash")}, ErrInvalidHeader},
+		{[]interface{}{int64(-65000), []byte("hash")}, ErrUnsupportedAlgorithm},
+	} {
+		h := NewHeaders()
+		require.NoError(t, h.Set(int64(34), tt.value))
+		_, err := h.GetX5T()
+		assert.ErrorIs(t, err, tt.err)
+	}
+
+	err = NewHeaders().setCertHash([]byte("certificate"), AlgorithmES256)
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	err = NewHeaders().setCertHash([]byte("certificate"), "unknown")
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+}